@@ -0,0 +1,74 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+)
+
+//////// Event attributes /////////
+
+const (
+	Seated   = "seated"
+	Standing = "standing"
+	Mixed    = "mixed" // seated and standing areas
+)
+
+// Attributes are the accessibility and venue features of an event. A false
+// flag is unknown as often as it is a no: listings rarely say what they lack.
+type Attributes struct {
+	AllAges    bool   `dynamodbav:"all_ages" json:"all_ages,omitempty"`
+	Licensed   bool   `dynamodbav:"licensed" json:"licensed,omitempty"` // alcohol served
+	Wheelchair bool   `dynamodbav:"wheelchair" json:"wheelchair,omitempty"`
+	Auslan     bool   `dynamodbav:"auslan" json:"auslan,omitempty"` // Auslan interpreted
+	Outdoor    bool   `dynamodbav:"outdoor" json:"outdoor,omitempty"`
+	Free       bool   `dynamodbav:"free" json:"free,omitempty"`
+	Seating    string `dynamodbav:"seating" json:"seating,omitempty" jsonschema:"enum=,enum=seated,enum=standing,enum=mixed"` // empty when unknown
+}
+
+// AttributeNames are the flags of Attributes, as filtered on in match requests
+var AttributeNames = []string{"all_ages", "licensed", "wheelchair", "auslan", "outdoor", "free"}
+
+// Has reports whether the flag called name is set
+func (obj Attributes) Has(name string) (bool, error) {
+	switch name {
+	case "all_ages":
+		return obj.AllAges, nil
+	case "licensed":
+		return obj.Licensed, nil
+	case "wheelchair":
+		return obj.Wheelchair, nil
+	case "auslan":
+		return obj.Auslan, nil
+	case "outdoor":
+		return obj.Outdoor, nil
+	case "free":
+		return obj.Free, nil
+	}
+	return false, fmt.Errorf("unknown attribute %q, expected one of %s", name, strings.Join(AttributeNames, ", "))
+}
+
+// Merge returns the flags set in either, and the seating of other when it knows it
+func (obj Attributes) Merge(other Attributes) Attributes {
+	result := Attributes{
+		AllAges:    obj.AllAges || other.AllAges,
+		Licensed:   obj.Licensed || other.Licensed,
+		Wheelchair: obj.Wheelchair || other.Wheelchair,
+		Auslan:     obj.Auslan || other.Auslan,
+		Outdoor:    obj.Outdoor || other.Outdoor,
+		Free:       obj.Free || other.Free,
+		Seating:    obj.Seating,
+	}
+	if other.Seating != "" {
+		result.Seating = other.Seating
+	}
+	return result
+}
+
+// CheckSeating accepts the seating values an event can have, empty included
+func CheckSeating(seating string) error {
+	switch seating {
+	case "", Seated, Standing, Mixed:
+		return nil
+	}
+	return fmt.Errorf("unknown seating %q, expected %s, %s or %s", seating, Seated, Standing, Mixed)
+}
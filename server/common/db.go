@@ -10,6 +10,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/invopop/jsonschema"
 	"github.com/rs/zerolog"
+	"strings"
 	"time"
 )
 
@@ -33,29 +34,36 @@ type Address struct {
 }
 
 type Event struct {
-	EventID      string       `dynamodbav:"event_id"`        // UUID string
-	Source_name  string       `dynamodbav:"source_name"`     // GSI PK
-	SourceEvent  string       `dynamodbav:"source_event_id"` // GSI SK
-	Title        string       `dynamodbav:"title"`
-	Description  string       `dynamodbav:"description"`
-	Caption      string       `dynamodbav:"caption"`
-	Start        time.Time    `dynamodbav:"start"`        // stored as RFC3339 string
-	StartBucket  string       `dynamodbav:"start_bucket"` // e.g. "2025-09"
-	End          time.Time    `dynamodbav:"end"`          // stored as RFC3339 string
-	VenueName    string       `dynamodbav:"venue_name"`
-	Address      Address      `dynamodbav:"address"`
-	Geo          Geo          `dynamodbav:"geo"`
-	URL          string       `dynamodbav:"url"`
-	TicketURL    string       `dynamodbav:"ticket_url"`
-	PriceMin     float64      `dynamodbav:"price_min"`
-	PriceMax     float64      `dynamodbav:"price_max"`
-	Images       []string     `dynamodbav:"images"`        // list of strings
-	Categories   []string     `dynamodbav:"categories"`    // list of strings
-	Tags         []string     `dynamodbav:"tags"`          // list of strings
-	ExtraTags    []string     `dynamodbav:"extra_tags"`    // list of strings
-	ContentFlags ContentFlags `dynamodbav:"content_flags"` // map of booleans
-	FetchedAt    time.Time    `dynamodbav:"fetched_at"`
-	Tagged       bool         `dynamodbav:"tagged"` // whether the event has been tagged
+	EventID      string              `dynamodbav:"event_id"`        // UUID string
+	Source_name  string              `dynamodbav:"source_name"`     // GSI PK
+	SourceEvent  string              `dynamodbav:"source_event_id"` // GSI SK
+	Title        string              `dynamodbav:"title"`
+	Description  string              `dynamodbav:"description"`
+	Caption      string              `dynamodbav:"caption"`
+	Start        time.Time           `dynamodbav:"start"`        // stored as RFC3339 string
+	StartBucket  string              `dynamodbav:"start_bucket"` // e.g. "2025-09"
+	End          time.Time           `dynamodbav:"end"`          // stored as RFC3339 string
+	VenueName    string              `dynamodbav:"venue_name"`
+	Address      Address             `dynamodbav:"address"`
+	Geo          Geo                 `dynamodbav:"geo"`
+	GeoPrecision string              `dynamodbav:"geo_precision"` // source, venue, street, suburb or postcode
+	URL          string              `dynamodbav:"url"`
+	TicketURL    string              `dynamodbav:"ticket_url"`
+	TicketRef    string              `dynamodbav:"ticket_ref"` // ticketing listing, see TicketRef
+	PriceMin     float64             `dynamodbav:"price_min"`
+	PriceMax     float64             `dynamodbav:"price_max"`
+	Images       []string            `dynamodbav:"images"`        // list of strings
+	SourceImages []string            `dynamodbav:"source_images"` // image URLs as scraped, before ingestion
+	Categories   []string            `dynamodbav:"categories"`    // list of strings
+	Tags         []string            `dynamodbav:"tags"`          // list of strings
+	ExtraTags    []string            `dynamodbav:"extra_tags"`    // list of strings
+	ContentFlags ContentFlags        `dynamodbav:"content_flags"` // map of booleans
+	Attributes   Attributes          `dynamodbav:"attributes"`    // accessibility and venue features
+	FetchedAt    time.Time           `dynamodbav:"fetched_at"`
+	Tagged       bool                `dynamodbav:"tagged"`                                // whether the event has been tagged
+	Overrides    map[string]Override `dynamodbav:"overrides,omitempty" json:",omitempty"` // editorial values, by field attribute name
+	Inferred     Attributes          `dynamodbav:"inferred_attributes" json:",omitzero"`  // attributes tagging read from the description
+	LinkedTo     string              `dynamodbav:"linked_to" json:",omitempty"`           // event of another source selling the same tickets
 }
 
 type Weight struct {
@@ -97,16 +105,117 @@ const (
 	FactoryTheatre SourceType = "factorytheatre"
 	Eventbrite     SourceType = "eventbrite"
 	OurSecretSpot  SourceType = "oursecretspot"
+	Oztix          SourceType = "oztix"
+	Humanitix      SourceType = "humanitix"
+	External       SourceType = "external" // any executable speaking the external scraper protocol
+	Push           SourceType = "push"     // events posted to the ingestion API
+	UserSource     SourceType = "user"     // events submitted by one user, approved by an editor
 )
 
+// SourceScope narrows what a listing API returns. Zero values fall back to the scraper's defaults.
+type SourceScope struct {
+	Regions     []string `dynamodbav:"regions"`      // e.g. ["NSW"]
+	Center      Geo      `dynamodbav:"center"`       // search centre
+	RadiusKm    int      `dynamodbav:"radius_km"`    // search radius around Center
+	Genres      []string `dynamodbav:"genres"`       // only keep events in these genres (case insensitive)
+	HorizonDays int      `dynamodbav:"horizon_days"` // ignore events starting further out
+	PageSize    int      `dynamodbav:"page_size"`
+}
+
+// SourceCheckpoint remembers what the last successful scrape of a source saw, so
+// the next run can stop once it reaches known, unchanged events
+type SourceCheckpoint struct {
+	LastSuccessfulRun  time.Time         `dynamodbav:"last_successful_run"`
+	LastFullRun        time.Time         `dynamodbav:"last_full_run"`
+	Seen               map[string]string `dynamodbav:"seen"`                // source event ID -> content fingerprint
+	ListingFingerprint string            `dynamodbav:"listing_fingerprint"` // hash of the links on the listing page
+}
+
 type Source struct {
-	SourceID   string     `dynamodbav:"source_id"`   // UUID string
-	Name       string     `dynamodbav:"name"`        // UUID string
-	SourceType SourceType `dynamodbav:"source_type"` // UUID string
-	URL        string     `dynamodbav:"url"`         // UUID string
-	City       string     `dynamodbav:"city"`        // UUID string
-	Tags       []string   `dynamodbav:"tags"`        // UUID string
-	Active     bool       `dynamodbav:"active"`      // UUID string
+	SourceID   string           `dynamodbav:"source_id"`   // UUID string
+	Name       string           `dynamodbav:"name"`        // UUID string
+	SourceType SourceType       `dynamodbav:"source_type"` // UUID string
+	URL        string           `dynamodbav:"url"`         // UUID string
+	City       string           `dynamodbav:"city"`        // UUID string
+	Tags       []string         `dynamodbav:"tags"`        // UUID string
+	Active     bool             `dynamodbav:"active"`      // UUID string
+	Scope      SourceScope      `dynamodbav:"scope"`
+	Debug      bool             `dynamodbav:"debug"`                 // verbose client logging for this source
+	Schedule   string           `dynamodbav:"schedule"`              // cron expression for daemon mode, the default scrape schedule when empty
+	Command    []string         `dynamodbav:"command"`               // executable and arguments of an external source
+	APIKeyHash string           `dynamodbav:"api_key_hash" json:"-"` // see HashAPIKey, set on sources allowed to push events
+	Checkpoint SourceCheckpoint `dynamodbav:"checkpoint"`
+}
+
+///////// Scrape Runs /////////
+
+// ScrapeRun is the outcome of one scrape of one source
+type ScrapeRun struct {
+	SourceID      string     `dynamodbav:"source_id" json:"source_id"`
+	StartedAt     time.Time  `dynamodbav:"started_at" json:"started_at"` // sort key, stored as RFC3339 string in UTC
+	FinishedAt    time.Time  `dynamodbav:"finished_at" json:"finished_at"`
+	SourceType    SourceType `dynamodbav:"source_type" json:"source_type"`
+	Full          bool       `dynamodbav:"full" json:"full"`
+	StoppedEarly  bool       `dynamodbav:"stopped_early" json:"stopped_early"`
+	PagesFetched  int        `dynamodbav:"pages_fetched" json:"pages_fetched"`
+	EventsFound   int        `dynamodbav:"events_found" json:"events_found"`
+	EventsNew     int        `dynamodbav:"events_new" json:"events_new"`
+	EventsUpdated int        `dynamodbav:"events_updated" json:"events_updated"`
+	EventsFailed  int        `dynamodbav:"events_failed" json:"events_failed"`
+	URLsSkipped   int        `dynamodbav:"urls_skipped" json:"urls_skipped"`
+	ErrorSamples  []string   `dynamodbav:"error_samples" json:"error_samples,omitempty"`
+	Error         string     `dynamodbav:"error" json:"error,omitempty"` // set when the run itself failed
+	Anomaly       bool       `dynamodbav:"anomaly" json:"anomaly"`
+	AnomalyReason string     `dynamodbav:"anomaly_reason" json:"anomaly_reason,omitempty"`
+}
+
+///////// Quarantine /////////
+
+// QuarantinedEvent is an event the pipeline set aside for review. There is one
+// per source event, so a bad event scraped again replaces its previous entry.
+type QuarantinedEvent struct {
+	QuarantineID  string    `dynamodbav:"quarantine_id" json:"quarantine_id"` // source_name#source_event_id
+	SourceName    string    `dynamodbav:"source_name" json:"source_name"`
+	SourceEvent   string    `dynamodbav:"source_event_id" json:"source_event_id"`
+	Stage         string    `dynamodbav:"stage" json:"stage"`
+	Reasons       []string  `dynamodbav:"reasons" json:"reasons"`
+	Payload       string    `dynamodbav:"payload" json:"payload"` // the event as JSON, as it reached the stage
+	QuarantinedAt time.Time `dynamodbav:"quarantined_at" json:"quarantined_at"`
+	FixedAt       time.Time `dynamodbav:"fixed_at" json:"fixed_at,omitzero"` // set when the payload was edited by hand
+}
+
+func QuarantineID(sourceName string, sourceEvent string) string {
+	return sourceName + "#" + sourceEvent
+}
+
+///////// Submissions /////////
+
+type SubmissionStatus string
+
+const (
+	SubmissionPending  SubmissionStatus = "pending"
+	SubmissionApproved SubmissionStatus = "approved"
+	SubmissionRejected SubmissionStatus = "rejected"
+)
+
+// Submission is an event suggested by a user. Approved, it goes through the
+// pipeline as an event of the user's source, with the submission ID as its
+// source event ID.
+type Submission struct {
+	SubmissionID string           `dynamodbav:"submission_id" json:"submission_id"`
+	UserID       string           `dynamodbav:"user_id" json:"user_id"`
+	Status       SubmissionStatus `dynamodbav:"status" json:"status"`
+	Event        IngestEvent      `dynamodbav:"event" json:"event"`
+	SubmittedAt  time.Time        `dynamodbav:"submitted_at" json:"submitted_at"`
+	EditedBy     string           `dynamodbav:"edited_by" json:"edited_by,omitempty"` // editor who last changed the event
+	ReviewedBy   string           `dynamodbav:"reviewed_by" json:"reviewed_by,omitempty"`
+	ReviewedAt   time.Time        `dynamodbav:"reviewed_at" json:"reviewed_at,omitzero"`
+	Reason       string           `dynamodbav:"reason" json:"reason,omitempty"` // given when rejected
+}
+
+// UserSourceID is the source of the events submitted by userID
+func UserSourceID(userID string) string {
+	return "user-" + userID
 }
 
 ///////// Raw Events /////////
@@ -178,6 +287,85 @@ func (obj Db) WriteEvent(event Event) error {
 	return err
 }
 
+func (obj Db) DeleteEvent(eventID string) error {
+	_, err := obj.dbClient.DeleteItem(obj.dbContext, &dynamodb.DeleteItemInput{
+		TableName: aws.String("Events"),
+		Key: map[string]types.AttributeValue{
+			"event_id": &types.AttributeValueMemberS{Value: eventID},
+		},
+	})
+	if err != nil {
+		obj.logger.Error().Msgf("Couldn't delete event %s: %v", eventID, err)
+	}
+	return err
+}
+
+// QueryEventByID returns nil when there is no such event
+func (obj Db) QueryEventByID(eventID string) (*Event, error) {
+	out, err := obj.dbClient.GetItem(obj.dbContext, &dynamodb.GetItemInput{
+		TableName: aws.String("Events"),
+		Key: map[string]types.AttributeValue{
+			"event_id": &types.AttributeValueMemberS{Value: eventID},
+		},
+	})
+	if err != nil {
+		obj.logger.Error().Msg(err.Error())
+		return nil, err
+	}
+	if len(out.Item) == 0 {
+		return nil, nil
+	}
+	var event Event
+	if err := attributevalue.UnmarshalMap(out.Item, &event); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// UpdateEventOverrides writes the overrides of an existing event and the
+// fields they set, leaving the rest of the item as it is
+func (obj Db) UpdateEventOverrides(event Event) error {
+	event.StartBucket = utcMonthBucket(event.Start)
+	item, err := attributevalue.MarshalMap(event)
+	if err != nil {
+		obj.logger.Error().Msgf("marshal: %s", err.Error())
+		return err
+	}
+	overrides, err := attributevalue.Marshal(event.Overrides)
+	if err != nil {
+		return err
+	}
+
+	names := map[string]string{"#overrides": "overrides"}
+	values := map[string]types.AttributeValue{":overrides": overrides}
+	assignments := []string{"#overrides = :overrides"}
+	fields := event.OverriddenFields()
+	if _, ok := event.Overrides["start"]; ok {
+		fields = append(fields, "start_bucket")
+	}
+	for i, field := range fields {
+		name, value := fmt.Sprintf("#f%d", i), fmt.Sprintf(":f%d", i)
+		names[name] = field
+		values[value] = item[field]
+		assignments = append(assignments, name+" = "+value)
+	}
+
+	_, err = obj.dbClient.UpdateItem(obj.dbContext, &dynamodb.UpdateItemInput{
+		TableName: aws.String("Events"),
+		Key: map[string]types.AttributeValue{
+			"event_id": &types.AttributeValueMemberS{Value: event.EventID},
+		},
+		UpdateExpression:          aws.String("SET " + strings.Join(assignments, ", ")),
+		ConditionExpression:       aws.String("attribute_exists(event_id)"),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+	})
+	if err != nil {
+		obj.logger.Error().Msgf("Couldn't update overrides of event %s: %v", event.EventID, err)
+	}
+	return err
+}
+
 // Query exactly one (or few) item(s) using both GSI keys: source AND source_event_id
 func (obj Db) QueryEventsBySourceAndSourceEventID(source, sourceEventID string) ([]Event, error) {
 	out, err := obj.dbClient.Query(obj.dbContext, &dynamodb.QueryInput{
@@ -253,6 +441,41 @@ func monthBuckets(from, to time.Time) []string {
 	return res
 }
 
+// QueryEventsByTicketRef returns the events selling tickets through the ticketing
+// listing ref that start between from and to
+func (obj Db) QueryEventsByTicketRef(ref string, from time.Time, to time.Time) ([]Event, error) {
+	eav := map[string]types.AttributeValue{
+		":from": &types.AttributeValueMemberS{Value: from.UTC().Format(time.RFC3339)},
+		":to":   &types.AttributeValueMemberS{Value: to.UTC().Format(time.RFC3339)},
+		":ref":  &types.AttributeValueMemberS{Value: ref},
+	}
+	var all []Event
+	for _, b := range monthBuckets(from, to) {
+		eav[":b"] = &types.AttributeValueMemberS{Value: b}
+		paginator := dynamodb.NewQueryPaginator(obj.dbClient, &dynamodb.QueryInput{
+			TableName:                 aws.String("Events"),
+			IndexName:                 aws.String("StartBucketIndex"),
+			KeyConditionExpression:    aws.String("start_bucket = :b AND #s BETWEEN :from AND :to"),
+			ExpressionAttributeNames:  map[string]string{"#s": "start"},
+			FilterExpression:          aws.String("ticket_ref = :ref"),
+			ExpressionAttributeValues: eav,
+		})
+		for paginator.HasMorePages() {
+			out, err := paginator.NextPage(obj.dbContext)
+			if err != nil {
+				obj.logger.Error().Msg(err.Error())
+				return nil, err
+			}
+			var page []Event
+			if err := attributevalue.UnmarshalListOfMaps(out.Items, &page); err != nil {
+				return nil, err
+			}
+			all = append(all, page...)
+		}
+	}
+	return all, nil
+}
+
 func (obj Db) QueryEventsByCategoryAndDate(dateFrom time.Time, dateTo time.Time, userCategory string) ([]Event, error) {
 
 	obj.logger.Info().Msgf("Querying events between %s and %s for category %s", dateFrom, dateTo, userCategory)
@@ -292,7 +515,7 @@ func (obj Db) QueryEventsByCategoryAndDate(dateFrom time.Time, dateTo time.Time,
 
 			out, err := obj.dbClient.Query(obj.dbContext, &queryInput)
 			if err != nil {
-				obj.logger.Error().Msgf(err.Error())
+				obj.logger.Error().Msg(err.Error())
 				return nil, err
 			}
 			obj.logger.Debug().Msgf("Found %d items in this page", len(out.Items))
@@ -313,7 +536,128 @@ func (obj Db) QueryEventsByCategoryAndDate(dateFrom time.Time, dateTo time.Time,
 	return all, nil
 }
 
+// EventFilter selects events by start time, source and category. Zero fields match every event.
+type EventFilter struct {
+	From     time.Time
+	To       time.Time
+	Source   string // source name
+	Category string
+}
+
+// QueryEvents returns the events matching filter. It queries by source when
+// one is given, by start month when a date range is given, and scans otherwise.
+func (obj Db) QueryEvents(filter EventFilter) ([]Event, error) {
+	eav := map[string]types.AttributeValue{}
+	names := map[string]string{}
+	var conditions []string
+	var startCondition string
+	if !filter.From.IsZero() || !filter.To.IsZero() {
+		from, to := filter.From, filter.To
+		if to.IsZero() {
+			to = time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
+		}
+		eav[":dateFrom"] = &types.AttributeValueMemberS{Value: from.UTC().Format(time.RFC3339)}
+		eav[":dateTo"] = &types.AttributeValueMemberS{Value: to.UTC().Format(time.RFC3339)}
+		names["#s"] = "start"
+		startCondition = "#s BETWEEN :dateFrom AND :dateTo"
+	}
+	if filter.Category != "" {
+		eav[":category"] = &types.AttributeValueMemberS{Value: filter.Category}
+		conditions = append(conditions, "contains(categories, :category)")
+	}
+
+	var inputs []dynamodb.QueryInput
+	switch {
+	case filter.Source != "":
+		eav[":src"] = &types.AttributeValueMemberS{Value: filter.Source}
+		if startCondition != "" {
+			conditions = append(conditions, startCondition)
+		}
+		inputs = append(inputs, dynamodb.QueryInput{
+			IndexName:              aws.String("SourceEvent"),
+			KeyConditionExpression: aws.String("source_name = :src"),
+		})
+	case startCondition != "" && !filter.From.IsZero() && !filter.To.IsZero():
+		for _, b := range monthBuckets(filter.From, filter.To) {
+			bucket := map[string]types.AttributeValue{":b": &types.AttributeValueMemberS{Value: b}}
+			for k, v := range eav {
+				bucket[k] = v
+			}
+			inputs = append(inputs, dynamodb.QueryInput{
+				IndexName:                 aws.String("StartBucketIndex"),
+				KeyConditionExpression:    aws.String("start_bucket = :b AND " + startCondition),
+				ExpressionAttributeValues: bucket,
+				ScanIndexForward:          aws.Bool(true), // earliest first
+			})
+		}
+	default:
+		// open-ended date ranges span an unknown number of buckets
+		if startCondition != "" {
+			conditions = append(conditions, startCondition)
+		}
+		return obj.scanEvents(strings.Join(conditions, " AND "), names, eav)
+	}
+
+	var all []Event
+	for _, input := range inputs {
+		input.TableName = aws.String("Events")
+		if input.ExpressionAttributeValues == nil {
+			input.ExpressionAttributeValues = eav
+		}
+		if len(names) > 0 {
+			input.ExpressionAttributeNames = names
+		}
+		if len(conditions) > 0 {
+			input.FilterExpression = aws.String(strings.Join(conditions, " AND "))
+		}
+		paginator := dynamodb.NewQueryPaginator(obj.dbClient, &input)
+		for paginator.HasMorePages() {
+			out, err := paginator.NextPage(obj.dbContext)
+			if err != nil {
+				obj.logger.Error().Msg(err.Error())
+				return nil, err
+			}
+			var page []Event
+			if err := attributevalue.UnmarshalListOfMaps(out.Items, &page); err != nil {
+				return nil, err
+			}
+			all = append(all, page...)
+		}
+	}
+	return all, nil
+}
+
+func (obj Db) scanEvents(filter string, names map[string]string, eav map[string]types.AttributeValue) ([]Event, error) {
+	input := &dynamodb.ScanInput{TableName: aws.String("Events")}
+	if filter != "" {
+		input.FilterExpression = aws.String(filter)
+		input.ExpressionAttributeValues = eav
+	}
+	if len(names) > 0 {
+		input.ExpressionAttributeNames = names
+	}
+	var all []Event
+	paginator := dynamodb.NewScanPaginator(obj.dbClient, input)
+	for paginator.HasMorePages() {
+		out, err := paginator.NextPage(obj.dbContext)
+		if err != nil {
+			obj.logger.Error().Msg(err.Error())
+			return nil, err
+		}
+		var page []Event
+		if err := attributevalue.UnmarshalListOfMaps(out.Items, &page); err != nil {
+			return nil, err
+		}
+		all = append(all, page...)
+	}
+	return all, nil
+}
+
+// UpdateEventTags writes the tagging of an event. Overridden fields keep their editorial values.
 func (obj Db) UpdateEventTags(event Event) (Event, error) {
+	if err := event.ApplyOverrides(); err != nil {
+		obj.logger.Warn().Msgf("Event %s: %v", event.EventID, err)
+	}
 	var tagAttributeValues = make([]types.AttributeValue, len(event.Tags))
 	for i, tag := range event.Tags {
 		tagAttributeValues[i] = &types.AttributeValueMemberS{Value: tag}
@@ -329,12 +673,21 @@ func (obj Db) UpdateEventTags(event Event) (Event, error) {
 		categoriesValue[i] = &types.AttributeValueMemberS{Value: tag}
 	}
 
+	attributes, err := attributevalue.Marshal(event.Attributes)
+	if err != nil {
+		return event, err
+	}
+	inferred, err := attributevalue.Marshal(event.Inferred)
+	if err != nil {
+		return event, err
+	}
+
 	response, err := obj.dbClient.UpdateItem(obj.dbContext, &dynamodb.UpdateItemInput{
 		TableName: aws.String("Events"),
 		Key: map[string]types.AttributeValue{
 			"event_id": &types.AttributeValueMemberS{Value: event.EventID},
 		},
-		UpdateExpression: aws.String("SET caption = :caption, tags = :tags, extra_tags = :extra_tags, categories = :categories, tagged = :true"),
+		UpdateExpression: aws.String("SET caption = :caption, tags = :tags, extra_tags = :extra_tags, categories = :categories, attributes = :attributes, inferred_attributes = :inferred, tagged = :true"),
 		/*		ExpressionAttributeNames: map[string]string{
 					"caption":    event.Caption,   // avoid reserved word
 					"tags":       event.Tags,      // avoid reserved word
@@ -351,7 +704,9 @@ func (obj Db) UpdateEventTags(event Event) (Event, error) {
 			":categories": &types.AttributeValueMemberL{
 				Value: categoriesValue,
 			},
-			":true": &types.AttributeValueMemberBOOL{Value: true},
+			":attributes": attributes,
+			":inferred":   inferred,
+			":true":       &types.AttributeValueMemberBOOL{Value: true},
 		},
 		ReturnValues: types.ReturnValueUpdatedNew,
 	})
@@ -372,7 +727,7 @@ func (obj Db) UpdateEventTags(event Event) (Event, error) {
 func (obj Db) WriteUser(user User) error {
 	av, err := attributevalue.MarshalMap(user)
 	if err != nil {
-		obj.logger.Error().Msgf(err.Error())
+		obj.logger.Error().Msg(err.Error())
 		return err
 	}
 
@@ -392,7 +747,7 @@ func (obj Db) QueryUserByUserID(userID string) (*User, error) {
 		ConsistentRead: aws.Bool(true),
 	})
 	if err != nil {
-		obj.logger.Error().Msgf(err.Error())
+		obj.logger.Error().Msg(err.Error())
 		return nil, err
 	}
 	var user User
@@ -464,6 +819,345 @@ func (obj Db) PurgeOldEvents(cutoff time.Time) error {
 	return nil
 }
 
+func (obj Db) WriteSource(source Source) error {
+	av, err := attributevalue.MarshalMap(source)
+	if err != nil {
+		obj.logger.Error().Msgf("marshal: %s", err.Error())
+		return err
+	}
+
+	_, err = obj.dbClient.PutItem(obj.dbContext, &dynamodb.PutItemInput{
+		TableName: aws.String("Sources"),
+		Item:      av,
+	})
+	return err
+}
+
+// QuerySourceBySourceID returns nil when the source has no entry
+func (obj Db) QuerySourceBySourceID(sourceID string) (*Source, error) {
+	out, err := obj.dbClient.GetItem(obj.dbContext, &dynamodb.GetItemInput{
+		TableName: aws.String("Sources"),
+		Key: map[string]types.AttributeValue{
+			"source_id": &types.AttributeValueMemberS{Value: sourceID},
+		},
+	})
+	if err != nil {
+		obj.logger.Error().Msg(err.Error())
+		return nil, err
+	}
+	if len(out.Item) == 0 {
+		return nil, nil
+	}
+	var source Source
+	if err := attributevalue.UnmarshalMap(out.Item, &source); err != nil {
+		return nil, err
+	}
+	return &source, nil
+}
+
+// UpdateSourceCheckpoint replaces the checkpoint of an existing source
+func (obj Db) UpdateSourceCheckpoint(sourceID string, checkpoint SourceCheckpoint) error {
+	av, err := attributevalue.Marshal(checkpoint)
+	if err != nil {
+		obj.logger.Error().Msgf("marshal: %s", err.Error())
+		return err
+	}
+
+	_, err = obj.dbClient.UpdateItem(obj.dbContext, &dynamodb.UpdateItemInput{
+		TableName: aws.String("Sources"),
+		Key: map[string]types.AttributeValue{
+			"source_id": &types.AttributeValueMemberS{Value: sourceID},
+		},
+		UpdateExpression:          aws.String("SET #checkpoint = :checkpoint"),
+		ConditionExpression:       aws.String("attribute_exists(source_id)"),
+		ExpressionAttributeNames:  map[string]string{"#checkpoint": "checkpoint"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{":checkpoint": av},
+	})
+	if err != nil {
+		obj.logger.Error().Msgf("Couldn't update checkpoint of source %s: %v", sourceID, err)
+	}
+	return err
+}
+
+// UpdateSourceAPIKeyHash replaces the API key of an existing source
+func (obj Db) UpdateSourceAPIKeyHash(sourceID string, hash string) error {
+	_, err := obj.dbClient.UpdateItem(obj.dbContext, &dynamodb.UpdateItemInput{
+		TableName: aws.String("Sources"),
+		Key: map[string]types.AttributeValue{
+			"source_id": &types.AttributeValueMemberS{Value: sourceID},
+		},
+		UpdateExpression:          aws.String("SET #hash = :hash"),
+		ConditionExpression:       aws.String("attribute_exists(source_id)"),
+		ExpressionAttributeNames:  map[string]string{"#hash": "api_key_hash"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{":hash": &types.AttributeValueMemberS{Value: hash}},
+	})
+	if err != nil {
+		obj.logger.Error().Msgf("Couldn't update API key of source %s: %v", sourceID, err)
+	}
+	return err
+}
+
+// QuerySources returns every source, active or not
+func (obj Db) QuerySources() ([]Source, error) {
+	var all []Source
+	paginator := dynamodb.NewScanPaginator(obj.dbClient, &dynamodb.ScanInput{TableName: aws.String("Sources")})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(obj.dbContext)
+		if err != nil {
+			obj.logger.Error().Msgf("scan failed: %s", err.Error())
+			return nil, err
+		}
+		var sources []Source
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &sources); err != nil {
+			return nil, err
+		}
+		all = append(all, sources...)
+	}
+	return all, nil
+}
+
+func (obj Db) QueryActiveSources() ([]Source, error) {
+	scanInput := &dynamodb.ScanInput{
+		TableName:        aws.String("Sources"),
+		FilterExpression: aws.String("active = :true"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":true": &types.AttributeValueMemberBOOL{Value: true},
+		},
+	}
+
+	var all []Source
+	paginator := dynamodb.NewScanPaginator(obj.dbClient, scanInput)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(obj.dbContext)
+		if err != nil {
+			obj.logger.Error().Msgf("scan failed: %s", err.Error())
+			return nil, err
+		}
+		var sources []Source
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &sources); err != nil {
+			return nil, err
+		}
+		all = append(all, sources...)
+	}
+	return all, nil
+}
+
+func (obj Db) WriteScrapeRun(run ScrapeRun) error {
+	run.StartedAt = run.StartedAt.UTC()
+	run.FinishedAt = run.FinishedAt.UTC()
+	av, err := attributevalue.MarshalMap(run)
+	if err != nil {
+		obj.logger.Error().Msgf("marshal: %s", err.Error())
+		return err
+	}
+
+	_, err = obj.dbClient.PutItem(obj.dbContext, &dynamodb.PutItemInput{
+		TableName: aws.String("ScrapeRuns"),
+		Item:      av,
+	})
+	return err
+}
+
+// QueryRecentScrapeRuns returns up to limit runs of a source, newest first
+func (obj Db) QueryRecentScrapeRuns(sourceID string, limit int) ([]ScrapeRun, error) {
+	out, err := obj.dbClient.Query(obj.dbContext, &dynamodb.QueryInput{
+		TableName:              aws.String("ScrapeRuns"),
+		KeyConditionExpression: aws.String("source_id = :source_id"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":source_id": &types.AttributeValueMemberS{Value: sourceID},
+		},
+		ScanIndexForward: aws.Bool(false),
+		Limit:            aws.Int32(int32(limit)),
+	})
+	if err != nil {
+		obj.logger.Error().Msgf("query failed: %s", err.Error())
+		return nil, err
+	}
+
+	var runs []ScrapeRun
+	if err := attributevalue.UnmarshalListOfMaps(out.Items, &runs); err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+func (obj Db) WriteQuarantinedEvent(event QuarantinedEvent) error {
+	event.QuarantinedAt = event.QuarantinedAt.UTC()
+	av, err := attributevalue.MarshalMap(event)
+	if err != nil {
+		obj.logger.Error().Msgf("marshal: %s", err.Error())
+		return err
+	}
+
+	_, err = obj.dbClient.PutItem(obj.dbContext, &dynamodb.PutItemInput{
+		TableName: aws.String("Quarantine"),
+		Item:      av,
+	})
+	return err
+}
+
+// QueryQuarantinedEvent returns nil when there is no such entry
+func (obj Db) QueryQuarantinedEvent(quarantineID string) (*QuarantinedEvent, error) {
+	out, err := obj.dbClient.GetItem(obj.dbContext, &dynamodb.GetItemInput{
+		TableName: aws.String("Quarantine"),
+		Key: map[string]types.AttributeValue{
+			"quarantine_id": &types.AttributeValueMemberS{Value: quarantineID},
+		},
+	})
+	if err != nil {
+		obj.logger.Error().Msg(err.Error())
+		return nil, err
+	}
+	if len(out.Item) == 0 {
+		return nil, nil
+	}
+	var event QuarantinedEvent
+	if err := attributevalue.UnmarshalMap(out.Item, &event); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// QueryQuarantinedEvents lists the quarantine, for one source or all when sourceName is empty
+func (obj Db) QueryQuarantinedEvents(sourceName string) ([]QuarantinedEvent, error) {
+	scanInput := &dynamodb.ScanInput{
+		TableName: aws.String("Quarantine"),
+	}
+	if sourceName != "" {
+		scanInput.FilterExpression = aws.String("source_name = :source_name")
+		scanInput.ExpressionAttributeValues = map[string]types.AttributeValue{
+			":source_name": &types.AttributeValueMemberS{Value: sourceName},
+		}
+	}
+
+	var all []QuarantinedEvent
+	paginator := dynamodb.NewScanPaginator(obj.dbClient, scanInput)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(obj.dbContext)
+		if err != nil {
+			obj.logger.Error().Msgf("scan failed: %s", err.Error())
+			return nil, err
+		}
+		var events []QuarantinedEvent
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &events); err != nil {
+			return nil, err
+		}
+		all = append(all, events...)
+	}
+	return all, nil
+}
+
+func (obj Db) DeleteQuarantinedEvent(quarantineID string) error {
+	_, err := obj.dbClient.DeleteItem(obj.dbContext, &dynamodb.DeleteItemInput{
+		TableName: aws.String("Quarantine"),
+		Key: map[string]types.AttributeValue{
+			"quarantine_id": &types.AttributeValueMemberS{Value: quarantineID},
+		},
+	})
+	if err != nil {
+		obj.logger.Error().Msgf("Couldn't delete quarantined event %s: %v", quarantineID, err)
+	}
+	return err
+}
+
+// WriteSubmission stores a new submission
+func (obj Db) WriteSubmission(submission Submission) error {
+	av, err := attributevalue.MarshalMap(submission)
+	if err != nil {
+		obj.logger.Error().Msgf("marshal: %s", err.Error())
+		return err
+	}
+
+	_, err = obj.dbClient.PutItem(obj.dbContext, &dynamodb.PutItemInput{
+		TableName:           aws.String("Submissions"),
+		Item:                av,
+		ConditionExpression: aws.String("attribute_not_exists(submission_id)"),
+	})
+	return err
+}
+
+// UpdateSubmission replaces a submission that still has status from, so two
+// editors reviewing the same submission cannot both go through
+func (obj Db) UpdateSubmission(submission Submission, from SubmissionStatus) error {
+	av, err := attributevalue.MarshalMap(submission)
+	if err != nil {
+		obj.logger.Error().Msgf("marshal: %s", err.Error())
+		return err
+	}
+
+	_, err = obj.dbClient.PutItem(obj.dbContext, &dynamodb.PutItemInput{
+		TableName:                 aws.String("Submissions"),
+		Item:                      av,
+		ConditionExpression:       aws.String("#status = :from"),
+		ExpressionAttributeNames:  map[string]string{"#status": "status"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{":from": &types.AttributeValueMemberS{Value: string(from)}},
+	})
+	if err != nil {
+		obj.logger.Error().Msgf("Couldn't update submission %s: %v", submission.SubmissionID, err)
+	}
+	return err
+}
+
+// QuerySubmission returns nil when there is no such submission
+func (obj Db) QuerySubmission(submissionID string) (*Submission, error) {
+	out, err := obj.dbClient.GetItem(obj.dbContext, &dynamodb.GetItemInput{
+		TableName: aws.String("Submissions"),
+		Key: map[string]types.AttributeValue{
+			"submission_id": &types.AttributeValueMemberS{Value: submissionID},
+		},
+	})
+	if err != nil {
+		obj.logger.Error().Msg(err.Error())
+		return nil, err
+	}
+	if len(out.Item) == 0 {
+		return nil, nil
+	}
+	var submission Submission
+	if err := attributevalue.UnmarshalMap(out.Item, &submission); err != nil {
+		return nil, err
+	}
+	return &submission, nil
+}
+
+// QuerySubmissions lists submissions with status, of userID, or all when either is empty
+func (obj Db) QuerySubmissions(status SubmissionStatus, userID string) ([]Submission, error) {
+	scanInput := &dynamodb.ScanInput{
+		TableName: aws.String("Submissions"),
+	}
+	var conditions []string
+	eav := map[string]types.AttributeValue{}
+	if status != "" {
+		conditions = append(conditions, "#status = :status")
+		eav[":status"] = &types.AttributeValueMemberS{Value: string(status)}
+		scanInput.ExpressionAttributeNames = map[string]string{"#status": "status"}
+	}
+	if userID != "" {
+		conditions = append(conditions, "user_id = :user_id")
+		eav[":user_id"] = &types.AttributeValueMemberS{Value: userID}
+	}
+	if len(conditions) > 0 {
+		scanInput.FilterExpression = aws.String(strings.Join(conditions, " AND "))
+		scanInput.ExpressionAttributeValues = eav
+	}
+
+	var all []Submission
+	paginator := dynamodb.NewScanPaginator(obj.dbClient, scanInput)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(obj.dbContext)
+		if err != nil {
+			obj.logger.Error().Msgf("scan failed: %s", err.Error())
+			return nil, err
+		}
+		var submissions []Submission
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &submissions); err != nil {
+			return nil, err
+		}
+		all = append(all, submissions...)
+	}
+	return all, nil
+}
+
 func (obj Db) CreateEventsTable() error {
 	const (
 		tableName      = "Events"
@@ -671,3 +1365,129 @@ func (obj Db) CreateSourcesTable() error {
 
 	return nil
 }
+
+func (obj Db) CreateScrapeRunsTable() error {
+	const (
+		tableName = "ScrapeRuns"
+	)
+
+	// Check if table exists
+	_, err := obj.dbClient.DescribeTable(obj.dbContext, &dynamodb.DescribeTableInput{
+		TableName: aws.String(tableName),
+	})
+	if err == nil {
+		obj.logger.Info().Msgf("Table %q already exists. Skipping creation.", tableName)
+		return nil
+	}
+
+	// Define table with:
+	// - PK: source_id (S)
+	// - SK: started_at (S) — RFC3339 in UTC, so runs sort chronologically
+	input := &dynamodb.CreateTableInput{
+		TableName: aws.String(tableName),
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String("source_id"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("started_at"), AttributeType: types.ScalarAttributeTypeS},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String("source_id"), KeyType: types.KeyTypeHash},
+			{AttributeName: aws.String("started_at"), KeyType: types.KeyTypeRange},
+		},
+		BillingMode: types.BillingModePayPerRequest, // on-demand: no capacity planning
+	}
+
+	obj.logger.Info().Msgf("Creating table %q ...", tableName)
+	if _, err := obj.dbClient.CreateTable(obj.dbContext, input); err != nil {
+		return fmt.Errorf("CreateTable: %w", err)
+	}
+
+	// Wait for ACTIVE
+	waiter := dynamodb.NewTableExistsWaiter(obj.dbClient)
+	if err := waiter.Wait(obj.dbContext, &dynamodb.DescribeTableInput{TableName: aws.String(tableName)}, 5*time.Minute); err != nil {
+		return fmt.Errorf("waiting for table ACTIVE: %w", err)
+	}
+
+	return nil
+}
+
+func (obj Db) CreateQuarantineTable() error {
+	const (
+		tableName = "Quarantine"
+	)
+
+	// Check if table exists
+	_, err := obj.dbClient.DescribeTable(obj.dbContext, &dynamodb.DescribeTableInput{
+		TableName: aws.String(tableName),
+	})
+	if err == nil {
+		obj.logger.Info().Msgf("Table %q already exists. Skipping creation.", tableName)
+		return nil
+	}
+
+	// Define table with:
+	// - PK: quarantine_id (S) — source_name#source_event_id
+	input := &dynamodb.CreateTableInput{
+		TableName: aws.String(tableName),
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String("quarantine_id"), AttributeType: types.ScalarAttributeTypeS},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String("quarantine_id"), KeyType: types.KeyTypeHash},
+		},
+		BillingMode: types.BillingModePayPerRequest, // on-demand: no capacity planning
+	}
+
+	obj.logger.Info().Msgf("Creating table %q ...", tableName)
+	if _, err := obj.dbClient.CreateTable(obj.dbContext, input); err != nil {
+		return fmt.Errorf("CreateTable: %w", err)
+	}
+
+	// Wait for ACTIVE
+	waiter := dynamodb.NewTableExistsWaiter(obj.dbClient)
+	if err := waiter.Wait(obj.dbContext, &dynamodb.DescribeTableInput{TableName: aws.String(tableName)}, 5*time.Minute); err != nil {
+		return fmt.Errorf("waiting for table ACTIVE: %w", err)
+	}
+
+	return nil
+}
+
+func (obj Db) CreateSubmissionsTable() error {
+	const (
+		tableName = "Submissions"
+	)
+
+	// Check if table exists
+	_, err := obj.dbClient.DescribeTable(obj.dbContext, &dynamodb.DescribeTableInput{
+		TableName: aws.String(tableName),
+	})
+	if err == nil {
+		obj.logger.Info().Msgf("Table %q already exists. Skipping creation.", tableName)
+		return nil
+	}
+
+	// Define table with:
+	// - PK: submission_id (S)
+	input := &dynamodb.CreateTableInput{
+		TableName: aws.String(tableName),
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String("submission_id"), AttributeType: types.ScalarAttributeTypeS},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String("submission_id"), KeyType: types.KeyTypeHash},
+		},
+		BillingMode: types.BillingModePayPerRequest, // on-demand: no capacity planning
+	}
+
+	obj.logger.Info().Msgf("Creating table %q ...", tableName)
+	if _, err := obj.dbClient.CreateTable(obj.dbContext, input); err != nil {
+		return fmt.Errorf("CreateTable: %w", err)
+	}
+
+	// Wait for ACTIVE
+	waiter := dynamodb.NewTableExistsWaiter(obj.dbClient)
+	if err := waiter.Wait(obj.dbContext, &dynamodb.DescribeTableInput{TableName: aws.String(tableName)}, 5*time.Minute); err != nil {
+		return fmt.Errorf("waiting for table ACTIVE: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,159 @@
+package common
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+//////// Pushed events /////////
+
+// IngestEvent is an event pushed from outside: written by an external scraper,
+// or posted to the ingestion API by a venue or promoter. Its JSON schema is
+// the one published by the API.
+type IngestEvent struct {
+	SourceEvent  string         `json:"source_event" jsonschema:"minLength=1,description=Stable ID of the event within the source"`
+	Title        string         `json:"title" jsonschema:"minLength=1"`
+	Description  string         `json:"description,omitempty"`
+	Start        time.Time      `json:"start" jsonschema:"description=RFC3339 start time"`
+	End          time.Time      `json:"end,omitempty,omitzero"`
+	VenueName    string         `json:"venue_name,omitempty"`
+	Address      *IngestAddress `json:"address,omitempty"`
+	Geo          *IngestGeo     `json:"geo,omitempty" jsonschema:"description=Venue location; geocoded from the address when left out"`
+	URL          string         `json:"url,omitempty" jsonschema:"format=uri"`
+	TicketURL    string         `json:"ticket_url,omitempty" jsonschema:"format=uri"`
+	PriceMin     float64        `json:"price_min,omitempty" jsonschema:"minimum=0"`
+	PriceMax     float64        `json:"price_max,omitempty" jsonschema:"minimum=0"`
+	Images       []string       `json:"images,omitempty"`
+	Categories   []string       `json:"categories,omitempty"`
+	Tags         []string       `json:"tags,omitempty"`
+	EighteenPlus bool           `json:"eighteen_plus,omitempty"`
+	SexPositive  bool           `json:"sex_positive,omitempty"`
+	Attributes   *Attributes    `json:"attributes,omitempty"`
+}
+
+type IngestAddress struct {
+	Line1    string `json:"line1,omitempty"`
+	Line2    string `json:"line2,omitempty"`
+	PostCode string `json:"postcode,omitempty"`
+	Locality string `json:"locality,omitempty"`
+	Region   string `json:"region,omitempty"`
+	Country  string `json:"country,omitempty"`
+}
+
+type IngestGeo struct {
+	Lat float64 `json:"lat" jsonschema:"minimum=-90,maximum=90"`
+	Lng float64 `json:"lng" jsonschema:"minimum=-180,maximum=180"`
+}
+
+// IngestCommand is the scraper command that runs pushed events through the pipeline
+const IngestCommand = "ingest"
+
+// IngestMessage is the scraper command queued by the ingestion API
+type IngestMessage struct {
+	Name   string        `json:"name"`  // IngestCommand
+	Venue  string        `json:"venue"` // source ID
+	Events []IngestEvent `json:"events"`
+}
+
+// Check reports the fields an event cannot go without, and values it cannot have
+func (obj IngestEvent) Check() error {
+	var missing []string
+	if obj.SourceEvent == "" {
+		missing = append(missing, "source_event")
+	}
+	if obj.Title == "" {
+		missing = append(missing, "title")
+	}
+	if obj.Start.IsZero() {
+		missing = append(missing, "start")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing %s", strings.Join(missing, ", "))
+	}
+	if obj.Attributes != nil {
+		return CheckSeating(obj.Attributes.Seating)
+	}
+	return nil
+}
+
+// Event converts obj into an event of sourceName, without an event ID
+func (obj IngestEvent) Event(sourceName string) Event {
+	result := Event{
+		Source_name:  sourceName,
+		SourceEvent:  obj.SourceEvent,
+		Title:        obj.Title,
+		Description:  obj.Description,
+		Start:        obj.Start.UTC(),
+		VenueName:    obj.VenueName,
+		URL:          obj.URL,
+		TicketURL:    obj.TicketURL,
+		PriceMin:     obj.PriceMin,
+		PriceMax:     obj.PriceMax,
+		Images:       obj.Images,
+		Categories:   obj.Categories,
+		Tags:         obj.Tags,
+		ContentFlags: ContentFlags{EighteenPlus: obj.EighteenPlus, SexPositive: obj.SexPositive},
+		FetchedAt:    time.Now(),
+	}
+	if !obj.End.IsZero() {
+		result.End = obj.End.UTC()
+	}
+	if obj.Address != nil {
+		result.Address = Address(*obj.Address)
+	}
+	if obj.Geo != nil {
+		result.Geo = Geo{Lat: obj.Geo.Lat, Lng: obj.Geo.Lng}
+	}
+	if obj.Attributes != nil {
+		result.Attributes = *obj.Attributes
+	}
+	return result
+}
+
+// Pushed reports whether the events of source are sent to us, through the
+// ingestion API or as user submissions, rather than scraped
+func (obj Source) Pushed() bool {
+	return obj.SourceType == Push || obj.SourceType == UserSource
+}
+
+//////// API keys /////////
+
+// NewAPIKey returns a random key for sourceID, and the hash to store in its
+// Sources entry. The key itself is only ever shown once.
+func NewAPIKey(sourceID string) (string, string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+	key := sourceID + "." + base64.RawURLEncoding.EncodeToString(secret)
+	return key, HashAPIKey(key), nil
+}
+
+// HashAPIKey hashes a key for storage. Keys are random, so a plain SHA-256 will do.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// APIKeySourceID returns the source a key was issued for, empty when key is malformed
+func APIKeySourceID(key string) string {
+	i := strings.LastIndex(key, ".")
+	if i <= 0 || i == len(key)-1 {
+		return ""
+	}
+	return key[:i]
+}
+
+// APIKeyMatches reports whether key is the one source was issued
+func APIKeyMatches(source Source, key string) bool {
+	if source.APIKeyHash == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(HashAPIKey(key)), []byte(source.APIKeyHash)) == 1
+}
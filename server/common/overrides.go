@@ -0,0 +1,133 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+//////// Editorial overrides /////////
+
+// Override is an editor's value for one field of an event. It wins over what
+// the source says, on every scrape and tagging run, until it is cleared.
+type Override struct {
+	Value     string    `dynamodbav:"value" json:"value"` // JSON of the field value
+	Author    string    `dynamodbav:"author" json:"author"`
+	UpdatedAt time.Time `dynamodbav:"updated_at" json:"updated_at"`
+}
+
+// OverridableFields are the event fields editors can override, by attribute name
+var OverridableFields = []string{
+	"title", "description", "caption", "start", "end", "venue_name", "address", "geo",
+	"url", "ticket_url", "price_min", "price_max", "images", "categories", "tags",
+	"extra_tags", "content_flags", "attributes",
+}
+
+// ErrNotOverridable is returned for fields that cannot be overridden
+var ErrNotOverridable = errors.New("field cannot be overridden")
+
+// SetOverride overrides field with value, a JSON value of the field's type, and applies it
+func (obj *Event) SetOverride(field string, value json.RawMessage, author string, at time.Time) error {
+	if err := obj.setField(field, string(value)); err != nil {
+		return err
+	}
+	if obj.Overrides == nil {
+		obj.Overrides = map[string]Override{}
+	}
+	obj.Overrides[field] = Override{Value: string(value), Author: author, UpdatedAt: at.UTC()}
+	return nil
+}
+
+// ClearOverride drops the override of field. The field keeps the overridden
+// value until the source next changes it.
+func (obj *Event) ClearOverride(field string) error {
+	if _, ok := obj.Overrides[field]; !ok {
+		return fmt.Errorf("%s is not overridden", field)
+	}
+	delete(obj.Overrides, field)
+	return nil
+}
+
+// ApplyOverrides sets the overridden fields to their editorial values
+func (obj *Event) ApplyOverrides() error {
+	var errs []error
+	for _, field := range obj.OverriddenFields() {
+		if err := obj.setField(field, obj.Overrides[field].Value); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// OverriddenFields returns the overridden fields, sorted
+func (obj Event) OverriddenFields() []string {
+	fields := make([]string, 0, len(obj.Overrides))
+	for field := range obj.Overrides {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func (obj *Event) setField(field string, value string) error {
+	var err error
+	switch field {
+	case "title":
+		err = setJSON(&obj.Title, value)
+	case "description":
+		err = setJSON(&obj.Description, value)
+	case "caption":
+		err = setJSON(&obj.Caption, value)
+	case "start":
+		err = setJSON(&obj.Start, value)
+	case "end":
+		err = setJSON(&obj.End, value)
+	case "venue_name":
+		err = setJSON(&obj.VenueName, value)
+	case "address":
+		err = setJSON(&obj.Address, value)
+	case "geo":
+		err = setJSON(&obj.Geo, value)
+	case "url":
+		err = setJSON(&obj.URL, value)
+	case "ticket_url":
+		err = setJSON(&obj.TicketURL, value)
+	case "price_min":
+		err = setJSON(&obj.PriceMin, value)
+	case "price_max":
+		err = setJSON(&obj.PriceMax, value)
+	case "images":
+		err = setJSON(&obj.Images, value)
+	case "categories":
+		err = setJSON(&obj.Categories, value)
+	case "tags":
+		err = setJSON(&obj.Tags, value)
+	case "extra_tags":
+		err = setJSON(&obj.ExtraTags, value)
+	case "content_flags":
+		err = setJSON(&obj.ContentFlags, value)
+	case "attributes":
+		if err = setJSON(&obj.Attributes, value); err == nil {
+			err = CheckSeating(obj.Attributes.Seating)
+		}
+	default:
+		return fmt.Errorf("%w: %q, expected one of %s", ErrNotOverridable, field, strings.Join(OverridableFields, ", "))
+	}
+	if err != nil {
+		return fmt.Errorf("override of %s: %w", field, err)
+	}
+	return nil
+}
+
+// setJSON replaces *target with value, leaving it untouched when value does not parse
+func setJSON[T any](target *T, value string) error {
+	var parsed T
+	if err := json.Unmarshal([]byte(value), &parsed); err != nil {
+		return err
+	}
+	*target = parsed
+	return nil
+}
@@ -0,0 +1,128 @@
+package common
+
+import (
+	"encoding/json"
+	"github.com/rs/zerolog"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// updateRequest is the part of a DynamoDB UpdateItem request the tests look at
+type updateRequest struct {
+	UpdateExpression          string
+	ExpressionAttributeNames  map[string]string
+	ExpressionAttributeValues map[string]json.RawMessage
+}
+
+// value returns the JSON of the value the request sets attribute to, empty when it is not set
+func (obj updateRequest) value(attribute string) string {
+	for _, assignment := range strings.Split(strings.TrimPrefix(obj.UpdateExpression, "SET "), ", ") {
+		name, value, _ := strings.Cut(assignment, " = ")
+		if name == attribute || obj.ExpressionAttributeNames[name] == attribute {
+			return string(obj.ExpressionAttributeValues[value])
+		}
+	}
+	return ""
+}
+
+// fakeDynamoDB returns a Db whose UpdateItem requests are recorded and succeed
+func fakeDynamoDB(t *testing.T) (Db, *[]updateRequest) {
+	var requests []updateRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if strings.HasSuffix(r.Header.Get("X-Amz-Target"), ".UpdateItem") {
+			var request updateRequest
+			if err := json.Unmarshal(body, &request); err != nil {
+				t.Error(err)
+			}
+			requests = append(requests, request)
+		}
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		w.Write([]byte("{}"))
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+
+	db, err := NewDb(server.URL, "ap-southeast-2", zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, &requests
+}
+
+func TestSetOverride(t *testing.T) {
+	event := Event{EventID: "e1", Title: "Scraped", PriceMin: 20}
+	at := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := event.SetOverride("title", json.RawMessage(`"Edited"`), "editor", at); err != nil {
+		t.Fatal(err)
+	}
+	if err := event.SetOverride("price_min", json.RawMessage(`"free"`), "editor", at); err == nil {
+		t.Error("override of a number with a string was accepted")
+	}
+	if err := event.SetOverride("event_id", json.RawMessage(`"e2"`), "editor", at); err == nil {
+		t.Error("override of event_id was accepted")
+	}
+	if event.Title != "Edited" || event.PriceMin != 20 || event.EventID != "e1" {
+		t.Errorf("got title %q, price %v, ID %s, want only the title changed", event.Title, event.PriceMin, event.EventID)
+	}
+	if fields := event.OverriddenFields(); len(fields) != 1 || fields[0] != "title" {
+		t.Errorf("overridden %v, want title", fields)
+	}
+
+	event.Title = "Scraped again"
+	if err := event.ApplyOverrides(); err != nil || event.Title != "Edited" {
+		t.Errorf("after ApplyOverrides title %q, error %v", event.Title, err)
+	}
+	if err := event.ClearOverride("title"); err != nil || len(event.Overrides) != 0 {
+		t.Errorf("ClearOverride left %v, error %v", event.Overrides, err)
+	}
+}
+
+func TestUpdateEventOverridesStartBucket(t *testing.T) {
+	db, requests := fakeDynamoDB(t)
+	event := Event{EventID: "e1", Title: "Show", Start: time.Date(2030, 1, 31, 20, 0, 0, 0, time.UTC)}
+	// 9am on 1 March in Sydney is still February in UTC
+	start := json.RawMessage(`"2030-03-01T09:00:00+11:00"`)
+	if err := event.SetOverride("start", start, "editor", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.UpdateEventOverrides(event); err != nil {
+		t.Fatal(err)
+	}
+	if len(*requests) != 1 {
+		t.Fatalf("%d UpdateItem requests, want 1", len(*requests))
+	}
+	request := (*requests)[0]
+	if got := request.value("start_bucket"); got != `{"S":"2030-02"}` {
+		t.Errorf("start_bucket set to %s, want 2030-02", got)
+	}
+	if got := request.value("start"); got == "" {
+		t.Error("start not set")
+	}
+	if got := request.value("title"); got != "" {
+		t.Errorf("title, which is not overridden, set to %s", got)
+	}
+
+	// other overrides leave the bucket alone
+	event = Event{EventID: "e2", Start: event.Start}
+	if err := event.SetOverride("title", json.RawMessage(`"Edited"`), "editor", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.UpdateEventOverrides(event); err != nil {
+		t.Fatal(err)
+	}
+	request = (*requests)[1]
+	if got := request.value("start_bucket"); got != "" {
+		t.Errorf("start_bucket set to %s without a start override", got)
+	}
+	if got := request.value("title"); got != `{"S":"Edited"}` {
+		t.Errorf("title set to %s, want Edited", got)
+	}
+}
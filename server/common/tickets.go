@@ -0,0 +1,70 @@
+package common
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+//////// Ticketing links /////////
+
+var (
+	moshtixEventID    = regexp.MustCompile(`^\d+$`)
+	eventbriteEventID = regexp.MustCompile(`(?:^|-)(\d{6,})$`)
+	ticketekShowCode  = regexp.MustCompile(`^[A-Za-z0-9]{4,}$`)
+	oztixEventID      = regexp.MustCompile(`^[0-9a-fA-F-]{8,}$`)
+)
+
+// TicketRef names the ticketing system listing behind link, as "moshtix:170001",
+// so the events of sources selling the same tickets can be linked. It is empty
+// for links that are not Moshtix, Ticketek, Eventbrite, Oztix or Humanitix event pages.
+func TicketRef(link string) string {
+	parsed, err := url.Parse(strings.TrimSpace(link))
+	if err != nil {
+		return ""
+	}
+	host := strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www.")
+	segments := strings.FieldsFunc(parsed.Path, func(r rune) bool { return r == '/' })
+
+	switch {
+	case host == "moshtix.com.au" || strings.HasSuffix(host, ".moshtix.com.au"):
+		// /v2/event/<slug>/<id>
+		for i, segment := range segments {
+			if segment == "event" && len(segments) > i+2 && moshtixEventID.MatchString(segments[i+2]) {
+				return "moshtix:" + segments[i+2]
+			}
+		}
+	case host == "ticketek.com.au" || strings.HasSuffix(host, ".ticketek.com.au"):
+		// /shows/show.aspx?sh=<code> or /events/<code>/venues/...
+		if code := parsed.Query().Get("sh"); ticketekShowCode.MatchString(code) {
+			return "ticketek:" + strings.ToUpper(code)
+		}
+		for i, segment := range segments {
+			if (segment == "events" || segment == "shows") && len(segments) > i+1 && ticketekShowCode.MatchString(segments[i+1]) {
+				return "ticketek:" + strings.ToUpper(segments[i+1])
+			}
+		}
+	case strings.HasPrefix(host, "eventbrite.") || strings.Contains(host, ".eventbrite."):
+		// /e/<slug>-<id>
+		for i, segment := range segments {
+			if segment == "e" && len(segments) > i+1 {
+				if match := eventbriteEventID.FindStringSubmatch(segments[i+1]); match != nil {
+					return "eventbrite:" + match[1]
+				}
+			}
+		}
+	case host == "oztix.com.au" || strings.HasSuffix(host, ".oztix.com.au"):
+		// /outlet/event/<uuid>
+		for i, segment := range segments {
+			if segment == "event" && len(segments) > i+1 && oztixEventID.MatchString(segments[i+1]) {
+				return "oztix:" + strings.ToLower(segments[i+1])
+			}
+		}
+	case host == "events.humanitix.com":
+		// /<slug>, and /<slug>/tickets
+		if len(segments) > 0 {
+			return "humanitix:" + strings.ToLower(segments[0])
+		}
+	}
+	return ""
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"os"
+	"scraper/internal/fixtures"
+	"time"
+)
+
+// Replays recorded scraper traffic and checks the produced events against the
+// golden files. Run from server/scraper:
+//
+//	go run ./cmd/fixtures                      verify every recorded source (go test runs this too)
+//	go run ./cmd/fixtures -fixture metrotheatre -update
+//	go run ./cmd/fixtures -fixture moshtix -record
+func main() {
+	var dir, name string
+	var record, update, verbose bool
+	flag.StringVar(&dir, "dir", "testdata/fixtures", "Fixture root directory")
+	flag.StringVar(&name, "fixture", "", "Fixture to process (default: every recorded fixture)")
+	flag.BoolVar(&record, "record", false, "Re-record the fixture from the live site (requires -fixture)")
+	flag.BoolVar(&update, "update", false, "Rewrite golden files instead of comparing")
+	flag.BoolVar(&verbose, "v", false, "Log scraper output")
+	flag.Parse()
+
+	level := zerolog.WarnLevel
+	if verbose {
+		level = zerolog.DebugLevel
+	}
+	logger := log.Output(zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: time.RFC3339, NoColor: true}).Level(level)
+	harness := fixtures.NewHarness(dir, logger)
+	ctx := context.Background()
+
+	if record {
+		if name == "" {
+			fmt.Println("-record requires -fixture")
+			os.Exit(2)
+		}
+		if err := harness.Record(ctx, name); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Printf("recorded %s\n", name)
+		return
+	}
+
+	names := []string{name}
+	if name == "" {
+		var err error
+		if names, err = harness.Names(); err != nil {
+			fmt.Println(err)
+			os.Exit(2)
+		}
+	}
+
+	failed := false
+	for _, fixture := range names {
+		if err := harness.Verify(ctx, fixture, update); err != nil {
+			fmt.Printf("FAIL %s\n%s\n", fixture, err)
+			failed = true
+		} else {
+			fmt.Printf("ok   %s\n", fixture)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"os"
+	"scraper/internal/command"
+)
+
+// The scraper command line. Run from server/scraper:
+//
+//	go run ./cmd/scraper scrape --source metrotheatre
+//	go run ./cmd/scraper scrape --dry-run --format table --diff
+//	go run ./cmd/scraper tag --source moshtix --limit 50
+//	go run ./cmd/scraper purge --before 2025-01-01
+//	go run ./cmd/scraper sources list --json
+//	go run ./cmd/scraper tables create
+//	go run ./cmd/scraper daemon --addr :8080
+//
+// Exit codes: 0 success, 1 failure, 2 bad arguments or configuration,
+// 3 when the command ran but some sources or events failed.
+func main() {
+	os.Exit(command.Main(os.Args[1:]))
+}
@@ -0,0 +1,332 @@
+package command
+
+import (
+	"common"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"io"
+	"os"
+	"os/signal"
+	"scraper/internal/transfer"
+	"scraper/internal/venuescrapers"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+)
+
+// exit codes of the command line
+const (
+	ExitOK      = 0
+	ExitFailed  = 1 // the command failed
+	ExitUsage   = 2 // bad arguments or configuration
+	ExitPartial = 3 // the command ran, but some sources or events failed
+)
+
+const usage = `usage: scraper <command> [flags]
+
+commands:
+  scrape                 scrape sources into the Events table
+  tag                    tag untagged events
+  purge                  delete events that have started
+  sources list           list sources, configured and built in
+  sources key            issue an API key for pushing events to a source
+  tables create          create the DynamoDB tables
+  events import          import events from a CSV or JSON lines file
+  events export          export stored events to CSV or JSON lines
+  events rekey           rekey venue events stored by page URL by session
+  daemon                 run scrape, tag and purge jobs on schedules
+  quarantine list        list quarantined events
+  quarantine fix         overwrite fields of a quarantined event
+  quarantine resubmit    run quarantined events through the pipeline again
+
+Configuration is read from the environment, as in Lambda.
+Run "scraper <command> -h" for the flags of a command.
+`
+
+// errUsage marks errors in the arguments, reported with ExitUsage
+var errUsage = errors.New("usage")
+
+// Main runs the command line with args, the arguments after the program name,
+// and returns the exit code
+func Main(args []string) int {
+	if len(args) > 0 && args[0] == "daemon" {
+		return daemon(args[1:])
+	}
+
+	command, asJSON, err := parseArgs(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return ExitOK
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		if errors.Is(err, errUsage) {
+			fmt.Fprint(os.Stderr, "\n"+usage)
+		}
+		return ExitUsage
+	}
+
+	logger := log.Output(zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: time.RFC3339, NoColor: true})
+	cfg, err := LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration: %s\n", err.Error())
+		return ExitUsage
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	svc, err := NewService(ctx, cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration: %s\n", err.Error())
+		return ExitUsage
+	}
+
+	response, err := Execute(ctx, svc, command, logger)
+
+	// a dry run or export without an output file has stdout to itself
+	out := io.Writer(os.Stdout)
+	if (command.Name == "scrape" && command.DryRun || command.Name == "exportEvents") && command.Output == "" {
+		out = os.Stderr
+	}
+	if asJSON {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(response)
+	} else {
+		printResponse(out, command, response)
+	}
+
+	switch {
+	case err != nil:
+		return ExitFailed
+	case partial(response):
+		return ExitPartial
+	}
+	return ExitOK
+}
+
+func parseArgs(args []string) (Command, bool, error) {
+	if len(args) == 0 {
+		return Command{}, false, fmt.Errorf("%w: no command given", errUsage)
+	}
+	name, args := args[0], args[1:]
+	switch name {
+	case "sources", "tables", "quarantine", "events":
+		if len(args) == 0 {
+			return Command{}, false, fmt.Errorf("%w: %s needs a subcommand", errUsage, name)
+		}
+		name, args = name+" "+args[0], args[1:]
+	case "help", "-h", "--help":
+		fmt.Fprint(os.Stdout, usage)
+		return Command{}, false, flag.ErrHelp
+	}
+
+	var command Command
+	var asJSON bool
+	var fix, mapping string
+	flags := flag.NewFlagSet("scraper "+name, flag.ContinueOnError)
+	flags.BoolVar(&asJSON, "json", false, "Print the result as JSON")
+
+	switch name {
+	case "scrape":
+		command.Name = "scrape"
+		flags.StringVar(&command.Venue, "source", "", "Source ID or type to scrape (default: every active source)")
+		flags.BoolVar(&command.Full, "full", false, "Scrape every page, ignoring source checkpoints")
+		flags.BoolVar(&command.DryRun, "dry-run", false, "Write scraped events out instead of storing them")
+		flags.StringVar(&command.Format, "format", venuescrapers.FormatJSONLines, "Dry run output format: jsonl or table")
+		flags.StringVar(&command.Output, "out", "", "Dry run output file (default: stdout)")
+		flags.BoolVar(&command.Diff, "diff", false, "Dry run: compare events with what is stored")
+	case "tag":
+		command.Name = "tag"
+		flags.StringVar(&command.Venue, "source", "", "Source ID or type whose events to tag (default: every active source)")
+		flags.IntVar(&command.Limit, "limit", 0, "Most events to tag (default: no limit)")
+	case "purge":
+		command.Name = "purge"
+		flags.StringVar(&command.Before, "before", "", "Delete events starting before this date, YYYY-MM-DD (default: today)")
+	case "sources list":
+		command.Name = "listSources"
+	case "sources key":
+		command.Name = "issueAPIKey"
+		flags.StringVar(&command.Venue, "source", "", "ID of the push source, created when it does not exist")
+		flags.StringVar(&command.DisplayName, "name", "", "Name of the source, when it is created (default: its ID)")
+	case "tables create":
+		command.Name = "createTables"
+	case "events import":
+		command.Name = "importEvents"
+		flags.StringVar(&command.File, "file", "", "CSV or JSON lines file of events")
+		flags.StringVar(&command.Format, "format", "", "File format: csv or jsonl (default: from the file extension)")
+		flags.StringVar(&command.Venue, "source", "", "Source ID of rows without a source column")
+		flags.StringVar(&mapping, "map", "", `CSV columns of fields, e.g. "title=Event Name,start=Date" (default: columns named as fields)`)
+		flags.BoolVar(&command.DryRun, "check", false, "Only read and validate the file")
+	case "events export":
+		command.Name = "exportEvents"
+		flags.StringVar(&command.Venue, "source", "", "Only export events of this source name")
+		flags.StringVar(&command.From, "from", "", "First day of events, YYYY-MM-DD")
+		flags.StringVar(&command.To, "to", "", "Last day of events, YYYY-MM-DD")
+		flags.StringVar(&command.Category, "category", "", "Only export events in this category")
+		flags.StringVar(&command.Format, "format", transfer.FormatCSV, "Output format: csv or jsonl")
+		flags.StringVar(&command.Output, "out", "", "Output file (default: stdout)")
+	case "events rekey":
+		command.Name = "migrateSessionKeys"
+		flags.BoolVar(&command.DryRun, "check", false, "Only count the events to rekey")
+	case "quarantine list":
+		command.Name = "listQuarantine"
+		flags.StringVar(&command.Venue, "source", "", "Only list events of this source")
+	case "quarantine fix":
+		command.Name = "fixQuarantined"
+		flags.StringVar(&command.QuarantineID, "id", "", "Quarantine ID of the event, as listed")
+		flags.StringVar(&fix, "set", "", `Fields to overwrite as JSON, e.g. '{"Title": "..."}'`)
+	case "quarantine resubmit":
+		command.Name = "resubmitQuarantined"
+		flags.StringVar(&command.QuarantineID, "id", "", "Quarantine ID of the event (default: every quarantined event)")
+		flags.StringVar(&command.Venue, "source", "", "Only resubmit events of this source")
+	default:
+		return Command{}, false, fmt.Errorf("%w: unknown command %q", errUsage, name)
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return Command{}, false, err
+	}
+	if flags.NArg() > 0 {
+		return Command{}, false, fmt.Errorf("%w: unexpected arguments %s", errUsage, strings.Join(flags.Args(), " "))
+	}
+
+	switch {
+	case command.Name == "issueAPIKey" && command.Venue == "":
+		return Command{}, false, fmt.Errorf("%w: sources key needs -source", errUsage)
+	case command.Name == "fixQuarantined" && (command.QuarantineID == "" || fix == ""):
+		return Command{}, false, fmt.Errorf("%w: quarantine fix needs -id and -set", errUsage)
+	case fix != "" && !json.Valid([]byte(fix)):
+		return Command{}, false, fmt.Errorf("%w: -set is not valid JSON", errUsage)
+	case command.Name == "importEvents" && command.File == "":
+		return Command{}, false, fmt.Errorf("%w: events import needs -file", errUsage)
+	case command.Before != "":
+		if _, err := time.Parse(time.DateOnly, command.Before); err != nil {
+			return Command{}, false, fmt.Errorf("%w: -before must be YYYY-MM-DD", errUsage)
+		}
+	}
+	for name, value := range map[string]string{"from": command.From, "to": command.To} {
+		if _, err := time.Parse(time.DateOnly, value); value != "" && err != nil {
+			return Command{}, false, fmt.Errorf("%w: -%s must be YYYY-MM-DD", errUsage, name)
+		}
+	}
+	var err error
+	if command.Mapping, err = transfer.ParseMapping(mapping); err != nil {
+		return Command{}, false, fmt.Errorf("%w: -map: %s", errUsage, err.Error())
+	}
+	if len(command.Mapping) == 0 {
+		command.Mapping = nil
+	}
+	command.Fix = json.RawMessage(fix)
+	return command, asJSON, nil
+}
+
+// daemon runs "scraper daemon" until SIGINT or SIGTERM
+func daemon(args []string) int {
+	logger := log.Output(zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: time.RFC3339, NoColor: true})
+	cfg, err := LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration: %s\n", err.Error())
+		return ExitUsage
+	}
+	if err := daemonFlags(args, &cfg); errors.Is(err, flag.ErrHelp) {
+		return ExitOK
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return ExitUsage
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	svc, err := NewService(ctx, cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration: %s\n", err.Error())
+		return ExitUsage
+	}
+	if err := RunDaemon(ctx, svc, cfg, logger); err != nil {
+		logger.Error().Msg(err.Error())
+		return ExitFailed
+	}
+	return ExitOK
+}
+
+// partial reports whether a command that ran left work undone
+func partial(response Response) bool {
+	for _, run := range response.Runs {
+		if run.Error != "" || run.EventsFailed > 0 {
+			return true
+		}
+	}
+	if len(response.Rejected) > 0 {
+		return true
+	}
+	return response.Command == "resubmitQuarantined" && len(response.Quarantined) > 0
+}
+
+func printResponse(out io.Writer, command Command, response Response) {
+	table := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	defer table.Flush()
+
+	switch command.Name {
+	case "scrape", "importEvents":
+		for _, row := range response.Rejected {
+			fmt.Fprintf(table, "Rejected line %d: %s\n", row.Line, row.Error)
+		}
+		if command.Name == "importEvents" && command.DryRun {
+			fmt.Fprintf(table, "Checked %s, %d rows rejected\n", command.File, len(response.Rejected))
+			return
+		}
+		fmt.Fprintln(table, "SOURCE\tPAGES\tFOUND\tNEW\tUPDATED\tFAILED\tSKIPPED\tSTATUS")
+		for _, run := range response.Runs {
+			fmt.Fprintf(table, "%s\t%d\t%d\t%d\t%d\t%d\t%d\t%s\n", run.SourceID, run.PagesFetched, run.EventsFound,
+				run.EventsNew, run.EventsUpdated, run.EventsFailed, run.URLsSkipped, runStatus(run))
+		}
+	case "listSources":
+		fmt.Fprintln(table, "SOURCE\tTYPE\tACTIVE\tNAME\tLAST RUN")
+		for _, source := range response.Sources {
+			lastRun := "-"
+			if !source.Checkpoint.LastSuccessfulRun.IsZero() {
+				lastRun = source.Checkpoint.LastSuccessfulRun.Local().Format("Mon 02 Jan 2006 15:04")
+			}
+			fmt.Fprintf(table, "%s\t%s\t%t\t%s\t%s\n", source.SourceID, source.SourceType, source.Active, source.Name, lastRun)
+		}
+	case "exportEvents":
+		fmt.Fprintf(table, "Exported %d events\n", response.Exported)
+	case "migrateSessionKeys":
+		if command.DryRun {
+			fmt.Fprintf(table, "%d events to rekey\n", response.Migrated)
+			return
+		}
+		fmt.Fprintf(table, "Rekeyed %d events\n", response.Migrated)
+	case "tag":
+		fmt.Fprintf(table, "Tagged %d events\n", response.Tagged)
+	case "issueAPIKey":
+		fmt.Fprintf(table, "API key for source %s, it will not be shown again:\n%s\n", command.Venue, response.APIKey)
+	case "listQuarantine", "fixQuarantined", "resubmitQuarantined":
+		if command.Name == "resubmitQuarantined" && len(response.Quarantined) == 0 {
+			fmt.Fprintln(table, "Nothing left in quarantine")
+			return
+		}
+		fmt.Fprintln(table, "ID\tSTAGE\tSINCE\tREASONS")
+		for _, event := range response.Quarantined {
+			fmt.Fprintf(table, "%s\t%s\t%s\t%s\n", event.QuarantineID, event.Stage,
+				event.QuarantinedAt.Local().Format("Mon 02 Jan 2006 15:04"), strings.Join(event.Reasons, "; "))
+		}
+	}
+}
+
+func runStatus(run common.ScrapeRun) string {
+	switch {
+	case run.Error != "":
+		return "failed: " + run.Error
+	case run.Anomaly:
+		return "anomaly: " + run.AnomalyReason
+	case run.StoppedEarly:
+		return "ok, stopped early"
+	}
+	return "ok"
+}
@@ -0,0 +1,135 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/rs/zerolog"
+	"net/http"
+	"scraper/internal/scheduler"
+	"scraper/internal/service"
+	"time"
+)
+
+// defaults of daemon mode, each overridable from the environment
+const (
+	DefaultScrapeSchedule = "0 */6 * * *"
+	DefaultTagSchedule    = "30 * * * *"
+	DefaultPurgeSchedule  = "0 4 * * *"
+	DefaultJitter         = 5 * time.Minute
+	DefaultStatusAddr     = ":8080"
+
+	scheduleOff     = "off"
+	shutdownTimeout = 2 * time.Minute
+)
+
+func orDefault[T comparable](value T, fallback T) T {
+	var zero T
+	if value == zero {
+		return fallback
+	}
+	return value
+}
+
+// RunDaemon runs scrape, tag and purge jobs on their schedules and serves their
+// status over HTTP until ctx is done. Sources are read once, at start.
+func RunDaemon(ctx context.Context, svc *service.Service, cfg Config, logger zerolog.Logger) error {
+	jitter := cfg.Daemon.Jitter
+	jobs := scheduler.NewScheduler(shutdownTimeout, logger)
+
+	sources, err := svc.ListSources()
+	if err != nil {
+		return fmt.Errorf("reading sources: %w", err)
+	}
+	for _, source := range sources {
+		if !source.Active || source.Pushed() {
+			continue
+		}
+		sourceID := source.SourceID
+		err := jobs.Add(scheduler.Job{
+			Name:     "scrape " + sourceID,
+			Schedule: orDefault(source.Schedule, orDefault(cfg.Daemon.ScrapeSchedule, DefaultScrapeSchedule)),
+			Jitter:   jitter,
+			Run: func(ctx context.Context) error {
+				runs, err := svc.LoadEvents(ctx, sourceID, false)
+				for _, run := range runs {
+					if run.Error != "" {
+						return errors.New(run.Error)
+					}
+				}
+				return err
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if schedule := orDefault(cfg.Daemon.TagSchedule, DefaultTagSchedule); schedule != scheduleOff {
+		err := jobs.Add(scheduler.Job{
+			Name:     "tag",
+			Schedule: schedule,
+			Jitter:   jitter,
+			Run: func(ctx context.Context) error {
+				_, err := svc.TagEvents("", 0)
+				return err
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if schedule := orDefault(cfg.Daemon.PurgeSchedule, DefaultPurgeSchedule); schedule != scheduleOff {
+		err := jobs.Add(scheduler.Job{
+			Name:     "purge",
+			Schedule: schedule,
+			Jitter:   jitter,
+			Run:      func(ctx context.Context) error { return svc.Purge(time.Now()) },
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	runCtx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	server := &http.Server{Addr: orDefault(cfg.Daemon.StatusAddr, DefaultStatusAddr), Handler: jobs.Handler()}
+	serveErr := make(chan error, 1)
+	go func() {
+		logger.Info().Msgf("Serving job status on %s", server.Addr)
+		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			// without its status endpoint the daemon stops
+			serveErr <- err
+			stop()
+		}
+	}()
+
+	jobs.Run(runCtx)
+
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	server.Shutdown(shutdownCtx)
+	select {
+	case err := <-serveErr:
+		return fmt.Errorf("status endpoint: %w", err)
+	default:
+		return nil
+	}
+}
+
+// daemonFlags parses the flags of "scraper daemon" into cfg
+func daemonFlags(args []string, cfg *Config) error {
+	flags := flag.NewFlagSet("scraper daemon", flag.ContinueOnError)
+	flags.StringVar(&cfg.Daemon.StatusAddr, "addr", orDefault(cfg.Daemon.StatusAddr, DefaultStatusAddr), "Address of the HTTP status endpoint")
+	flags.DurationVar(&cfg.Daemon.Jitter, "jitter", orDefault(cfg.Daemon.Jitter, DefaultJitter), "Longest random delay added to each run")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return fmt.Errorf("%w: unexpected arguments", errUsage)
+	}
+	return nil
+}
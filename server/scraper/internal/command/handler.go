@@ -0,0 +1,294 @@
+// Package command runs scraper commands for both entry points: the Lambda
+// handler, which receives a Command as JSON, and the command line, which
+// builds the same Command from its arguments.
+package command
+
+import (
+	"common"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/kelseyhightower/envconfig"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"os"
+	"scraper/internal/dates"
+	"scraper/internal/fetch"
+	"scraper/internal/geocode"
+	"scraper/internal/images"
+	"scraper/internal/service"
+	"scraper/internal/transfer"
+	"scraper/internal/venuescrapers"
+	"time"
+)
+
+type Command struct {
+	Name  string `json:"name"`  // scrape, purge, tag, listSources, issueAPIKey, ingest, importEvents, exportEvents, migrateSessionKeys, createTables, listQuarantine, fixQuarantined, resubmitQuarantined
+	Venue string `json:"venue"` // source ID or type, all when empty; also filters tag and the quarantine
+	Full  bool   `json:"full"`  // scrape: ignore source checkpoints
+
+	// scrape: write events out instead of storing them; importEvents: only validate the file;
+	// migrateSessionKeys: only count the events to rekey
+	DryRun bool   `json:"dry_run"`
+	Format string `json:"format"` // scrape: jsonl (default) or table; importEvents, exportEvents: csv or jsonl
+	Output string `json:"output"` // scrape, exportEvents: file, stdout when empty
+	Diff   bool   `json:"diff"`   // compare with the stored events
+
+	Limit  int    `json:"limit"`  // tag: most events to tag, no limit when 0
+	Before string `json:"before"` // purge: YYYY-MM-DD, events starting earlier are deleted; today when empty
+
+	QuarantineID string          `json:"quarantine_id"` // fixQuarantined, resubmitQuarantined
+	Fix          json.RawMessage `json:"fix"`           // fixQuarantined: fields to overwrite, e.g. {"Title": "..."}
+
+	Events      []common.IngestEvent `json:"events,omitempty"` // ingest: events pushed for the source in Venue
+	DisplayName string               `json:"display_name"`     // issueAPIKey: name of the push source, when it is created
+
+	File    string            `json:"file"`    // importEvents: CSV or JSON lines file, rows without a source go to Venue
+	Mapping map[string]string `json:"mapping"` // importEvents: CSV columns of fields, e.g. {"title": "Event Name"}
+
+	// exportEvents: events of the source name in Venue, all when empty
+	From     string `json:"from"`     // YYYY-MM-DD, first day of events
+	To       string `json:"to"`       // YYYY-MM-DD, last day of events
+	Category string `json:"category"` // only events in this category
+}
+
+// Response is what a command returns to the invoker
+type Response struct {
+	Command     string                    `json:"command"`
+	Runs        []common.ScrapeRun        `json:"runs,omitempty"`        // scrape: one run per source
+	Sources     []common.Source           `json:"sources,omitempty"`     // listSources
+	Tagged      int                       `json:"tagged,omitempty"`      // tag: events tagged
+	Queued      []string                  `json:"queued,omitempty"`      // scrape: sources queued for their own invocation
+	Quarantined []common.QuarantinedEvent `json:"quarantined,omitempty"` // quarantine commands: the events still quarantined
+	APIKey      string                    `json:"api_key,omitempty"`     // issueAPIKey: the new key, shown only once
+	Rejected    []transfer.RowError       `json:"rejected,omitempty"`    // importEvents: rows that could not be read
+	Exported    int                       `json:"exported,omitempty"`    // exportEvents: events written
+	Migrated    int                       `json:"migrated,omitempty"`    // migrateSessionKeys: events rekeyed or deleted
+}
+
+type Config struct {
+	Region   string `envconfig:"AWS_REGION"`
+	QueueURL string `envconfig:"SCRAPER_QUEUE_URL"` // SQS queue scrapes of every source fan out to
+	Database struct {
+		Endpoint string `envconfig:"DYNAMODB_ENDPOINT"`
+	} `yaml:"database"`
+	Fetch struct {
+		UserAgent string        `envconfig:"SCRAPER_USER_AGENT"`
+		CacheDir  string        `envconfig:"SCRAPER_CACHE_DIR"`
+		Timeout   time.Duration `envconfig:"SCRAPER_HTTP_TIMEOUT"`
+	} `yaml:"fetch"`
+	Images struct {
+		Dir      string `envconfig:"SCRAPER_IMAGE_DIR"`      // local blob store
+		Bucket   string `envconfig:"SCRAPER_IMAGE_BUCKET"`   // S3 blob store, takes precedence
+		Endpoint string `envconfig:"SCRAPER_IMAGE_ENDPOINT"` // S3-compatible services only
+		BaseURL  string `envconfig:"SCRAPER_IMAGE_BASE_URL"` // where stored images are served from
+	} `yaml:"images"`
+	Geocode struct {
+		Gazetteer    string `envconfig:"SCRAPER_GAZETTEER"`     // CSV file, replaces the built-in one
+		NominatimURL string `envconfig:"SCRAPER_NOMINATIM_URL"` // online geocoding, off when empty
+	} `yaml:"geocode"`
+	Pipeline struct {
+		Stages    string `envconfig:"SCRAPER_STAGES"`     // e.g. "normalize,validate,dedupe,save:retry"
+		NotifyURL string `envconfig:"SCRAPER_NOTIFY_URL"` // webhook for the notify stage
+	} `yaml:"pipeline"`
+	Daemon struct {
+		ScrapeSchedule string        `envconfig:"SCRAPER_SCRAPE_SCHEDULE"` // for sources without a schedule of their own
+		TagSchedule    string        `envconfig:"SCRAPER_TAG_SCHEDULE"`    // "off" disables the job
+		PurgeSchedule  string        `envconfig:"SCRAPER_PURGE_SCHEDULE"`  // "off" disables the job
+		Jitter         time.Duration `envconfig:"SCRAPER_JITTER"`
+		StatusAddr     string        `envconfig:"SCRAPER_STATUS_ADDR"` // HTTP status endpoint
+	} `yaml:"daemon"`
+}
+
+func LoadConfig() (Config, error) {
+	var cfg Config
+	err := envconfig.Process("", &cfg)
+	return cfg, err
+}
+
+func fetchConfig(cfg Config) fetch.Config {
+	result := fetch.DefaultConfig()
+	if cfg.Fetch.UserAgent != "" {
+		result.UserAgent = cfg.Fetch.UserAgent
+	}
+	if cfg.Fetch.Timeout > 0 {
+		result.Timeout = cfg.Fetch.Timeout
+	}
+	result.CacheDir = cfg.Fetch.CacheDir
+	return result
+}
+
+// imageStore returns nil when no store is configured, which leaves images remote
+func imageStore(ctx context.Context, cfg Config) (images.BlobStore, error) {
+	switch {
+	case cfg.Images.Bucket != "":
+		return images.NewS3Store(ctx, cfg.Images.Bucket, cfg.Region, cfg.Images.Endpoint, cfg.Images.BaseURL)
+	case cfg.Images.Dir != "":
+		return images.NewFileStore(cfg.Images.Dir, cfg.Images.BaseURL), nil
+	}
+	return nil, nil
+}
+
+func geocoder(cfg Config) (geocode.Geocoder, error) {
+	gazetteer := geocode.DefaultGazetteer()
+	if cfg.Geocode.Gazetteer != "" {
+		var err error
+		if gazetteer, err = geocode.LoadGazetteer(cfg.Geocode.Gazetteer); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Geocode.NominatimURL == "" {
+		return geocode.NewCache(gazetteer), nil
+	}
+	nominatim := geocode.NewNominatim(cfg.Geocode.NominatimURL, fetchConfig(cfg).UserAgent, &http.Client{Timeout: 10 * time.Second})
+	return geocode.NewCache(geocode.Chain{gazetteer, nominatim}), nil
+}
+
+// NewService sets up the service as cfg describes
+func NewService(ctx context.Context, cfg Config) (*service.Service, error) {
+	store, err := imageStore(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("image store: %w", err)
+	}
+	locator, err := geocoder(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("gazetteer: %w", err)
+	}
+	options := service.Options{Fetch: fetchConfig(cfg), ImageStore: store, Geocoder: locator, NotifyURL: cfg.Pipeline.NotifyURL}
+	if cfg.Pipeline.Stages != "" {
+		if options.Stages, err = venuescrapers.ParseStages(cfg.Pipeline.Stages); err != nil {
+			return nil, fmt.Errorf("SCRAPER_STAGES: %w", err)
+		}
+	}
+	return service.NewService(cfg.Database.Endpoint, cfg.Region, options)
+}
+
+// HandleRequest is the Lambda handler, see triggers.go for what it accepts
+func HandleRequest(ctx context.Context, payload json.RawMessage) (any, error) {
+	logger := log.Output(zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: time.RFC3339, NoColor: true})
+	cfg, err := LoadConfig()
+	if err != nil {
+		logger.Error().Msgf("Invalid configuration: %v", err)
+		return nil, err
+	}
+	log.Printf("%+v", cfg)
+
+	svc, err := NewService(ctx, cfg)
+	if err != nil {
+		logger.Error().Msgf("Failed to set up the service: %v", err)
+		return nil, err
+	}
+	var queue Queue
+	if cfg.QueueURL != "" {
+		if queue, err = NewSQSQueue(ctx, cfg.QueueURL, cfg.Region); err != nil {
+			logger.Error().Msgf("Failed to set up the queue: %v", err)
+			return nil, err
+		}
+	}
+	return handleRequest(ctx, svc, queue, payload, logger)
+}
+
+// Execute runs command against svc
+func Execute(ctx context.Context, svc *service.Service, command Command, logger zerolog.Logger) (Response, error) {
+	response := Response{Command: command.Name}
+	var err error
+
+	switch command.Name {
+	case "scrape":
+		if command.DryRun {
+			logger.Info().Msg("Starting scrape command (dry run)")
+			format := command.Format
+			if format == "" {
+				format = venuescrapers.FormatJSONLines
+			}
+			response.Runs, err = svc.DryRun(ctx, command.Venue, service.DryRunOptions{Format: format, Output: command.Output, Diff: command.Diff})
+		} else {
+			logger.Info().Msg("Starting scrape command")
+			response.Runs, err = svc.LoadEvents(ctx, command.Venue, command.Full)
+		}
+	case "purge":
+		logger.Info().Msg("Starting purge command")
+		cutoff := time.Now()
+		if command.Before != "" {
+			if cutoff, err = time.Parse(time.DateOnly, command.Before); err != nil {
+				return response, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", command.Before)
+			}
+		}
+		err = svc.Purge(cutoff)
+	case "tag":
+		logger.Info().Msg("Starting tag command")
+		response.Tagged, err = svc.TagEvents(command.Venue, command.Limit)
+	case "listSources":
+		response.Sources, err = svc.ListSources()
+	case "issueAPIKey":
+		logger.Info().Msgf("Issuing an API key for source %s", command.Venue)
+		response.APIKey, err = svc.IssueAPIKey(command.Venue, command.DisplayName)
+	case common.IngestCommand:
+		logger.Info().Msgf("Ingesting %d events for source %s", len(command.Events), command.Venue)
+		var run common.ScrapeRun
+		if run, err = svc.Ingest(ctx, command.Venue, command.Events); err == nil {
+			response.Runs = []common.ScrapeRun{run}
+		}
+	case "importEvents":
+		logger.Info().Msgf("Importing events from %s", command.File)
+		options := service.ImportOptions{Format: command.Format, Mapping: command.Mapping, Source: command.Venue, Check: command.DryRun}
+		response.Runs, response.Rejected, err = svc.Import(ctx, command.File, options)
+	case "exportEvents":
+		logger.Info().Msg("Starting export command")
+		var filter common.EventFilter
+		if filter, err = exportFilter(command); err == nil {
+			format := command.Format
+			if format == "" {
+				format = transfer.FormatJSONLines
+			}
+			response.Exported, err = svc.Export(filter, format, command.Output)
+		}
+	case "migrateSessionKeys":
+		logger.Info().Msg("Starting session key migration")
+		response.Migrated, err = svc.MigrateSessionKeys(command.DryRun)
+	case "createTables":
+		logger.Info().Msg("Starting create tables command")
+		err = svc.CreateTables()
+	case "listQuarantine":
+		response.Quarantined, err = svc.ListQuarantined(command.Venue)
+	case "fixQuarantined":
+		logger.Info().Msgf("Fixing quarantined event %s", command.QuarantineID)
+		var event common.QuarantinedEvent
+		if event, err = svc.FixQuarantined(command.QuarantineID, command.Fix); err == nil {
+			response.Quarantined = []common.QuarantinedEvent{event}
+		}
+	case "resubmitQuarantined":
+		logger.Info().Msg("Resubmitting quarantined events")
+		response.Quarantined, err = svc.ResubmitQuarantined(ctx, command.QuarantineID, command.Venue)
+	default:
+		err = fmt.Errorf("unknown command: %s", command.Name)
+	}
+
+	if err != nil {
+		logger.Error().Msg(err.Error())
+	}
+	return response, err
+}
+
+// exportFilter reads the event filter of an exportEvents command. The day in
+// To is included, both are Sydney days.
+func exportFilter(command Command) (common.EventFilter, error) {
+	filter := common.EventFilter{Source: command.Venue, Category: command.Category}
+	location, err := time.LoadLocation(dates.DefaultLocation)
+	if err != nil {
+		return filter, err
+	}
+	if command.From != "" {
+		if filter.From, err = time.ParseInLocation(time.DateOnly, command.From, location); err != nil {
+			return filter, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", command.From)
+		}
+	}
+	if command.To != "" {
+		if filter.To, err = time.ParseInLocation(time.DateOnly, command.To, location); err != nil {
+			return filter, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", command.To)
+		}
+		filter.To = filter.To.AddDate(0, 0, 1)
+	}
+	return filter, nil
+}
@@ -0,0 +1,173 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/rs/zerolog"
+	"scraper/internal/service"
+	"strconv"
+)
+
+// The Lambda is invoked three ways:
+//
+//   - with a Command, directly or from an EventBridge Scheduler target
+//   - with an EventBridge event, whose detail is a Command. A scheduled rule
+//     without one scrapes every source.
+//   - with a batch of SQS messages, each holding a Command
+//
+// With a queue configured, scraping every source fans out into one message per
+// active source, so each source is scraped by its own invocation and a broken
+// scraper only fails its own message.
+
+// Queue takes commands to run later, one invocation each
+type Queue interface {
+	Send(ctx context.Context, commands []Command) error
+}
+
+type SQSQueue struct {
+	client *sqs.Client
+	url    string
+}
+
+func NewSQSQueue(ctx context.Context, url string, region string) (SQSQueue, error) {
+	cfg, err := config.LoadDefaultConfig(ctx, func(o *config.LoadOptions) error {
+		if region != "" {
+			o.Region = region
+		}
+		return nil
+	})
+	if err != nil {
+		return SQSQueue{}, fmt.Errorf("failed loading AWS config: %w", err)
+	}
+	return SQSQueue{client: sqs.NewFromConfig(cfg), url: url}, nil
+}
+
+// sqsBatchSize is the most messages SendMessageBatch accepts
+const sqsBatchSize = 10
+
+func (obj SQSQueue) Send(ctx context.Context, commands []Command) error {
+	for start := 0; start < len(commands); start += sqsBatchSize {
+		batch := commands[start:min(start+sqsBatchSize, len(commands))]
+		entries := make([]types.SendMessageBatchRequestEntry, len(batch))
+		for i, command := range batch {
+			body, err := json.Marshal(command)
+			if err != nil {
+				return err
+			}
+			entries[i] = types.SendMessageBatchRequestEntry{Id: aws.String(strconv.Itoa(i)), MessageBody: aws.String(string(body))}
+		}
+
+		out, err := obj.client.SendMessageBatch(ctx, &sqs.SendMessageBatchInput{QueueUrl: aws.String(obj.url), Entries: entries})
+		if err != nil {
+			return err
+		}
+		if len(out.Failed) > 0 {
+			return fmt.Errorf("%d of %d messages not queued: %s", len(out.Failed), len(entries), aws.ToString(out.Failed[0].Message))
+		}
+	}
+	return nil
+}
+
+// request is the union of the payloads the Lambda receives, enough to tell them apart
+type request struct {
+	Records    []events.SQSMessage `json:"Records"`
+	DetailType string              `json:"detail-type"`
+	Detail     json.RawMessage     `json:"detail"`
+}
+
+func handleRequest(ctx context.Context, svc *service.Service, queue Queue, payload json.RawMessage, logger zerolog.Logger) (any, error) {
+	var parsed request
+	if err := json.Unmarshal(payload, &parsed); err != nil {
+		logger.Error().Msgf("Failed to unmarshal event: %v", err)
+		return nil, err
+	}
+
+	switch {
+	case len(parsed.Records) > 0:
+		return handleSQS(ctx, svc, parsed.Records, logger), nil
+	case parsed.DetailType != "":
+		command := Command{Name: "scrape"}
+		if detail := bytes.TrimSpace(parsed.Detail); len(detail) > 0 && !bytes.Equal(detail, []byte("{}")) {
+			if err := json.Unmarshal(detail, &command); err != nil {
+				logger.Error().Msgf("Failed to unmarshal %s detail: %v", parsed.DetailType, err)
+				return nil, err
+			}
+		}
+		logger.Info().Msgf("Received %s: %s", parsed.DetailType, command.Name)
+		return dispatch(ctx, svc, queue, command, logger)
+	}
+
+	var command Command
+	if err := json.Unmarshal(payload, &command); err != nil {
+		logger.Error().Msgf("Failed to unmarshal event: %v", err)
+		return nil, err
+	}
+	return dispatch(ctx, svc, queue, command, logger)
+}
+
+// dispatch runs command, or queues a scrape per source when it is to scrape them all
+func dispatch(ctx context.Context, svc *service.Service, queue Queue, command Command, logger zerolog.Logger) (Response, error) {
+	fanOut := command.Name == "scrape" && !command.DryRun && (command.Venue == "" || command.Venue == "all")
+	if queue == nil || !fanOut {
+		return Execute(ctx, svc, command, logger)
+	}
+
+	response := Response{Command: command.Name}
+	sources, err := svc.ListSources()
+	if err != nil {
+		return response, err
+	}
+	var commands []Command
+	for _, source := range sources {
+		if source.Active && !source.Pushed() {
+			commands = append(commands, Command{Name: "scrape", Venue: source.SourceID, Full: command.Full})
+			response.Queued = append(response.Queued, source.SourceID)
+		}
+	}
+	if err := queue.Send(ctx, commands); err != nil {
+		logger.Error().Msgf("Failed to queue scrapes: %v", err)
+		return response, err
+	}
+	logger.Info().Msgf("Queued a scrape of %d sources", len(commands))
+	return response, nil
+}
+
+// handleSQS runs the command in each message and reports the failed ones, so
+// SQS retries only those. The event source mapping needs ReportBatchItemFailures.
+func handleSQS(ctx context.Context, svc *service.Service, messages []events.SQSMessage, logger zerolog.Logger) events.SQSEventResponse {
+	var response events.SQSEventResponse
+	for _, message := range messages {
+		if err := handleMessage(ctx, svc, message, logger); err != nil {
+			logger.Error().Msgf("Message %s failed: %s", message.MessageId, err.Error())
+			response.BatchItemFailures = append(response.BatchItemFailures, events.SQSBatchItemFailure{ItemIdentifier: message.MessageId})
+		}
+	}
+	return response
+}
+
+func handleMessage(ctx context.Context, svc *service.Service, message events.SQSMessage, logger zerolog.Logger) error {
+	var command Command
+	if err := json.Unmarshal([]byte(message.Body), &command); err != nil {
+		return err
+	}
+	// messages run their command as is, a queued "scrape all" is not fanned out again
+	response, err := Execute(ctx, svc, command, logger)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, run := range response.Runs {
+		if run.Error != "" {
+			errs = append(errs, fmt.Errorf("source %s: %s", run.SourceID, run.Error))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,383 @@
+// Package dates parses the free-form dates and times found on Australian event
+// listings: "Fri 31 Oct", "31/10/2025 8pm", "Friday, 31 October 2025 08:00 PM",
+// ranges such as "Fri 31 Oct – Sat 1 Nov" or "8pm - late", and labelled times
+// such as "Doors 7pm / Show 8pm". Dates are read day first. Missing years are
+// inferred relative to the time the page was fetched.
+package dates
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // Lambda images do not ship a zoneinfo database
+)
+
+// ErrUnparsable is wrapped by every error Parse returns
+var ErrUnparsable = errors.New("unparsable date")
+
+// DefaultLocation is where listing times are read when the text has no zone
+const DefaultLocation = "Australia/Sydney"
+
+// a date without a year is taken to be in the past only if it is this recent,
+// so "Sat 1 Nov" fetched in mid November is this year's, fetched in March next year's
+const pastTolerance = 60 * 24 * time.Hour
+
+// Result holds what a listing said about when an event happens. End and Doors
+// are zero when the text does not give them. When TimeKnown is false the text
+// only gave dates and Start (and End) are at midnight.
+type Result struct {
+	Start     time.Time
+	End       time.Time
+	Doors     time.Time
+	TimeKnown bool
+}
+
+type Parser struct {
+	location *time.Location
+}
+
+func NewParser(location *time.Location) Parser {
+	return Parser{location: location}
+}
+
+// NewSydneyParser reads listing times as Sydney local time
+func NewSydneyParser() Parser {
+	location, err := time.LoadLocation(DefaultLocation)
+	if err != nil {
+		// cannot happen with time/tzdata embedded
+		panic(err)
+	}
+	return NewParser(location)
+}
+
+var (
+	weekdayPattern = `(?:mon|tue|tues|wed|thu|thur|thurs|fri|sat|sun)(?:day|nesday|rsday|urday|sday)?`
+	monthPattern   = `jan(?:uary)?|feb(?:ruary)?|mar(?:ch)?|apr(?:il)?|may|june?|july?|aug(?:ust)?|sep(?:t|tember)?|oct(?:ober)?|nov(?:ember)?|dec(?:ember)?`
+	timePattern    = `(?:\d{1,2}(?:[:.]\d{2})?\s*[ap]\.?m\.?|\d{1,2}:\d{2}|noon|midday|midnight)`
+
+	labelledTimeRe = regexp.MustCompile(`\b(doors?(?:\s+open)?|show|starts?|music|event|gig|curtain|open)\s*(?:at|from|:)?\s*(` + timePattern + `)`)
+	isoDateRe      = regexp.MustCompile(`\b(\d{4})-(\d{2})-(\d{2})\b`)
+	numericDateRe  = regexp.MustCompile(`\b(\d{1,2})/(\d{1,2})(?:/(\d{4}|\d{2}))?\b`)
+	dayMonthRe     = regexp.MustCompile(`\b(?:(` + weekdayPattern + `)\s+)?(\d{1,2})(?:st|nd|rd|th)?\s+(?:of\s+)?(` + monthPattern + `)\b(?:\s+(\d{4}))?`)
+	monthDayRe     = regexp.MustCompile(`\b(?:(` + weekdayPattern + `)\s+)?(` + monthPattern + `)\s+(\d{1,2})(?:st|nd|rd|th)?\b(?:\s+(\d{4}))?`)
+	timeRe         = regexp.MustCompile(`\b` + timePattern)
+	clockRe        = regexp.MustCompile(`^(\d{1,2})(?:[:.](\d{2}))?\s*([ap])?`)
+)
+
+var months = map[string]time.Month{
+	"jan": time.January, "feb": time.February, "mar": time.March, "apr": time.April,
+	"may": time.May, "jun": time.June, "jul": time.July, "aug": time.August,
+	"sep": time.September, "oct": time.October, "nov": time.November, "dec": time.December,
+}
+
+var weekdays = map[string]time.Weekday{
+	"mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday, "thu": time.Thursday,
+	"fri": time.Friday, "sat": time.Saturday, "sun": time.Sunday,
+}
+
+// date is a calendar date as written, year 0 when missing
+type date struct {
+	year     int
+	month    time.Month
+	day      int
+	weekday  time.Weekday
+	hasWkday bool
+}
+
+// clock is minutes after midnight
+type clock int
+
+type scan struct {
+	text  string
+	dates []date
+	times []clock
+	doors *clock
+	show  *clock
+}
+
+// Parse reads text as it appeared on a listing fetched at fetchedAt
+func (obj Parser) Parse(text string, fetchedAt time.Time) (Result, error) {
+	s := scan{text: normalize(text)}
+	if err := s.labelledTimes(); err != nil {
+		return Result{}, fmt.Errorf("%w %q: %s", ErrUnparsable, text, err.Error())
+	}
+	if err := s.datesIn(); err != nil {
+		return Result{}, fmt.Errorf("%w %q: %s", ErrUnparsable, text, err.Error())
+	}
+	s.plainTimes()
+	if len(s.dates) == 0 {
+		return Result{}, fmt.Errorf("%w %q: no date found", ErrUnparsable, text)
+	}
+
+	reference := fetchedAt.In(obj.location)
+	start, end, err := obj.resolveDates(s.dates, reference)
+	if err != nil {
+		return Result{}, fmt.Errorf("%w %q: %s", ErrUnparsable, text, err.Error())
+	}
+
+	var result Result
+	startTime, endTime := s.startEndTimes()
+	result.TimeKnown = startTime != nil || s.doors != nil
+	result.Start = obj.at(start, startTime)
+	if s.doors != nil {
+		result.Doors = obj.at(start, s.doors)
+		if startTime == nil {
+			result.Start = result.Doors
+		}
+	}
+	if end != nil || endTime != nil {
+		endDate := start
+		if end != nil {
+			endDate = *end
+		}
+		if endTime == nil {
+			endTime = startTime
+		}
+		result.End = obj.at(endDate, endTime)
+		// "10pm - 3am" finishes the next day
+		if !result.End.After(result.Start) && end == nil {
+			result.End = result.End.AddDate(0, 0, 1)
+		}
+	}
+	return result, nil
+}
+
+func normalize(text string) string {
+	text = strings.ToLower(text)
+	text = strings.NewReplacer("–", " - ", "—", " - ", ",", " ", "\u00a0", " ", "|", " ").Replace(text)
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// consume blanks out a match so later patterns do not see it again
+func (obj *scan) consume(loc []int) {
+	obj.text = obj.text[:loc[0]] + strings.Repeat(" ", loc[1]-loc[0]) + obj.text[loc[1]:]
+}
+
+func (obj *scan) labelledTimes() error {
+	for {
+		loc := labelledTimeRe.FindStringSubmatchIndex(obj.text)
+		if loc == nil {
+			return nil
+		}
+		label, value := obj.text[loc[2]:loc[3]], obj.text[loc[4]:loc[5]]
+		parsed, err := parseClock(value)
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(label, "door") || label == "open" {
+			obj.doors = &parsed
+		} else {
+			obj.show = &parsed
+		}
+		obj.consume(loc[:2])
+	}
+}
+
+func (obj *scan) datesIn() error {
+	type found struct {
+		at   int
+		date date
+	}
+	var all []found
+
+	for _, loc := range isoDateRe.FindAllStringSubmatchIndex(obj.text, -1) {
+		year, _ := strconv.Atoi(obj.text[loc[2]:loc[3]])
+		month, _ := strconv.Atoi(obj.text[loc[4]:loc[5]])
+		day, _ := strconv.Atoi(obj.text[loc[6]:loc[7]])
+		all = append(all, found{loc[0], date{year: year, month: time.Month(month), day: day}})
+		obj.consume(loc[:2])
+	}
+	for _, loc := range numericDateRe.FindAllStringSubmatchIndex(obj.text, -1) {
+		day, _ := strconv.Atoi(obj.text[loc[2]:loc[3]])
+		month, _ := strconv.Atoi(obj.text[loc[4]:loc[5]])
+		d := date{month: time.Month(month), day: day}
+		if loc[6] >= 0 {
+			d.year = fullYear(obj.text[loc[6]:loc[7]])
+		}
+		all = append(all, found{loc[0], d})
+		obj.consume(loc[:2])
+	}
+	for _, re := range []*regexp.Regexp{dayMonthRe, monthDayRe} {
+		for _, loc := range re.FindAllStringSubmatchIndex(obj.text, -1) {
+			dayGroup, monthGroup := 4, 6
+			if re == monthDayRe {
+				dayGroup, monthGroup = 6, 4
+			}
+			day, _ := strconv.Atoi(obj.text[loc[dayGroup]:loc[dayGroup+1]])
+			d := date{month: months[obj.text[loc[monthGroup]:loc[monthGroup]+3]], day: day}
+			if loc[2] >= 0 {
+				d.weekday, d.hasWkday = weekdays[obj.text[loc[2]:loc[2]+3]], true
+			}
+			if loc[8] >= 0 {
+				d.year, _ = strconv.Atoi(obj.text[loc[8]:loc[9]])
+			}
+			all = append(all, found{loc[0], d})
+			obj.consume(loc[:2])
+		}
+	}
+
+	// keep the order the dates were written in
+	sort.Slice(all, func(i, j int) bool { return all[i].at < all[j].at })
+	for _, f := range all {
+		if f.date.month < time.January || f.date.month > time.December || f.date.day < 1 || f.date.day > 31 {
+			return fmt.Errorf("invalid date %d/%d", f.date.day, f.date.month)
+		}
+		obj.dates = append(obj.dates, f.date)
+	}
+	if len(obj.dates) > 2 {
+		return fmt.Errorf("%d dates found, expected one or a range", len(obj.dates))
+	}
+	return nil
+}
+
+func (obj *scan) plainTimes() {
+	for _, loc := range timeRe.FindAllStringIndex(obj.text, -1) {
+		if parsed, err := parseClock(obj.text[loc[0]:loc[1]]); err == nil {
+			obj.times = append(obj.times, parsed)
+		}
+	}
+}
+
+// startEndTimes prefers a labelled show time; unlabelled times read as "start - end"
+func (obj *scan) startEndTimes() (*clock, *clock) {
+	var start, end *clock
+	times := obj.times
+	if obj.show != nil {
+		start = obj.show
+	} else if len(times) > 0 {
+		start, times = &times[0], times[1:]
+	}
+	if len(times) > 0 {
+		end = &times[0]
+	}
+	return start, end
+}
+
+func parseClock(text string) (clock, error) {
+	switch text {
+	case "noon", "midday":
+		return 12 * 60, nil
+	case "midnight":
+		return 0, nil
+	}
+	m := clockRe.FindStringSubmatch(strings.ReplaceAll(text, ".m", "m"))
+	if m == nil {
+		return 0, fmt.Errorf("invalid time %q", text)
+	}
+	hour, _ := strconv.Atoi(m[1])
+	minute := 0
+	if m[2] != "" {
+		minute, _ = strconv.Atoi(m[2])
+	}
+	switch m[3] {
+	case "a":
+		if hour == 12 {
+			hour = 0
+		}
+	case "p":
+		if hour < 12 {
+			hour += 12
+		}
+	}
+	if hour > 23 || minute > 59 {
+		return 0, fmt.Errorf("invalid time %q", text)
+	}
+	return clock(hour*60 + minute), nil
+}
+
+func fullYear(text string) int {
+	year, _ := strconv.Atoi(text)
+	if year < 100 {
+		year += 2000
+	}
+	return year
+}
+
+// resolveDates fills in missing years: from the other end of a range when it
+// has one, otherwise the first year (this one or the next) where the date is
+// not long past and matches the weekday if one was written
+func (obj Parser) resolveDates(dates []date, reference time.Time) (time.Time, *time.Time, error) {
+	first := dates[0]
+	var last *date
+	if len(dates) == 2 {
+		last = &dates[1]
+	}
+
+	if first.year == 0 && last != nil && last.year != 0 {
+		first.year = last.year
+		if first.month > last.month || (first.month == last.month && first.day > last.day) {
+			first.year--
+		}
+	}
+	if first.year == 0 {
+		year, err := obj.inferYear(first, reference)
+		if err != nil {
+			return time.Time{}, nil, err
+		}
+		first.year = year
+	}
+	start, err := obj.calendar(first)
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+	if last == nil {
+		return start, nil, nil
+	}
+
+	if last.year == 0 {
+		last.year = first.year
+		if last.month < first.month || (last.month == first.month && last.day < first.day) {
+			last.year++
+		}
+	}
+	end, err := obj.calendar(*last)
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+	if end.Before(start) {
+		return time.Time{}, nil, errors.New("range ends before it starts")
+	}
+	return start, &end, nil
+}
+
+// inferYear picks the first year where d is not long past and, when a weekday
+// was written, falls on it. A weekday no nearby year matches is an error rather
+// than a guess.
+func (obj Parser) inferYear(d date, reference time.Time) (int, error) {
+	cutoff := reference.Add(-pastTolerance)
+	for year := reference.Year() - 1; year <= reference.Year()+1; year++ {
+		candidate := time.Date(year, d.month, d.day, 0, 0, 0, 0, obj.location)
+		if candidate.Before(cutoff) || candidate.Month() != d.month {
+			continue
+		}
+		if !d.hasWkday || candidate.Weekday() == d.weekday {
+			return year, nil
+		}
+	}
+	if d.hasWkday {
+		return 0, fmt.Errorf("%d/%d is not a %s in any year near %d", d.day, d.month, d.weekday, reference.Year())
+	}
+	return reference.Year(), nil
+}
+
+func (obj Parser) calendar(d date) (time.Time, error) {
+	result := time.Date(d.year, d.month, d.day, 0, 0, 0, 0, obj.location)
+	if result.Day() != d.day {
+		return time.Time{}, fmt.Errorf("invalid date %d/%d/%d", d.day, d.month, d.year)
+	}
+	if d.hasWkday && result.Weekday() != d.weekday {
+		return time.Time{}, fmt.Errorf("%d/%d/%d is a %s, not a %s", d.day, d.month, d.year, result.Weekday(), d.weekday)
+	}
+	return result, nil
+}
+
+func (obj Parser) at(day time.Time, c *clock) time.Time {
+	if c == nil {
+		return day
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), int(*c)/60, int(*c)%60, 0, 0, obj.location)
+}
@@ -0,0 +1,65 @@
+package dates
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	parser := NewSydneyParser()
+	sydney := parser.location
+	at := func(year int, month time.Month, day, hour, minute int) time.Time {
+		return time.Date(year, month, day, hour, minute, 0, 0, sydney)
+	}
+	october := at(2026, time.October, 18, 12, 0)
+
+	tests := []struct {
+		text      string
+		fetchedAt time.Time
+		want      Result
+	}{
+		{"Fri 30 Oct", october, Result{Start: at(2026, time.October, 30, 0, 0)}},
+		{"31/10/2025 8pm", october, Result{Start: at(2025, time.October, 31, 20, 0), TimeKnown: true}},
+		{"Friday, 31 October 2025 08:00 PM", october, Result{Start: at(2025, time.October, 31, 20, 0), TimeKnown: true}},
+		{"Fri 30 Oct – Sat 31 Oct", october, Result{Start: at(2026, time.October, 30, 0, 0), End: at(2026, time.October, 31, 0, 0)}},
+		{"Fri 30 Oct 8pm – 11pm", october, Result{Start: at(2026, time.October, 30, 20, 0), End: at(2026, time.October, 30, 23, 0), TimeKnown: true}},
+		{"Sat 31 Oct 2026 10pm - 3am", october, Result{Start: at(2026, time.October, 31, 22, 0), End: at(2026, time.November, 1, 3, 0), TimeKnown: true}},
+		{"Sat 31 Oct 2026 Doors 7pm / Show 8pm", october, Result{Start: at(2026, time.October, 31, 20, 0), Doors: at(2026, time.October, 31, 19, 0), TimeKnown: true}},
+		{"Sat 31 Oct 2026 Doors 7pm", october, Result{Start: at(2026, time.October, 31, 19, 0), Doors: at(2026, time.October, 31, 19, 0), TimeKnown: true}},
+		// December listing of a January show, and a January listing of a recent December one
+		{"Sat 2 Jan 9pm", at(2026, time.December, 20, 12, 0), Result{Start: at(2027, time.January, 2, 21, 0), TimeKnown: true}},
+		{"Sat 19 Dec", at(2027, time.January, 10, 12, 0), Result{Start: at(2026, time.December, 19, 0, 0)}},
+		{"Thu 31 Dec – Fri 1 Jan", at(2026, time.December, 1, 12, 0), Result{Start: at(2026, time.December, 31, 0, 0), End: at(2027, time.January, 1, 0, 0)}},
+	}
+	for _, test := range tests {
+		got, err := parser.Parse(test.text, test.fetchedAt)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", test.text, err)
+			continue
+		}
+		if !got.Start.Equal(test.want.Start) || !got.End.Equal(test.want.End) || !got.Doors.Equal(test.want.Doors) || got.TimeKnown != test.want.TimeKnown {
+			t.Errorf("Parse(%q) = %+v, want %+v", test.text, got, test.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	parser := NewSydneyParser()
+	fetchedAt := time.Date(2026, time.October, 18, 12, 0, 0, 0, parser.location)
+
+	for _, text := range []string{
+		"",
+		"TBA",
+		"32/10/2025",
+		"31 Feb 2026",
+		"Fri 31 Oct",      // a Saturday this year, a Sunday next
+		"Fri 31 Oct 2026", // a Saturday
+		"1/2/2026 3/4/2026 5/6/2026",
+		"7/11/2026 - 6/11/2026",
+	} {
+		if got, err := parser.Parse(text, fetchedAt); !errors.Is(err, ErrUnparsable) {
+			t.Errorf("Parse(%q) = %+v, %v, want ErrUnparsable", text, got, err)
+		}
+	}
+}
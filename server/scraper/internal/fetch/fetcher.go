@@ -0,0 +1,378 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+	"github.com/rs/zerolog"
+	"golang.org/x/time/rate"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const DefaultUserAgent = "GigsNearMeBot/1.0"
+
+type Config struct {
+	Timeout     time.Duration // per attempt
+	UserAgent   string
+	MaxRetries  int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	CacheDir    string // on-disk response cache; empty disables it
+	HostRate    rate.Limit
+	HostBurst   int
+	// requests in flight against a single host, across all scrapers
+	MaxConcurrentPerHost int
+	// check robots.txt before every GET and honour its Crawl-delay
+	RespectRobots bool
+	Transport     http.RoundTripper // underlying transport; nil means http.DefaultTransport
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Timeout:     20 * time.Second,
+		UserAgent:   DefaultUserAgent,
+		MaxRetries:  3,
+		BaseBackoff: 500 * time.Millisecond,
+		MaxBackoff:  10 * time.Second,
+		HostRate:    rate.Limit(2),
+		HostBurst:   2,
+
+		MaxConcurrentPerHost: 2,
+		RespectRobots:        true,
+	}
+}
+
+// Response is a fully read GET response
+type Response struct {
+	URL        string
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	FromCache  bool // the server answered 304 and Body came from the on-disk cache
+}
+
+// Fetcher is the single HTTP entry point shared by all scrapers: it applies
+// robots.txt, the per-host rate and concurrency limits, the User-Agent, retries
+// and the conditional-request cache.
+type Fetcher struct {
+	config  Config
+	client  *http.Client
+	limiter *HostLimiter
+	slots   *hostSlots
+	robots  *robotsCache
+	cache   *diskCache
+	metrics *Metrics
+	logger  zerolog.Logger
+}
+
+func NewFetcher(config Config, logger zerolog.Logger) *Fetcher {
+	obj := &Fetcher{
+		config:  config,
+		limiter: NewHostLimiter(config.HostRate, config.HostBurst),
+		slots:   newHostSlots(config.MaxConcurrentPerHost),
+		robots:  newRobotsCache(),
+		metrics: newMetrics(),
+		logger:  logger,
+	}
+
+	if config.CacheDir != "" {
+		cache, err := newDiskCache(config.CacheDir)
+		if err != nil {
+			logger.Warn().Msgf("Response cache disabled, cannot use %s: %s", config.CacheDir, err.Error())
+		} else {
+			obj.cache = cache
+		}
+	}
+
+	base := config.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	obj.client = &http.Client{
+		Transport: &transport{fetcher: obj, base: base},
+	}
+	return obj
+}
+
+// Client returns an http.Client going through the fetcher, for libraries
+// (such as the GraphQL client) that issue their own requests
+func (obj *Fetcher) Client() *http.Client {
+	return obj.client
+}
+
+func (obj *Fetcher) Metrics() []HostMetrics {
+	return obj.metrics.Snapshot()
+}
+
+func (obj *Fetcher) LogMetrics() {
+	for _, m := range obj.metrics.Snapshot() {
+		obj.logger.Info().Msgf("Fetch metrics %s: %d requests, %d retries, %d errors, %d not modified, %d disallowed, %d bytes, %s",
+			m.Host, m.Requests, m.Retries, m.Errors, m.NotModified, m.Disallowed, m.Bytes, m.Latency)
+	}
+}
+
+// Get fetches url and returns the body. Non-2xx responses are returned as errors.
+func (obj *Fetcher) Get(ctx context.Context, url string) (*Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := obj.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("GET %s: unexpected status %d", url, resp.StatusCode)
+	}
+
+	return &Response{
+		URL:        url,
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       body,
+		FromCache:  resp.Header.Get(fromCacheHeader) != "",
+	}, nil
+}
+
+func (obj *Fetcher) GetDocument(ctx context.Context, url string) (*goquery.Document, error) {
+	resp, err := obj.Get(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	return goquery.NewDocumentFromReader(bytes.NewReader(resp.Body))
+}
+
+// marks responses rebuilt from the cache after a 304
+const fromCacheHeader = "X-Fetch-From-Cache"
+
+type transport struct {
+	fetcher *Fetcher
+	base    http.RoundTripper
+}
+
+func (obj *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	f := obj.fetcher
+	host := req.URL.Host
+
+	req = req.Clone(req.Context())
+	if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", f.config.UserAgent)
+	}
+
+	// robots.txt governs crawling, so API calls such as GraphQL POSTs are only rate limited
+	if f.config.RespectRobots && req.Method == http.MethodGet {
+		if err := obj.checkRobots(req); err != nil {
+			if errors.Is(err, ErrDisallowed) {
+				f.metrics.update(host, func(m *HostMetrics) { m.Disallowed++ })
+			}
+			return nil, err
+		}
+	}
+
+	// retries need to replay the body
+	if req.Body != nil && req.GetBody == nil {
+		data, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(data)), nil }
+		req.Body, _ = req.GetBody()
+	}
+
+	var cached *cacheEntry
+	if f.cache != nil && req.Method == http.MethodGet {
+		if entry, ok := f.cache.get(req.URL.String()); ok {
+			cached = entry
+			if entry.ETag != "" {
+				req.Header.Set("If-None-Match", entry.ETag)
+			}
+			if entry.LastModified != "" {
+				req.Header.Set("If-Modified-Since", entry.LastModified)
+			}
+		}
+	}
+
+	var resp *http.Response
+	var err error
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			f.metrics.update(host, func(m *HostMetrics) { m.Retries++ })
+			if req.GetBody != nil {
+				if req.Body, err = req.GetBody(); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		if err = f.limiter.Wait(req.Context(), req.URL.String()); err != nil {
+			return nil, err
+		}
+
+		resp, err = obj.attempt(req)
+		if !retryable(resp, err) || attempt >= f.config.MaxRetries {
+			break
+		}
+
+		delay := f.backoff(attempt, resp)
+		if err != nil {
+			f.logger.Debug().Msgf("%s %s failed (%s), retrying in %s", req.Method, req.URL, err.Error(), delay)
+		} else {
+			f.logger.Debug().Msgf("%s %s returned %d, retrying in %s", req.Method, req.URL, resp.StatusCode, delay)
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(delay):
+		}
+	}
+
+	if err != nil {
+		f.metrics.update(host, func(m *HostMetrics) { m.Errors++ })
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		resp.Body.Close()
+		f.metrics.update(host, func(m *HostMetrics) { m.NotModified++ })
+		header := cached.Header.Clone()
+		header.Set(fromCacheHeader, "1")
+		return &http.Response{
+			Status:        http.StatusText(cached.StatusCode),
+			StatusCode:    cached.StatusCode,
+			Proto:         resp.Proto,
+			ProtoMajor:    resp.ProtoMajor,
+			ProtoMinor:    resp.ProtoMinor,
+			Header:        header,
+			Body:          io.NopCloser(bytes.NewReader(cached.Body)),
+			ContentLength: int64(len(cached.Body)),
+			Request:       req,
+		}, nil
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		f.metrics.update(host, func(m *HostMetrics) { m.Errors++ })
+		return resp, nil
+	}
+
+	etag, lastModified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	if f.cache == nil || req.Method != http.MethodGet || (etag == "" && lastModified == "") {
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	err = f.cache.put(cacheEntry{
+		URL:          req.URL.String(),
+		ETag:         etag,
+		LastModified: lastModified,
+		StatusCode:   resp.StatusCode,
+		Header:       resp.Header,
+		Body:         body,
+		StoredAt:     time.Now(),
+	})
+	if err != nil {
+		f.logger.Warn().Msgf("Could not cache %s: %s", req.URL, err.Error())
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+// attempt sends one request bounded by the per-attempt timeout
+func (obj *transport) attempt(req *http.Request) (*http.Response, error) {
+	f := obj.fetcher
+	host := req.URL.Host
+
+	release, err := f.slots.acquire(req.Context(), host)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := req.Context(), context.CancelFunc(func() {})
+	if f.config.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(req.Context(), f.config.Timeout)
+	}
+	started := time.Now()
+	resp, err := obj.base.RoundTrip(req.WithContext(ctx))
+	elapsed := time.Since(started)
+
+	f.metrics.update(host, func(m *HostMetrics) {
+		m.Requests++
+		m.Latency += elapsed
+	})
+
+	if err != nil {
+		cancel()
+		release()
+		return nil, err
+	}
+	// the host slot is held until the body has been consumed
+	resp.Body = &trackedBody{ReadCloser: resp.Body, cancel: cancel, onClose: func(n int64) {
+		release()
+		f.metrics.update(host, func(m *HostMetrics) { m.Bytes += n })
+	}}
+	return resp, nil
+}
+
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
+
+// backoff doubles the delay on each attempt, with jitter, and honours Retry-After when given in seconds
+func (obj *Fetcher) backoff(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+			return min(time.Duration(seconds)*time.Second, obj.config.MaxBackoff)
+		}
+	}
+	delay := obj.config.BaseBackoff << attempt
+	delay += time.Duration(rand.Int64N(int64(obj.config.BaseBackoff) + 1))
+	return min(delay, obj.config.MaxBackoff)
+}
+
+// trackedBody counts the bytes read and releases the per-attempt timeout
+// once the caller is done with the body
+type trackedBody struct {
+	io.ReadCloser
+	cancel  context.CancelFunc
+	onClose func(n int64)
+	n       int64
+	closed  bool
+}
+
+func (obj *trackedBody) Read(p []byte) (int, error) {
+	n, err := obj.ReadCloser.Read(p)
+	obj.n += int64(n)
+	return n, err
+}
+
+func (obj *trackedBody) Close() error {
+	err := obj.ReadCloser.Close()
+	if !obj.closed {
+		obj.closed = true
+		obj.cancel()
+		obj.onClose(obj.n)
+	}
+	return err
+}
@@ -0,0 +1,93 @@
+package fetch
+
+import (
+	"context"
+	"golang.org/x/time/rate"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// HostLimiter hands out one token bucket per host so that concurrent workers
+// hitting the same venue site are throttled together, while different sites
+// proceed independently.
+type HostLimiter struct {
+	mu       sync.Mutex
+	limit    rate.Limit
+	burst    int
+	limiters map[string]*rate.Limiter
+}
+
+func NewHostLimiter(limit rate.Limit, burst int) *HostLimiter {
+	return &HostLimiter{
+		limit:    limit,
+		burst:    burst,
+		limiters: map[string]*rate.Limiter{},
+	}
+}
+
+func (obj *HostLimiter) limiter(host string) *rate.Limiter {
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+
+	l, ok := obj.limiters[host]
+	if !ok {
+		l = rate.NewLimiter(obj.limit, obj.burst)
+		obj.limiters[host] = l
+	}
+	return l
+}
+
+// SetMinInterval slows host down to one request per interval, e.g. for a
+// robots.txt Crawl-delay. It never speeds a host up.
+func (obj *HostLimiter) SetMinInterval(host string, interval time.Duration) {
+	l := obj.limiter(host)
+	if limit := rate.Every(interval); limit < l.Limit() {
+		l.SetLimit(limit)
+		l.SetBurst(1)
+	}
+}
+
+// Wait blocks until a request to rawURL is allowed or ctx is done
+func (obj *HostLimiter) Wait(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	return obj.limiter(u.Host).Wait(ctx)
+}
+
+// hostSlots caps the number of requests in flight per host
+type hostSlots struct {
+	mu    sync.Mutex
+	size  int
+	slots map[string]chan struct{}
+}
+
+func newHostSlots(size int) *hostSlots {
+	return &hostSlots{size: size, slots: map[string]chan struct{}{}}
+}
+
+// acquire blocks until a slot for host is free and returns the function releasing it.
+// A non-positive size means no cap.
+func (obj *hostSlots) acquire(ctx context.Context, host string) (func(), error) {
+	if obj.size <= 0 {
+		return func() {}, nil
+	}
+
+	obj.mu.Lock()
+	slot, ok := obj.slots[host]
+	if !ok {
+		slot = make(chan struct{}, obj.size)
+		obj.slots[host] = slot
+	}
+	obj.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case slot <- struct{}{}:
+	}
+	var once sync.Once
+	return func() { once.Do(func() { <-slot }) }, nil
+}
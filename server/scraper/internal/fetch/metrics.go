@@ -0,0 +1,52 @@
+package fetch
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+type HostMetrics struct {
+	Host        string        `json:"host"`
+	Requests    int           `json:"requests"`     // attempts sent over the wire, retries included
+	Retries     int           `json:"retries"`      // attempts beyond the first
+	Errors      int           `json:"errors"`       // transport errors and final non-2xx responses
+	NotModified int           `json:"not_modified"` // 304s answered from the cache
+	Disallowed  int           `json:"disallowed"`   // requests refused because of robots.txt
+	Bytes       int64         `json:"bytes"`
+	Latency     time.Duration `json:"latency"` // cumulative time spent waiting on the host
+}
+
+type Metrics struct {
+	mu    sync.Mutex
+	hosts map[string]*HostMetrics
+}
+
+func newMetrics() *Metrics {
+	return &Metrics{hosts: map[string]*HostMetrics{}}
+}
+
+func (obj *Metrics) update(host string, fn func(m *HostMetrics)) {
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+
+	m, ok := obj.hosts[host]
+	if !ok {
+		m = &HostMetrics{Host: host}
+		obj.hosts[host] = m
+	}
+	fn(m)
+}
+
+// Snapshot returns a copy of the per-host counters, sorted by host
+func (obj *Metrics) Snapshot() []HostMetrics {
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+
+	result := make([]HostMetrics, 0, len(obj.hosts))
+	for _, m := range obj.hosts {
+		result = append(result, *m)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Host < result[j].Host })
+	return result
+}
@@ -0,0 +1,269 @@
+package fetch
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrDisallowed is returned (wrapped) for URLs the site's robots.txt excludes for our user agent
+var ErrDisallowed = errors.New("disallowed by robots.txt")
+
+const (
+	robotsTTL      = 24 * time.Hour
+	robotsErrorTTL = 10 * time.Minute
+	robotsMaxBytes = 500 * 1024 // RFC 9309 lets crawlers ignore anything past 500 KiB
+)
+
+type robotsRule struct {
+	allow   bool
+	pattern string
+}
+
+// robotsPolicy is the group of a robots.txt that applies to our user agent
+type robotsPolicy struct {
+	rules      []robotsRule
+	crawlDelay time.Duration
+}
+
+var allowAll = &robotsPolicy{}
+var disallowAll = &robotsPolicy{rules: []robotsRule{{allow: false, pattern: "/"}}}
+
+// parseRobots picks the group naming our product token, falling back to "*".
+// Tokens match whole and case-insensitively, as RFC 9309 has it, so a group for
+// "bot" is not ours. Consecutive User-agent lines share the rules that follow them.
+func parseRobots(r io.Reader, userAgent string) *robotsPolicy {
+	token := strings.ToLower(userAgent)
+	if i := strings.IndexAny(token, "/ "); i >= 0 {
+		token = token[:i]
+	}
+
+	var specific, wildcard *robotsPolicy
+	var agents []string
+	inRules := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		if key == "user-agent" {
+			if inRules {
+				agents = nil
+				inRules = false
+			}
+			agent := strings.ToLower(value)
+			if i := strings.IndexAny(agent, "/ "); i >= 0 {
+				agent = agent[:i]
+			}
+			agents = append(agents, agent)
+			continue
+		}
+
+		inRules = true
+		var targets []*robotsPolicy
+		for _, agent := range agents {
+			switch {
+			case agent == "*":
+				if wildcard == nil {
+					wildcard = &robotsPolicy{}
+				}
+				targets = append(targets, wildcard)
+			case agent != "" && agent == token:
+				if specific == nil {
+					specific = &robotsPolicy{}
+				}
+				targets = append(targets, specific)
+			}
+		}
+
+		for _, policy := range targets {
+			switch key {
+			case "allow", "disallow":
+				// an empty Disallow allows everything and adds no rule
+				if value != "" {
+					policy.rules = append(policy.rules, robotsRule{allow: key == "allow", pattern: value})
+				}
+			case "crawl-delay":
+				if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+					policy.crawlDelay = time.Duration(seconds * float64(time.Second))
+				}
+			}
+		}
+	}
+
+	switch {
+	case specific != nil:
+		return specific
+	case wildcard != nil:
+		return wildcard
+	}
+	return allowAll
+}
+
+// Allowed applies the longest matching rule; on a tie Allow wins
+func (obj *robotsPolicy) Allowed(path string) bool {
+	if path == "" {
+		path = "/"
+	}
+	best, allowed := -1, true
+	for _, rule := range obj.rules {
+		if !robotsMatch(rule.pattern, path) {
+			continue
+		}
+		if len(rule.pattern) > best || (len(rule.pattern) == best && rule.allow) {
+			best, allowed = len(rule.pattern), rule.allow
+		}
+	}
+	return allowed
+}
+
+// robotsMatch supports the "*" wildcard and the "$" end anchor
+func robotsMatch(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	parts := strings.Split(strings.TrimSuffix(pattern, "$"), "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	pos := len(parts[0])
+	for i, part := range parts[1:] {
+		if anchored && i == len(parts)-2 {
+			// the last literal has to end the path
+			return strings.HasSuffix(path[pos:], part)
+		}
+		idx := strings.Index(path[pos:], part)
+		if idx < 0 {
+			return false
+		}
+		pos += idx + len(part)
+	}
+	return !anchored || pos == len(path)
+}
+
+type robotsEntry struct {
+	policy  *robotsPolicy
+	expires time.Time
+}
+
+// robotsCache fetches robots.txt once per origin and keeps it in memory
+type robotsCache struct {
+	mu      sync.Mutex
+	entries map[string]*robotsEntry
+	pending map[string]chan struct{}
+}
+
+func newRobotsCache() *robotsCache {
+	return &robotsCache{
+		entries: map[string]*robotsEntry{},
+		pending: map[string]chan struct{}{},
+	}
+}
+
+// policy returns the cached policy for origin, fetching it with load when
+// missing or stale; concurrent callers for the same origin share one fetch
+func (obj *robotsCache) policy(ctx context.Context, origin string, load func(ctx context.Context) (*robotsPolicy, time.Duration, error)) (*robotsPolicy, error) {
+	for {
+		obj.mu.Lock()
+		if entry, ok := obj.entries[origin]; ok && time.Now().Before(entry.expires) {
+			obj.mu.Unlock()
+			return entry.policy, nil
+		}
+		wait, loading := obj.pending[origin]
+		if !loading {
+			done := make(chan struct{})
+			obj.pending[origin] = done
+			obj.mu.Unlock()
+
+			policy, ttl, err := load(ctx)
+
+			obj.mu.Lock()
+			if err == nil {
+				obj.entries[origin] = &robotsEntry{policy: policy, expires: time.Now().Add(ttl)}
+			}
+			delete(obj.pending, origin)
+			obj.mu.Unlock()
+			close(done)
+			return policy, err
+		}
+		obj.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-wait:
+		}
+	}
+}
+
+// loadRobots follows RFC 9309: a 4xx means no restrictions, while a server
+// error or an unreachable host means the whole site is off limits for now
+func (obj *transport) loadRobots(ctx context.Context, origin string) (*robotsPolicy, time.Duration, error) {
+	f := obj.fetcher
+	robotsURL := origin + "/robots.txt"
+
+	if err := f.limiter.Wait(ctx, robotsURL); err != nil {
+		return nil, 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, robotsURL, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("User-Agent", f.config.UserAgent)
+
+	// straight through the base transport, as robots.txt is not itself subject to
+	// robots checks or the page retry policy
+	client := http.Client{Transport: obj.base}
+	resp, err := client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, 0, ctx.Err()
+		}
+		f.logger.Warn().Msgf("Could not fetch %s, treating site as disallowed: %s", robotsURL, err.Error())
+		return disallowAll, robotsErrorTTL, nil
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode <= 299:
+		policy := parseRobots(io.LimitReader(resp.Body, robotsMaxBytes), f.config.UserAgent)
+		if policy.crawlDelay > 0 {
+			f.logger.Debug().Msgf("%s asks for a crawl delay of %s", origin, policy.crawlDelay)
+			f.limiter.SetMinInterval(req.URL.Host, policy.crawlDelay)
+		}
+		return policy, robotsTTL, nil
+	case resp.StatusCode >= 400 && resp.StatusCode <= 499:
+		return allowAll, robotsTTL, nil
+	}
+	f.logger.Warn().Msgf("%s returned %d, treating site as disallowed", robotsURL, resp.StatusCode)
+	return disallowAll, robotsErrorTTL, nil
+}
+
+// checkRobots returns a wrapped ErrDisallowed when robots.txt excludes req
+func (obj *transport) checkRobots(req *http.Request) error {
+	origin := req.URL.Scheme + "://" + req.URL.Host
+	policy, err := obj.fetcher.robots.policy(req.Context(), origin, func(ctx context.Context) (*robotsPolicy, time.Duration, error) {
+		return obj.loadRobots(ctx, origin)
+	})
+	if err != nil {
+		return err
+	}
+	if !policy.Allowed(req.URL.RequestURI()) {
+		return fmt.Errorf("%s: %w", req.URL, ErrDisallowed)
+	}
+	return nil
+}
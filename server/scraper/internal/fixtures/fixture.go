@@ -0,0 +1,75 @@
+// Package fixtures records scraper HTTP traffic to disk and replays it through a
+// local server, so scraper output can be checked against golden files offline.
+//
+// A fixture lives in <dir>/<name>/: index.json lists the recorded exchanges,
+// each response body sits next to it in its own file, and events.golden.json
+// holds the normalized events the scraper is expected to produce from them.
+// An optional source.json holds the common.Source to scrape with; without it
+// the directory name is taken as the source type.
+package fixtures
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// BaseURLPlaceholder replaces the recorded site origin in bodies and goldens,
+// and is substituted with the replay server URL when serving.
+const BaseURLPlaceholder = "{{BASE_URL}}"
+
+const (
+	indexFile  = "index.json"
+	goldenFile = "events.golden.json"
+	sourceFile = "source.json"
+)
+
+type Exchange struct {
+	Method      string `json:"method"`
+	RequestURI  string `json:"request_uri"` // path and query, relative to the base URL
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type"`
+	BodyFile    string `json:"body_file"`
+}
+
+type Fixture struct {
+	Dir       string
+	Exchanges []Exchange
+}
+
+func LoadFixture(dir string) (*Fixture, error) {
+	data, err := os.ReadFile(filepath.Join(dir, indexFile))
+	if err != nil {
+		return nil, err
+	}
+	var exchanges []Exchange
+	if err := json.Unmarshal(data, &exchanges); err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Join(dir, indexFile), err)
+	}
+	return &Fixture{Dir: dir, Exchanges: exchanges}, nil
+}
+
+func (obj *Fixture) Body(exchange Exchange) ([]byte, error) {
+	return os.ReadFile(filepath.Join(obj.Dir, exchange.BodyFile))
+}
+
+func (obj *Fixture) saveIndex() error {
+	data, err := json.MarshalIndent(obj.Exchanges, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(obj.Dir, indexFile), append(data, '\n'), 0o644)
+}
+
+// bodyExtension picks a file extension that keeps recorded bodies readable in an editor
+func bodyExtension(contentType string) string {
+	switch {
+	case strings.Contains(contentType, "json"):
+		return ".json"
+	case strings.Contains(contentType, "html"):
+		return ".html"
+	}
+	return ".txt"
+}
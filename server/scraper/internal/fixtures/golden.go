@@ -0,0 +1,90 @@
+package fixtures
+
+import (
+	"bytes"
+	"common"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// normalize strips the fields that change from run to run (generated IDs, fetch
+// times, the replay server port) so events can be compared byte for byte
+func normalize(events []common.Event, baseURL string) []common.Event {
+	rebase := func(s string) string { return strings.ReplaceAll(s, baseURL, BaseURLPlaceholder) }
+
+	result := make([]common.Event, len(events))
+	for i, event := range events {
+		event.EventID = ""
+		event.FetchedAt = time.Time{}
+		event.SourceEvent = rebase(event.SourceEvent)
+		event.URL = rebase(event.URL)
+		event.TicketURL = rebase(event.TicketURL)
+		images := make([]string, len(event.Images))
+		for j, image := range event.Images {
+			images[j] = rebase(image)
+		}
+		if event.Images != nil {
+			event.Images = images
+		}
+		result[i] = event
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].SourceEvent != result[j].SourceEvent {
+			return result[i].SourceEvent < result[j].SourceEvent
+		}
+		return result[i].Start.Before(result[j].Start)
+	})
+	return result
+}
+
+func marshalGolden(events []common.Event) ([]byte, error) {
+	data, err := json.MarshalIndent(events, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// compareGolden returns an error describing the first differing line, if any
+func compareGolden(dir string, events []common.Event) error {
+	got, err := marshalGolden(events)
+	if err != nil {
+		return err
+	}
+	want, err := os.ReadFile(filepath.Join(dir, goldenFile))
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(got, want) {
+		return nil
+	}
+
+	gotLines, wantLines := strings.Split(string(got), "\n"), strings.Split(string(want), "\n")
+	for i := 0; i < max(len(gotLines), len(wantLines)); i++ {
+		var g, w string
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if g != w {
+			return fmt.Errorf("%s line %d:\n  want: %s\n  got:  %s", filepath.Join(dir, goldenFile), i+1, strings.TrimSpace(w), strings.TrimSpace(g))
+		}
+	}
+	return nil
+}
+
+func writeGolden(dir string, events []common.Event) error {
+	data, err := marshalGolden(events)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, goldenFile), data, 0o644)
+}
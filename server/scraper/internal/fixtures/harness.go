@@ -0,0 +1,180 @@
+package fixtures
+
+import (
+	"common"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/rs/zerolog"
+	"golang.org/x/time/rate"
+	"os"
+	"path/filepath"
+	"scraper/internal/fetch"
+	"scraper/internal/venuescrapers"
+	"strings"
+)
+
+type Harness struct {
+	dir    string // one subdirectory per fixture
+	logger zerolog.Logger
+}
+
+func NewHarness(dir string, logger zerolog.Logger) Harness {
+	return Harness{
+		dir:    dir,
+		logger: logger,
+	}
+}
+
+// Names lists the recorded fixtures
+func (obj Harness) Names() ([]string, error) {
+	entries, err := os.ReadDir(obj.dir)
+	if err != nil {
+		return nil, err
+	}
+	var result []string
+	for _, entry := range entries {
+		if _, err := os.Stat(filepath.Join(obj.dir, entry.Name(), indexFile)); err == nil {
+			result = append(result, entry.Name())
+		}
+	}
+	return result, nil
+}
+
+// source reads the optional source.json of a fixture. Without one the fixture
+// name is the source type and the scraper runs with its default scope.
+func (obj Harness) source(name string) (common.Source, error) {
+	data, err := os.ReadFile(filepath.Join(obj.dir, name, sourceFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return venuescrapers.DefaultSource(common.SourceType(name)), nil
+	}
+	if err != nil {
+		return common.Source{}, err
+	}
+	var source common.Source
+	if err := json.Unmarshal(data, &source); err != nil {
+		return common.Source{}, fmt.Errorf("%s: %w", filepath.Join(obj.dir, name, sourceFile), err)
+	}
+	return source, nil
+}
+
+func (obj Harness) relocatable(source common.Source, fetcher *fetch.Fetcher) (venuescrapers.Relocatable, error) {
+	scraper, err := venuescrapers.NewScraper(source, fetcher, obj.logger)
+	if err != nil {
+		return nil, err
+	}
+	relocatable, ok := scraper.(venuescrapers.Relocatable)
+	if !ok {
+		return nil, fmt.Errorf("scraper for %s cannot be pointed at a replay server", source.SourceType)
+	}
+	return relocatable, nil
+}
+
+// Record scrapes the live site for the named fixture, replacing its recorded
+// exchanges, then regenerates the golden file from a replay of them
+func (obj Harness) Record(ctx context.Context, name string) error {
+	dir := filepath.Join(obj.dir, name)
+	source, err := obj.source(name)
+	if err != nil {
+		return err
+	}
+	if err := obj.clear(dir); err != nil {
+		return err
+	}
+
+	probe, err := obj.relocatable(source, nil)
+	if err != nil {
+		return err
+	}
+	recorder, err := NewRecorder(dir, probe.BaseURL(), nil)
+	if err != nil {
+		return err
+	}
+
+	config := fetch.DefaultConfig()
+	config.Transport = recorder
+	fetcher := fetch.NewFetcher(config, obj.logger)
+	scraper, err := obj.relocatable(source, fetcher)
+	if err != nil {
+		return err
+	}
+
+	pipeline := venuescrapers.NewPipeline(venuescrapers.NewMemoryStore(), fetcher, obj.logger)
+	if err := scraper.Scrape(ctx, pipeline); err != nil {
+		return err
+	}
+	if err := recorder.Save(); err != nil {
+		return err
+	}
+
+	return obj.Verify(ctx, name, true)
+}
+
+// clear removes everything recorded in dir but the source definition
+func (obj Harness) clear(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.Name() == sourceFile {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(dir, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Verify replays the named fixture and compares the resulting events with
+// the golden file, or rewrites the golden file when update is set
+func (obj Harness) Verify(ctx context.Context, name string, update bool) error {
+	dir := filepath.Join(obj.dir, name)
+	source, err := obj.source(name)
+	if err != nil {
+		return err
+	}
+	fixture, err := LoadFixture(dir)
+	if err != nil {
+		return err
+	}
+
+	server := NewReplayServer(fixture)
+	defer server.Close()
+
+	config := fetch.DefaultConfig()
+	config.HostRate = rate.Inf
+	config.MaxRetries = 0
+	fetcher := fetch.NewFetcher(config, obj.logger)
+	scraper, err := obj.relocatable(source, fetcher)
+	if err != nil {
+		return err
+	}
+
+	store := venuescrapers.NewMemoryStore()
+	pipeline := venuescrapers.NewPipeline(store, fetcher, obj.logger)
+	if err := scraper.WithBaseURL(server.URL).Scrape(ctx, pipeline); err != nil {
+		return err
+	}
+
+	var errs []error
+	if misses := server.Misses(); len(misses) > 0 {
+		errs = append(errs, fmt.Errorf("%s: requests not in fixture: %s", name, strings.Join(misses, ", ")))
+	}
+
+	events := normalize(store.Events(), server.URL)
+	if update {
+		if err := writeGolden(dir, events); err != nil {
+			errs = append(errs, err)
+		}
+	} else if err := compareGolden(dir, events); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,28 @@
+package fixtures
+
+import (
+	"context"
+	"github.com/rs/zerolog"
+	"testing"
+)
+
+// TestGoldens replays every recorded fixture and fails on a golden diff, so
+// selector regressions show up in go test. Regenerate goldens with
+// go run ./cmd/fixtures -update.
+func TestGoldens(t *testing.T) {
+	harness := NewHarness("../../testdata/fixtures", zerolog.Nop())
+	names, err := harness.Names()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) == 0 {
+		t.Fatal("no fixtures recorded")
+	}
+	for _, name := range names {
+		t.Run(name, func(t *testing.T) {
+			if err := harness.Verify(context.Background(), name, false); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
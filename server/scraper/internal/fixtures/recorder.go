@@ -0,0 +1,86 @@
+package fixtures
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Recorder is an http.RoundTripper that forwards to the live site and writes every
+// exchange with the base host into a fixture directory
+type Recorder struct {
+	mu      sync.Mutex
+	base    http.RoundTripper
+	origin  string
+	fixture *Fixture
+}
+
+func NewRecorder(dir string, baseURL string, base http.RoundTripper) (*Recorder, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Recorder{
+		base:    base,
+		origin:  u.Scheme + "://" + u.Host,
+		fixture: &Fixture{Dir: dir},
+	}, nil
+}
+
+func (obj *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := obj.base.RoundTrip(req)
+	if err != nil || req.URL.Scheme+"://"+req.URL.Host != obj.origin {
+		return resp, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	// a 304 has no body worth keeping, and replay never sends validators
+	if resp.StatusCode == http.StatusNotModified {
+		return resp, nil
+	}
+
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+
+	contentType := resp.Header.Get("Content-Type")
+	exchange := Exchange{
+		Method:      req.Method,
+		RequestURI:  req.URL.RequestURI(),
+		Status:      resp.StatusCode,
+		ContentType: contentType,
+		BodyFile:    fmt.Sprintf("%03d%s", len(obj.fixture.Exchanges)+1, bodyExtension(contentType)),
+	}
+	rewritten := strings.ReplaceAll(string(body), obj.origin, BaseURLPlaceholder)
+	if err := os.WriteFile(filepath.Join(obj.fixture.Dir, exchange.BodyFile), []byte(rewritten), 0o644); err != nil {
+		return nil, err
+	}
+	obj.fixture.Exchanges = append(obj.fixture.Exchanges, exchange)
+
+	return resp, nil
+}
+
+// Save writes the index of everything recorded so far
+func (obj *Recorder) Save() error {
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+
+	return obj.fixture.saveIndex()
+}
@@ -0,0 +1,73 @@
+package fixtures
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+// ReplayServer serves a fixture over HTTP. Exchanges are matched on method and
+// request URI; repeated requests to the same URI (GraphQL pages all POST to one
+// path) are answered in recorded order, the last one repeating.
+type ReplayServer struct {
+	*httptest.Server
+	fixture *Fixture
+
+	mu     sync.Mutex
+	served map[string]int
+	misses []string
+}
+
+func NewReplayServer(fixture *Fixture) *ReplayServer {
+	obj := &ReplayServer{
+		fixture: fixture,
+		served:  map[string]int{},
+	}
+	obj.Server = httptest.NewServer(http.HandlerFunc(obj.serve))
+	return obj
+}
+
+func (obj *ReplayServer) serve(w http.ResponseWriter, r *http.Request) {
+	key := r.Method + " " + r.URL.RequestURI()
+
+	obj.mu.Lock()
+	var candidates []Exchange
+	for _, exchange := range obj.fixture.Exchanges {
+		if exchange.Method+" "+exchange.RequestURI == key {
+			candidates = append(candidates, exchange)
+		}
+	}
+	if len(candidates) == 0 {
+		// fixtures recorded without a robots.txt replay as "no restrictions"
+		if r.URL.Path != "/robots.txt" {
+			obj.misses = append(obj.misses, key)
+		}
+		obj.mu.Unlock()
+		http.NotFound(w, r)
+		return
+	}
+	exchange := candidates[min(obj.served[key], len(candidates)-1)]
+	obj.served[key]++
+	obj.mu.Unlock()
+
+	body, err := obj.fixture.Body(exchange)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if exchange.ContentType != "" {
+		w.Header().Set("Content-Type", exchange.ContentType)
+	}
+	w.WriteHeader(exchange.Status)
+	w.Write([]byte(strings.ReplaceAll(string(body), BaseURLPlaceholder, obj.URL)))
+}
+
+// Misses lists requests that had no recorded exchange
+func (obj *ReplayServer) Misses() []string {
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+
+	return append([]string(nil), obj.misses...)
+}
@@ -0,0 +1,139 @@
+package geocode
+
+import (
+	"common"
+	"context"
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// The gazetteer is a CSV file with the header kind,name,postcode,region,lat,lng
+// where kind is one of venue, suburb or postcode. Postcodes without a row of
+// their own fall back to the first suburb listed with them.
+//
+//go:embed gazetteer.csv
+var defaultGazetteer string
+
+// Gazetteer geocodes offline from a list of known venues, suburbs and postcodes
+type Gazetteer struct {
+	venues    map[string]common.Geo
+	suburbs   map[string]common.Geo // by "name|region" and by name alone
+	postcodes map[string]common.Geo
+}
+
+// DefaultGazetteer covers the venues and suburbs around Sydney that our sources list
+func DefaultGazetteer() Gazetteer {
+	gazetteer, err := ParseGazetteer(strings.NewReader(defaultGazetteer))
+	if err != nil {
+		panic(fmt.Sprintf("embedded gazetteer: %s", err.Error()))
+	}
+	return gazetteer
+}
+
+func LoadGazetteer(path string) (Gazetteer, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Gazetteer{}, err
+	}
+	defer file.Close()
+	return ParseGazetteer(file)
+}
+
+func ParseGazetteer(r io.Reader) (Gazetteer, error) {
+	result := Gazetteer{
+		venues:    map[string]common.Geo{},
+		suburbs:   map[string]common.Geo{},
+		postcodes: map[string]common.Geo{},
+	}
+	fallbackPostcodes := map[string]common.Geo{}
+
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = 6
+	records, err := reader.ReadAll()
+	if err != nil {
+		return Gazetteer{}, err
+	}
+	for i, record := range records {
+		if i == 0 && record[0] == "kind" {
+			continue
+		}
+		kind, name, postcode, region := record[0], normalize(record[1]), normalize(record[2]), regionCode(record[3])
+		lat, latErr := strconv.ParseFloat(strings.TrimSpace(record[4]), 64)
+		lng, lngErr := strconv.ParseFloat(strings.TrimSpace(record[5]), 64)
+		if latErr != nil || lngErr != nil {
+			return Gazetteer{}, fmt.Errorf("line %d: invalid coordinates", i+1)
+		}
+		geo := common.Geo{Lat: lat, Lng: lng}
+
+		switch kind {
+		case "venue":
+			result.venues[name] = geo
+		case "suburb":
+			result.suburbs[name+"|"+region] = geo
+			if _, ok := result.suburbs[name]; !ok {
+				result.suburbs[name] = geo
+			}
+			if _, ok := fallbackPostcodes[postcode]; postcode != "" && !ok {
+				fallbackPostcodes[postcode] = geo
+			}
+		case "postcode":
+			result.postcodes[postcode] = geo
+		default:
+			return Gazetteer{}, fmt.Errorf("line %d: unknown kind %q", i+1, kind)
+		}
+	}
+	for postcode, geo := range fallbackPostcodes {
+		if _, ok := result.postcodes[postcode]; !ok {
+			result.postcodes[postcode] = geo
+		}
+	}
+	return result, nil
+}
+
+// Geocode matches the venue name first, then the suburb, then the postcode
+func (obj Gazetteer) Geocode(ctx context.Context, query Query) (Result, error) {
+	if geo, ok := obj.venues[normalize(query.Venue)]; ok {
+		return Result{Geo: geo, Precision: PrecisionVenue}, nil
+	}
+
+	locality := normalize(query.Address.Locality)
+	if locality != "" {
+		if geo, ok := obj.suburbs[locality+"|"+regionCode(query.Address.Region)]; ok {
+			return Result{Geo: geo, Precision: PrecisionSuburb}, nil
+		}
+		if geo, ok := obj.suburbs[locality]; ok && query.Address.Region == "" {
+			return Result{Geo: geo, Precision: PrecisionSuburb}, nil
+		}
+	}
+
+	if geo, ok := obj.postcodes[normalize(query.Address.PostCode)]; ok {
+		return Result{Geo: geo, Precision: PrecisionPostcode}, nil
+	}
+	return Result{}, ErrNotFound
+}
+
+var regionCodes = map[string]string{
+	"new south wales":              "nsw",
+	"victoria":                     "vic",
+	"queensland":                   "qld",
+	"south australia":              "sa",
+	"western australia":            "wa",
+	"tasmania":                     "tas",
+	"northern territory":           "nt",
+	"australian capital territory": "act",
+}
+
+// regionCode reduces state names to their abbreviation, the way sources mostly write them
+func regionCode(region string) string {
+	region = normalize(region)
+	if code, ok := regionCodes[region]; ok {
+		return code
+	}
+	return region
+}
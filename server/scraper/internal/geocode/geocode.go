@@ -0,0 +1,131 @@
+// Package geocode resolves venue names and addresses to coordinates, for events
+// whose source gives no location. A local gazetteer answers most lookups
+// offline; online providers fill the gaps behind the same Geocoder interface.
+package geocode
+
+import (
+	"common"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+)
+
+// ErrNotFound is returned when a geocoder has no answer for a query
+var ErrNotFound = errors.New("location not found")
+
+// Precision says how closely coordinates match the event location, from best to worst
+type Precision string
+
+const (
+	PrecisionSource   Precision = "source"   // given by the event source
+	PrecisionVenue    Precision = "venue"    // a known venue
+	PrecisionStreet   Precision = "street"   // the street address
+	PrecisionSuburb   Precision = "suburb"   // centre of the suburb or town
+	PrecisionPostcode Precision = "postcode" // centre of the postcode area
+)
+
+var ranks = map[Precision]int{
+	PrecisionSource:   4,
+	PrecisionVenue:    4,
+	PrecisionStreet:   3,
+	PrecisionSuburb:   2,
+	PrecisionPostcode: 1,
+}
+
+// Better reports whether p is more precise than other
+func (p Precision) Better(other Precision) bool {
+	return ranks[p] > ranks[other]
+}
+
+type Query struct {
+	Venue   string
+	Address common.Address
+}
+
+func (q Query) key() string {
+	parts := []string{q.Venue, q.Address.Line1, q.Address.Line2, q.Address.Locality, q.Address.PostCode, q.Address.Region, q.Address.Country}
+	for i, part := range parts {
+		parts[i] = normalize(part)
+	}
+	return strings.Join(parts, "|")
+}
+
+type Result struct {
+	Geo       common.Geo
+	Precision Precision
+}
+
+type Geocoder interface {
+	Geocode(ctx context.Context, query Query) (Result, error)
+}
+
+// Chain asks each geocoder in turn and keeps the most precise answer. It stops
+// at the first street or venue level result, so slower providers go last.
+type Chain []Geocoder
+
+func (obj Chain) Geocode(ctx context.Context, query Query) (Result, error) {
+	var best Result
+	var firstErr error
+	for _, geocoder := range obj {
+		result, err := geocoder.Geocode(ctx, query)
+		if err != nil {
+			if !errors.Is(err, ErrNotFound) && firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if result.Precision.Better(best.Precision) {
+			best = result
+		}
+		if !PrecisionStreet.Better(best.Precision) {
+			break
+		}
+	}
+	if best.Precision != "" {
+		return best, nil
+	}
+	if firstErr != nil {
+		return Result{}, firstErr
+	}
+	return Result{}, ErrNotFound
+}
+
+// Cache remembers the answers of a geocoder, misses included, for the life of the process
+type Cache struct {
+	geocoder Geocoder
+	mu       sync.Mutex
+	results  map[string]cached
+}
+
+type cached struct {
+	result Result
+	err    error
+}
+
+func NewCache(geocoder Geocoder) *Cache {
+	return &Cache{geocoder: geocoder, results: map[string]cached{}}
+}
+
+func (obj *Cache) Geocode(ctx context.Context, query Query) (Result, error) {
+	key := query.key()
+	obj.mu.Lock()
+	entry, ok := obj.results[key]
+	obj.mu.Unlock()
+	if ok {
+		return entry.result, entry.err
+	}
+
+	result, err := obj.geocoder.Geocode(ctx, query)
+	// provider outages are not cached, so a later event can try again
+	if err == nil || errors.Is(err, ErrNotFound) {
+		obj.mu.Lock()
+		obj.results[key] = cached{result: result, err: err}
+		obj.mu.Unlock()
+	}
+	return result, err
+}
+
+func normalize(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
@@ -0,0 +1,106 @@
+package geocode
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"golang.org/x/time/rate"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// DefaultNominatimURL is the public OpenStreetMap instance. Its usage policy
+// allows one request per second and requires an identifying user agent.
+const DefaultNominatimURL = "https://nominatim.openstreetmap.org"
+
+// Nominatim geocodes street addresses online with an OpenStreetMap Nominatim server
+type Nominatim struct {
+	baseURL   string
+	userAgent string
+	client    *http.Client
+	limiter   *rate.Limiter
+}
+
+func NewNominatim(baseURL string, userAgent string, client *http.Client) Nominatim {
+	return Nominatim{
+		baseURL:   baseURL,
+		userAgent: userAgent,
+		client:    client,
+		limiter:   rate.NewLimiter(rate.Every(time.Second), 1),
+	}
+}
+
+type nominatimPlace struct {
+	Lat         string `json:"lat"`
+	Lon         string `json:"lon"`
+	AddressType string `json:"addresstype"`
+}
+
+// Geocode runs a structured search on the address. The venue name is left out,
+// Nominatim matches it poorly and the gazetteer handles venues.
+func (obj Nominatim) Geocode(ctx context.Context, query Query) (Result, error) {
+	address := query.Address
+	if address.Line1 == "" && address.Locality == "" && address.PostCode == "" {
+		return Result{}, ErrNotFound
+	}
+
+	params := url.Values{}
+	params.Set("format", "jsonv2")
+	params.Set("limit", "1")
+	params.Set("countrycodes", "au")
+	setIf(params, "street", address.Line1)
+	setIf(params, "city", address.Locality)
+	setIf(params, "postalcode", address.PostCode)
+	setIf(params, "state", address.Region)
+
+	if err := obj.limiter.Wait(ctx); err != nil {
+		return Result{}, err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, obj.baseURL+"/search?"+params.Encode(), nil)
+	if err != nil {
+		return Result{}, err
+	}
+	request.Header.Set("User-Agent", obj.userAgent)
+	response, err := obj.client.Do(request)
+	if err != nil {
+		return Result{}, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return Result{}, fmt.Errorf("nominatim returned %s", response.Status)
+	}
+
+	var places []nominatimPlace
+	if err := json.NewDecoder(response.Body).Decode(&places); err != nil {
+		return Result{}, err
+	}
+	if len(places) == 0 {
+		return Result{}, ErrNotFound
+	}
+	lat, latErr := strconv.ParseFloat(places[0].Lat, 64)
+	lng, lngErr := strconv.ParseFloat(places[0].Lon, 64)
+	if latErr != nil || lngErr != nil {
+		return Result{}, fmt.Errorf("nominatim returned invalid coordinates %q, %q", places[0].Lat, places[0].Lon)
+	}
+
+	result := Result{Precision: PrecisionStreet}
+	result.Geo.Lat, result.Geo.Lng = lat, lng
+	switch places[0].AddressType {
+	case "suburb", "city", "town", "village", "hamlet", "neighbourhood", "quarter", "municipality":
+		result.Precision = PrecisionSuburb
+	case "postcode":
+		result.Precision = PrecisionPostcode
+	case "county", "state", "country":
+		// too coarse to place an event on a map
+		return Result{}, ErrNotFound
+	}
+	return result, nil
+}
+
+func setIf(params url.Values, key string, value string) {
+	if value != "" {
+		params.Set(key, value)
+	}
+}
@@ -0,0 +1,162 @@
+// Package images copies event images into our own blob store, so events do not
+// depend on remote CDNs that break or block hotlinking.
+//
+// Every image is stored under the hash of its content, so the same picture
+// used by several events or served from several URLs is stored once:
+//
+//	images/<hash>.<ext>        the original bytes
+//	images/<hash>.webp         full size WebP
+//	images/<hash>-w<N>.webp    thumbnails N pixels wide
+package images
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/HugoSmits86/nativewebp"
+	"github.com/rs/zerolog"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"scraper/internal/fetch"
+	"strings"
+	"sync"
+)
+
+const keyPrefix = "images/"
+
+// DefaultWidths are the thumbnail widths generated for each image
+var DefaultWidths = []int{320, 960}
+
+// images larger than this are stored as they are, without variants
+const maxPixels = 40_000_000
+
+var extensions = map[string]string{
+	"jpeg": ".jpg",
+	"png":  ".png",
+	"gif":  ".gif",
+	"webp": ".webp",
+}
+
+type Ingester struct {
+	store   BlobStore
+	fetcher *fetch.Fetcher
+	widths  []int
+	known   *sync.Map // remote URL -> stable URL, for images seen by this process
+	logger  zerolog.Logger
+}
+
+func NewIngester(store BlobStore, fetcher *fetch.Fetcher, logger zerolog.Logger) Ingester {
+	return Ingester{
+		store:   store,
+		fetcher: fetcher,
+		widths:  DefaultWidths,
+		known:   &sync.Map{},
+		logger:  logger,
+	}
+}
+
+// Ingest copies the images at urls into the store and returns their stable
+// URLs, in order and without duplicates. An image that cannot be copied keeps
+// its remote URL, so a broken image never fails the event.
+func (obj Ingester) Ingest(ctx context.Context, urls []string) []string {
+	result := make([]string, 0, len(urls))
+	seen := map[string]bool{}
+	for _, url := range urls {
+		stable, err := obj.ingest(ctx, url)
+		if err != nil {
+			obj.logger.Warn().Msgf("Keeping remote image %s: %s", url, err.Error())
+			stable = url
+		}
+		if !seen[stable] {
+			seen[stable] = true
+			result = append(result, stable)
+		}
+	}
+	return result
+}
+
+func (obj Ingester) ingest(ctx context.Context, url string) (string, error) {
+	if stable, ok := obj.known.Load(url); ok {
+		return stable.(string), nil
+	}
+	if strings.HasPrefix(url, obj.store.URL(keyPrefix)) {
+		return url, nil
+	}
+
+	response, err := obj.fetcher.Get(ctx, url)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(response.Body)
+	hash := hex.EncodeToString(sum[:])
+
+	config, format, err := image.DecodeConfig(bytes.NewReader(response.Body))
+	if err != nil {
+		return "", fmt.Errorf("not a supported image: %w", err)
+	}
+	originalKey := keyPrefix + hash + extensions[format]
+
+	// the original goes in last, so once it exists every variant does too
+	exists, err := obj.store.Exists(ctx, originalKey)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		if config.Width*config.Height <= maxPixels {
+			if err := obj.putVariants(ctx, hash, response.Body); err != nil {
+				return "", err
+			}
+		}
+		if err := obj.store.Put(ctx, originalKey, response.Body, "image/"+format); err != nil {
+			return "", err
+		}
+		obj.logger.Debug().Msgf("Stored image %s as %s", url, originalKey)
+	}
+
+	stable := obj.store.URL(originalKey)
+	obj.known.Store(url, stable)
+	return stable, nil
+}
+
+func (obj Ingester) putVariants(ctx context.Context, hash string, data []byte) error {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	if err := obj.putWebP(ctx, keyPrefix+hash+".webp", img); err != nil {
+		return err
+	}
+	for _, width := range obj.widths {
+		if width >= img.Bounds().Dx() {
+			continue
+		}
+		if err := obj.putWebP(ctx, fmt.Sprintf("%s%s-w%d.webp", keyPrefix, hash, width), resize(img, width)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (obj Ingester) putWebP(ctx context.Context, key string, img image.Image) error {
+	var buf bytes.Buffer
+	if err := nativewebp.Encode(&buf, img, nil); err != nil {
+		return fmt.Errorf("encoding %s: %w", key, err)
+	}
+	return obj.store.Put(ctx, key, buf.Bytes(), "image/webp")
+}
+
+// resize scales img to width, keeping its aspect ratio
+func resize(img image.Image, width int) image.Image {
+	bounds := img.Bounds()
+	height := max(1, bounds.Dy()*width/bounds.Dx())
+	result := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(result, result.Bounds(), img, bounds, draw.Src, nil)
+	return result
+}
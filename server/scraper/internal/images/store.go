@@ -0,0 +1,125 @@
+package images
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// BlobStore keeps image files under a key and serves them from a stable URL
+type BlobStore interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	Exists(ctx context.Context, key string) (bool, error)
+	URL(key string) string
+}
+
+// FileStore writes blobs under a local directory, typically served by a static file server
+type FileStore struct {
+	dir     string
+	baseURL string
+}
+
+func NewFileStore(dir string, baseURL string) FileStore {
+	return FileStore{dir: dir, baseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+func (obj FileStore) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	path := filepath.Join(obj.dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	// write then rename, so a reader never sees half a file
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".blob-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (obj FileStore) Exists(ctx context.Context, key string) (bool, error) {
+	_, err := os.Stat(filepath.Join(obj.dir, filepath.FromSlash(key)))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (obj FileStore) URL(key string) string {
+	return obj.baseURL + "/" + key
+}
+
+// S3Store writes blobs to a bucket on S3 or any S3-compatible service (MinIO, R2, ...)
+type S3Store struct {
+	client  *s3.Client
+	bucket  string
+	baseURL string
+}
+
+// NewS3Store uses the default AWS configuration. endpointURL is only needed for
+// S3-compatible services, which are addressed path-style. baseURL is where the
+// bucket is served from, e.g. a CDN in front of it.
+func NewS3Store(ctx context.Context, bucket string, region string, endpointURL string, baseURL string) (S3Store, error) {
+	cfg, err := config.LoadDefaultConfig(ctx, func(o *config.LoadOptions) error {
+		if region != "" {
+			o.Region = region
+		}
+		return nil
+	})
+	if err != nil {
+		return S3Store{}, fmt.Errorf("failed loading AWS config: %w", err)
+	}
+
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if endpointURL != "" {
+			o.BaseEndpoint = aws.String(endpointURL)
+			o.UsePathStyle = true
+		}
+	})
+	if baseURL == "" {
+		baseURL = fmt.Sprintf("https://%s.s3.%s.amazonaws.com", bucket, cfg.Region)
+	}
+	return S3Store{client: client, bucket: bucket, baseURL: strings.TrimSuffix(baseURL, "/")}, nil
+}
+
+func (obj S3Store) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	_, err := obj.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:       aws.String(obj.bucket),
+		Key:          aws.String(key),
+		Body:         bytes.NewReader(data),
+		ContentType:  aws.String(contentType),
+		CacheControl: aws.String("public, max-age=31536000, immutable"), // keys are content hashes
+	})
+	return err
+}
+
+func (obj S3Store) Exists(ctx context.Context, key string) (bool, error) {
+	_, err := obj.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(obj.bucket),
+		Key:    aws.String(key),
+	})
+	var notFound *types.NotFound
+	if errors.As(err, &notFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (obj S3Store) URL(key string) string {
+	return obj.baseURL + "/" + key
+}
@@ -0,0 +1,207 @@
+// Package scheduler runs jobs on cron schedules inside one long-running process.
+// A job never overlaps itself: when it is still running at its next due time,
+// that run is skipped.
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/robfig/cron/v3"
+	"github.com/rs/zerolog"
+	"math/rand/v2"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Job is a named task and when to run it
+type Job struct {
+	Name     string
+	Schedule string        // cron expression, e.g. "0 */6 * * *", or a descriptor such as "@hourly"
+	Jitter   time.Duration // random delay up to this long, so jobs due together do not all start at once
+	Run      func(ctx context.Context) error
+}
+
+type JobStatus struct {
+	Name       string    `json:"name"`
+	Schedule   string    `json:"schedule"`
+	Running    bool      `json:"running"`
+	NextRun    time.Time `json:"next_run"`
+	LastStart  time.Time `json:"last_start,omitzero"`
+	LastFinish time.Time `json:"last_finish,omitzero"`
+	LastError  string    `json:"last_error,omitempty"`
+	Runs       int       `json:"runs"`
+	Failures   int       `json:"failures"`
+	Overlaps   int       `json:"overlaps"` // runs skipped because the previous one was still going
+}
+
+type entry struct {
+	job      Job
+	schedule cron.Schedule
+	status   JobStatus
+}
+
+type Scheduler struct {
+	mu              sync.Mutex
+	entries         map[string]*entry
+	running         sync.WaitGroup
+	shutdownTimeout time.Duration
+	logger          zerolog.Logger
+}
+
+// NewScheduler returns a scheduler that gives running jobs shutdownTimeout to
+// finish once it is stopped, before cancelling them
+func NewScheduler(shutdownTimeout time.Duration, logger zerolog.Logger) *Scheduler {
+	return &Scheduler{
+		entries:         map[string]*entry{},
+		shutdownTimeout: shutdownTimeout,
+		logger:          logger,
+	}
+}
+
+// Add registers job; it fails on an invalid schedule or a name already in use
+func (obj *Scheduler) Add(job Job) error {
+	schedule, err := cron.ParseStandard(job.Schedule)
+	if err != nil {
+		return fmt.Errorf("job %s: invalid schedule %q: %w", job.Name, job.Schedule, err)
+	}
+
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+	if _, ok := obj.entries[job.Name]; ok {
+		return fmt.Errorf("job %s added twice", job.Name)
+	}
+	obj.entries[job.Name] = &entry{job: job, schedule: schedule, status: JobStatus{Name: job.Name, Schedule: job.Schedule}}
+	return nil
+}
+
+// Run starts jobs when they are due until ctx is done, then waits for running
+// jobs to finish. Jobs get their own context, cancelled only when they overrun
+// the shutdown timeout.
+func (obj *Scheduler) Run(ctx context.Context) error {
+	jobCtx, cancelJobs := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelJobs()
+
+	obj.mu.Lock()
+	entries := make([]*entry, 0, len(obj.entries))
+	for _, e := range obj.entries {
+		entries = append(entries, e)
+	}
+	obj.mu.Unlock()
+
+	var loops sync.WaitGroup
+	for _, e := range entries {
+		loops.Add(1)
+		go func() {
+			defer loops.Done()
+			obj.loop(ctx, jobCtx, e)
+		}()
+	}
+	obj.logger.Info().Msgf("Scheduler started with %d jobs", len(entries))
+	loops.Wait()
+
+	obj.logger.Info().Msg("Scheduler stopping, waiting for running jobs")
+	done := make(chan struct{})
+	go func() {
+		obj.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(obj.shutdownTimeout):
+		obj.logger.Warn().Msgf("Jobs still running after %s, cancelling them", obj.shutdownTimeout)
+		cancelJobs()
+		<-done
+	}
+	return nil
+}
+
+// loop waits for each due time of one job until ctx is done
+func (obj *Scheduler) loop(ctx context.Context, jobCtx context.Context, e *entry) {
+	for {
+		next := e.schedule.Next(time.Now())
+		if e.job.Jitter > 0 {
+			next = next.Add(rand.N(e.job.Jitter))
+		}
+		obj.update(e, func(status *JobStatus) { status.NextRun = next })
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		obj.start(jobCtx, e)
+	}
+}
+
+// start runs the job in the background unless it is still running
+func (obj *Scheduler) start(ctx context.Context, e *entry) {
+	obj.mu.Lock()
+	if e.status.Running {
+		e.status.Overlaps++
+		obj.mu.Unlock()
+		obj.logger.Warn().Msgf("Job %s is still running, skipping this run", e.job.Name)
+		return
+	}
+	e.status.Running = true
+	e.status.LastStart = time.Now()
+	obj.running.Add(1)
+	obj.mu.Unlock()
+
+	go func() {
+		defer obj.running.Done()
+		obj.logger.Info().Msgf("Job %s started", e.job.Name)
+		err := e.job.Run(ctx)
+		obj.update(e, func(status *JobStatus) {
+			status.Running = false
+			status.LastFinish = time.Now()
+			status.Runs++
+			status.LastError = ""
+			if err != nil {
+				status.Failures++
+				status.LastError = err.Error()
+			}
+		})
+		if err != nil {
+			obj.logger.Error().Msgf("Job %s failed: %s", e.job.Name, err.Error())
+		} else {
+			obj.logger.Info().Msgf("Job %s finished", e.job.Name)
+		}
+	}()
+}
+
+func (obj *Scheduler) update(e *entry, fn func(status *JobStatus)) {
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+	fn(&e.status)
+}
+
+// Status returns the state of every job, by name
+func (obj *Scheduler) Status() []JobStatus {
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+
+	result := make([]JobStatus, 0, len(obj.entries))
+	for _, e := range obj.entries {
+		result = append(result, e.status)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// Handler serves the job status as JSON on /status, and answers /healthz
+func (obj *Scheduler) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"jobs": obj.Status()})
+	})
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok\n"))
+	})
+	return mux
+}
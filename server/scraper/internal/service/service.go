@@ -3,10 +3,18 @@ package service
 import (
 	"common"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"os"
+	"scraper/internal/fetch"
+	"scraper/internal/geocode"
+	"scraper/internal/images"
+	"scraper/internal/transfer"
 	"scraper/internal/venuescrapers"
+	"strings"
 	"time"
 )
 
@@ -23,65 +31,562 @@ type Service struct {
 	logger    zerolog.Logger
 }
 
-func NewService(dynamoURL string, region string) *Service {
+type Options struct {
+	Fetch      fetch.Config
+	ImageStore images.BlobStore          // nil leaves images remote
+	Geocoder   geocode.Geocoder          // nil keeps the built-in gazetteer
+	Stages     []venuescrapers.StageSpec // nil runs venuescrapers.DefaultStages
+	NotifyURL  string                    // webhook for the notify stage
+}
+
+func NewService(dynamoURL string, region string, options Options) (*Service, error) {
 	logger := log.Output(zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: time.RFC3339, NoColor: true})
 	var dbLayer, _ = common.NewDb(dynamoURL, region, logger)
+	fetcher := fetch.NewFetcher(options.Fetch, logger)
+	tagger := venuescrapers.NewTagger(dbLayer, logger)
+
+	pipeline := venuescrapers.NewPipeline(dbLayer, fetcher, logger).
+		WithTagger(tagger).
+		WithQuarantine(venuescrapers.NewTableQuarantine(dbLayer))
+	if options.ImageStore != nil {
+		pipeline = pipeline.WithImages(images.NewIngester(options.ImageStore, fetcher, logger))
+	}
+	if options.Geocoder != nil {
+		pipeline = pipeline.WithGeocoder(options.Geocoder)
+	}
+	if options.NotifyURL != "" {
+		pipeline = pipeline.WithNotifier(venuescrapers.NewWebhookNotifier(options.NotifyURL, fetcher.Client()))
+	}
+	if options.Stages != nil {
+		var err error
+		if pipeline, err = pipeline.WithStages(options.Stages); err != nil {
+			return nil, err
+		}
+	}
+
 	service := &Service{
 		dbLayer:  dbLayer,
-		pipeline: venuescrapers.NewPipeline(dbLayer, logger),
-		tagger:   venuescrapers.NewTagger(dbLayer, logger),
+		pipeline: pipeline,
+		tagger:   tagger,
 		logger:   logger,
 	}
 
-	return service
+	return service, nil
+}
+
+// sourcesToScrape resolves venue (a source ID, a source type, or "all"/empty)
+// against the active Sources entries, falling back to the built-in defaults
+// for source types that have no entry
+func (s Service) sourcesToScrape(venue string) []common.Source {
+	stored, err := s.dbLayer.QueryActiveSources()
+	if err != nil {
+		s.logger.Warn().Msgf("Could not read sources, using defaults: %s", err.Error())
+	}
+
+	configured := map[common.SourceType]bool{}
+	for _, source := range stored {
+		configured[source.SourceType] = true
+	}
+	all := stored
+	for _, source := range s.pipeline.DefaultSources() {
+		if !configured[source.SourceType] {
+			all = append(all, s.withCheckpoint(source))
+		}
+	}
+	if venue == "" || venue == "all" {
+		return all
+	}
+
+	var result []common.Source
+	for _, source := range all {
+		if source.SourceID == venue || string(source.SourceType) == venue {
+			result = append(result, source)
+		}
+	}
+	if len(result) == 0 {
+		// not scraped by default, but a scraper may still exist for it
+		result = append(result, s.withCheckpoint(venuescrapers.DefaultSource(common.SourceType(venue))))
+	}
+	return result
+}
+
+// sourcesToRun is sourcesToScrape without the sources whose events are pushed to us
+func (s Service) sourcesToRun(venue string) []common.Source {
+	var result []common.Source
+	for _, source := range s.sourcesToScrape(venue) {
+		if !source.Pushed() {
+			result = append(result, source)
+		}
+	}
+	return result
+}
+
+// withCheckpoint picks up the checkpoint of a built-in source that has no full
+// Sources entry yet
+func (s Service) withCheckpoint(source common.Source) common.Source {
+	stored, err := s.dbLayer.QuerySourceBySourceID(source.SourceID)
+	if err != nil {
+		s.logger.Warn().Msgf("Could not read checkpoint of source %s: %s", source.SourceID, err.Error())
+	} else if stored != nil {
+		source.Checkpoint = stored.Checkpoint
+	}
+	return source
+}
+
+// saveCheckpoint stores the checkpoint of source. A built-in source gets a full
+// entry the first time, so it is listed and scraped as configured from then on.
+func (s Service) saveCheckpoint(source common.Source, checkpoint common.SourceCheckpoint) error {
+	stored, err := s.dbLayer.QuerySourceBySourceID(source.SourceID)
+	if err != nil {
+		return err
+	}
+	// entries without a type only ever held a checkpoint
+	if stored == nil || stored.SourceType == "" {
+		source.Checkpoint = checkpoint
+		return s.dbLayer.WriteSource(source)
+	}
+	return s.dbLayer.UpdateSourceCheckpoint(source.SourceID, checkpoint)
 }
 
-func (s Service) LoadEvents(venue string) error {
-	err := s.pipeline.Scrape(common.SourceType(venue))
+// LoadEvents scrapes the sources matching venue concurrently and records a run
+// per source. Unless full is set, sources scraped before stop at events they
+// already know.
+func (s Service) LoadEvents(ctx context.Context, venue string, full bool) ([]common.ScrapeRun, error) {
+	sources := s.sourcesToRun(venue)
+	byID := map[string]common.Source{}
+	for _, source := range sources {
+		byID[source.SourceID] = source
+	}
+	reports, err := s.pipeline.ScrapeAll(ctx, sources, venuescrapers.ScrapeOptions{Full: full})
+	s.pipeline.LogFetchMetrics()
+	s.pipeline.LogStageMetrics()
+
+	runs := make([]common.ScrapeRun, 0, len(reports))
+	for _, report := range reports {
+		if report.Checkpoint != nil {
+			if err := s.saveCheckpoint(byID[report.SourceID], *report.Checkpoint); err != nil {
+				s.logger.Error().Msgf("Could not save checkpoint of source %s: %s", report.SourceID, err.Error())
+			}
+		}
+		runs = append(runs, s.recordRun(report))
+	}
 	if err != nil {
 		s.logger.Error().Msg(err.Error())
 	}
 
-	return nil
+	return runs, nil
 }
 
-func (s Service) tagEventsForSource(source string) error {
-	s.logger.Info().Msg("Tagging events for source " + source)
+type DryRunOptions struct {
+	Format string // venuescrapers.FormatJSONLines or venuescrapers.FormatTable
+	Output string // file to write to, stdout when empty
+	Diff   bool   // compare each event with the stored copy
+}
 
-	events, err := s.dbLayer.QueryUntaggedEvents(source)
-	s.logger.Info().Msgf("Found %d untagged events", len(events))
+// DryRun scrapes the sources matching venue in full and writes the events out
+// instead of storing them. Nothing is written to the database, checkpoints and
+// runs included.
+func (s Service) DryRun(ctx context.Context, venue string, options DryRunOptions) ([]common.ScrapeRun, error) {
+	out := os.Stdout
+	if options.Output != "" {
+		file, err := os.Create(options.Output)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		out = file
+	}
 
-	if err == nil {
-		slices := len(events) / 10
-		for i := 0; i <= slices; i++ {
-			start := i * 10
-			end := start + 10
-			if end > len(events) {
-				end = len(events)
+	var reference venuescrapers.EventStore
+	if options.Diff {
+		reference = s.dbLayer
+	}
+	sink, err := venuescrapers.NewSink(out, options.Format, reference)
+	if err != nil {
+		return nil, err
+	}
+
+	pipeline := s.pipeline.WithStore(sink).WithQuarantine(sink)
+	reports, err := pipeline.ScrapeAll(ctx, s.sourcesToRun(venue), venuescrapers.ScrapeOptions{Full: true})
+	if flushErr := sink.Flush(); flushErr != nil {
+		return nil, flushErr
+	}
+	pipeline.LogFetchMetrics()
+
+	runs := make([]common.ScrapeRun, 0, len(reports))
+	for _, report := range reports {
+		runs = append(runs, report.Run())
+	}
+	return runs, err
+}
+
+// recordRun checks a run against the source's recent history and stores it
+func (s Service) recordRun(report *venuescrapers.ScrapeReport) common.ScrapeRun {
+	run := report.Run()
+	history, err := s.dbLayer.QueryRecentScrapeRuns(run.SourceID, venuescrapers.AnomalyHistory)
+	if err != nil {
+		s.logger.Warn().Msgf("Could not read previous runs of source %s: %s", run.SourceID, err.Error())
+	}
+	venuescrapers.FlagAnomaly(&run, history)
+
+	s.logger.Info().Msgf("Source %s: %d pages, %d events found, %d new, %d updated, %d failed, %d URLs skipped, full run: %t, stopped early: %t",
+		run.SourceID, run.PagesFetched, run.EventsFound, run.EventsNew, run.EventsUpdated, run.EventsFailed, run.URLsSkipped, run.Full, run.StoppedEarly)
+	if run.Anomaly {
+		s.logger.Warn().Msgf("Source %s may be broken: %s", run.SourceID, run.AnomalyReason)
+	}
+
+	if err := s.dbLayer.WriteScrapeRun(run); err != nil {
+		s.logger.Error().Msgf("Could not save run of source %s: %s", run.SourceID, err.Error())
+	}
+	return run
+}
+
+// ListQuarantined returns the quarantined events of a source, or of every source when source is empty
+func (s Service) ListQuarantined(source string) ([]common.QuarantinedEvent, error) {
+	return s.dbLayer.QueryQuarantinedEvents(source)
+}
+
+// FixQuarantined overwrites fields of a quarantined event with fix, a partial
+// event in JSON such as {"Title": "..."}. The event stays quarantined until resubmitted.
+func (s Service) FixQuarantined(quarantineID string, fix json.RawMessage) (common.QuarantinedEvent, error) {
+	entry, err := s.quarantined(quarantineID)
+	if err != nil {
+		return common.QuarantinedEvent{}, err
+	}
+
+	var event common.Event
+	if err := json.Unmarshal([]byte(entry.Payload), &event); err != nil {
+		return common.QuarantinedEvent{}, fmt.Errorf("quarantined event %s: %w", quarantineID, err)
+	}
+	if err := json.Unmarshal(fix, &event); err != nil {
+		return common.QuarantinedEvent{}, fmt.Errorf("invalid fix: %w", err)
+	}
+	if common.QuarantineID(event.Source_name, event.SourceEvent) != quarantineID {
+		return common.QuarantinedEvent{}, errors.New("a fix cannot change the source of an event")
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return common.QuarantinedEvent{}, err
+	}
+	entry.Payload = string(payload)
+	entry.FixedAt = time.Now().UTC()
+	return entry, s.dbLayer.WriteQuarantinedEvent(entry)
+}
+
+// ResubmitQuarantined runs quarantined events through the pipeline again: the
+// one with quarantineID, or else every one of source (all sources when empty).
+// Events that pass leave the quarantine; it returns those that are still in it.
+func (s Service) ResubmitQuarantined(ctx context.Context, quarantineID string, source string) ([]common.QuarantinedEvent, error) {
+	var entries []common.QuarantinedEvent
+	if quarantineID != "" {
+		entry, err := s.quarantined(quarantineID)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	} else {
+		var err error
+		if entries, err = s.dbLayer.QueryQuarantinedEvents(source); err != nil {
+			return nil, err
+		}
+	}
+
+	var remaining []common.QuarantinedEvent
+	for _, entry := range entries {
+		var event common.Event
+		if err := json.Unmarshal([]byte(entry.Payload), &event); err != nil {
+			s.logger.Error().Msgf("Unreadable quarantined event %s: %s", entry.QuarantineID, err.Error())
+			remaining = append(remaining, entry)
+			continue
+		}
+
+		_, err := s.pipeline.Process(ctx, event)
+		if err != nil && !errors.Is(err, venuescrapers.ErrDuplicateEvent) {
+			s.logger.Warn().Msgf("Quarantined event %s still fails: %s", entry.QuarantineID, err.Error())
+			// the pipeline may have quarantined it again with new reasons
+			if updated, readErr := s.dbLayer.QueryQuarantinedEvent(entry.QuarantineID); readErr == nil && updated != nil {
+				entry = *updated
 			}
-			batch := events[start:end]
-			s.logger.Info().Msgf("Tagging batch %d: %d events\n", i, len(batch))
-			err = s.tagger.Tag(batch)
+			remaining = append(remaining, entry)
+			continue
+		}
+
+		s.logger.Info().Msgf("Released %s from quarantine", entry.QuarantineID)
+		if err := s.dbLayer.DeleteQuarantinedEvent(entry.QuarantineID); err != nil {
+			return remaining, err
+		}
+	}
+	return remaining, nil
+}
+
+func (s Service) quarantined(quarantineID string) (common.QuarantinedEvent, error) {
+	entry, err := s.dbLayer.QueryQuarantinedEvent(quarantineID)
+	if err != nil {
+		return common.QuarantinedEvent{}, err
+	}
+	if entry == nil {
+		return common.QuarantinedEvent{}, fmt.Errorf("no quarantined event %s", quarantineID)
+	}
+	return *entry, nil
+}
+
+// ListSources returns every Sources entry, active or not, followed by the
+// built-in sources that have none
+func (s Service) ListSources() ([]common.Source, error) {
+	stored, err := s.dbLayer.QuerySources()
+	if err != nil {
+		return nil, err
+	}
+
+	configured := map[common.SourceType]bool{}
+	for _, source := range stored {
+		configured[source.SourceType] = true
+	}
+	all := stored
+	for _, source := range s.pipeline.DefaultSources() {
+		if !configured[source.SourceType] {
+			all = append(all, s.withCheckpoint(source))
+		}
+	}
+	return all, nil
+}
+
+// Ingest runs events pushed for a push source, or approved submissions of a
+// user source, through the pipeline and records the run
+func (s Service) Ingest(ctx context.Context, sourceID string, events []common.IngestEvent) (common.ScrapeRun, error) {
+	source, err := s.dbLayer.QuerySourceBySourceID(sourceID)
+	if err != nil {
+		return common.ScrapeRun{}, err
+	}
+	if source == nil || !source.Pushed() {
+		return common.ScrapeRun{}, fmt.Errorf("no push or user source %s", sourceID)
+	}
+	if !source.Active {
+		return common.ScrapeRun{}, fmt.Errorf("source %s is not active", sourceID)
+	}
+
+	report := s.pipeline.Ingest(ctx, *source, events)
+	s.pipeline.LogStageMetrics()
+	// batch sizes are up to the pusher, so runs are not checked for anomalies
+	run := report.Run()
+	if err := s.dbLayer.WriteScrapeRun(run); err != nil {
+		s.logger.Error().Msgf("Could not save run of source %s: %s", run.SourceID, err.Error())
+	}
+	return run, nil
+}
+
+// IssueAPIKey gives a push source a new API key, replacing any previous one, and
+// returns it. The source is created when it has no entry yet.
+func (s Service) IssueAPIKey(sourceID string, name string) (string, error) {
+	key, hash, err := common.NewAPIKey(sourceID)
+	if err != nil {
+		return "", err
+	}
+	source, err := s.dbLayer.QuerySourceBySourceID(sourceID)
+	if err != nil {
+		return "", err
+	}
+	if source == nil {
+		if name == "" {
+			name = sourceID
+		}
+		err = s.dbLayer.WriteSource(common.Source{SourceID: sourceID, Name: name, SourceType: common.Push, Active: true, APIKeyHash: hash})
+		return key, err
+	}
+	if source.SourceType != common.Push {
+		return "", fmt.Errorf("source %s is a %s source, only push sources take API keys", sourceID, source.SourceType)
+	}
+	return key, s.dbLayer.UpdateSourceAPIKeyHash(sourceID, hash)
+}
+
+type ImportOptions struct {
+	Format  string            // transfer.FormatCSV or transfer.FormatJSONLines, from the file extension when empty
+	Mapping map[string]string // CSV only: field -> column of the file
+	Source  string            // source ID of rows that do not name one
+	Check   bool              // only read and validate the file
+}
+
+// Import reads events from file and runs them through the pipeline, as one run
+// per source. Rows that cannot be read are returned, the rest are imported. A
+// source ID with no entry gets a push source.
+func (s Service) Import(ctx context.Context, file string, options ImportOptions) ([]common.ScrapeRun, []transfer.RowError, error) {
+	format := options.Format
+	if format == "" {
+		format = transfer.FormatOf(file)
+	}
+	reader, err := transfer.NewReader(format, options.Mapping, options.Source)
+	if err != nil {
+		return nil, nil, err
+	}
+	in, err := os.Open(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer in.Close()
+	records, rejected, err := reader.Read(in)
+	if err != nil {
+		return nil, rejected, fmt.Errorf("%s: %w", file, err)
+	}
+	s.logger.Info().Msgf("Read %d events from %s, rejected %d rows", len(records), file, len(rejected))
+	if options.Check {
+		return nil, rejected, nil
+	}
+
+	var order []string
+	bySource := map[string][]common.IngestEvent{}
+	for _, record := range records {
+		if _, ok := bySource[record.Source]; !ok {
+			order = append(order, record.Source)
+		}
+		bySource[record.Source] = append(bySource[record.Source], record.Event)
+	}
+
+	var runs []common.ScrapeRun
+	for _, sourceID := range order {
+		source, err := s.importSource(sourceID)
+		if err != nil {
+			return runs, rejected, err
+		}
+		report := s.pipeline.Ingest(ctx, source, bySource[sourceID])
+		// imports are one-off batches, so like pushes they are not checked for anomalies
+		run := report.Run()
+		if err := s.dbLayer.WriteScrapeRun(run); err != nil {
+			s.logger.Error().Msgf("Could not save run of source %s: %s", run.SourceID, err.Error())
+		}
+		runs = append(runs, run)
+	}
+	s.pipeline.LogStageMetrics()
+	return runs, rejected, nil
+}
+
+// importSource returns the source events are imported for, creating a push source when it has no entry
+func (s Service) importSource(sourceID string) (common.Source, error) {
+	source, err := s.dbLayer.QuerySourceBySourceID(sourceID)
+	if err != nil {
+		return common.Source{}, err
+	}
+	if source != nil {
+		return *source, nil
+	}
+	for _, builtIn := range s.pipeline.DefaultSources() {
+		if builtIn.SourceID == sourceID {
+			return builtIn, nil
+		}
+	}
+	s.logger.Info().Msgf("Creating push source %s for imported events", sourceID)
+	created := common.Source{SourceID: sourceID, Name: sourceID, SourceType: common.Push, Active: true}
+	return created, s.dbLayer.WriteSource(created)
+}
+
+// Export writes the events matching filter to output (stdout when empty) in
+// format, and returns how many it wrote
+func (s Service) Export(filter common.EventFilter, format string, output string) (int, error) {
+	events, err := s.dbLayer.QueryEvents(filter)
+	if err != nil {
+		return 0, err
+	}
+	out := os.Stdout
+	if output != "" {
+		file, err := os.Create(output)
+		if err != nil {
+			return 0, err
+		}
+		defer file.Close()
+		out = file
+	}
+	s.logger.Info().Msgf("Exporting %d events", len(events))
+	return len(events), transfer.Write(out, format, events)
+}
+
+// MigrateSessionKeys rekeys the events of venue pages stored under the bare
+// page URL, from before every session was keyed by URL and start. An event whose
+// session was since stored again under its new key is deleted. It returns how
+// many events were rekeyed or deleted; with check set nothing is written.
+func (s Service) MigrateSessionKeys(check bool) (int, error) {
+	migrated := 0
+	for _, sourceType := range venuescrapers.SessionSources {
+		events, err := s.dbLayer.QueryEvents(common.EventFilter{Source: string(sourceType)})
+		if err != nil {
+			return migrated, err
+		}
+		for _, event := range events {
+			if strings.Contains(event.SourceEvent, "#") {
+				continue
+			}
+			key := venuescrapers.SessionKey(event.SourceEvent, event.Start)
+			existing, err := s.dbLayer.QueryEventsBySourceAndSourceEventID(event.Source_name, key)
 			if err != nil {
-				s.logger.Error().Msgf("Error tagging batch %d: %s\n", i, err.Error())
+				return migrated, err
+			}
+			migrated++
+			if check {
+				s.logger.Info().Msgf("Would rekey %s - %s as %s", event.Source_name, event.SourceEvent, key)
+				continue
+			}
+			if len(existing) > 0 {
+				s.logger.Info().Msgf("Deleting %s - %s, stored again as %s", event.Source_name, event.SourceEvent, key)
+				err = s.dbLayer.DeleteEvent(event.EventID)
+			} else {
+				s.logger.Info().Msgf("Rekeying %s - %s as %s", event.Source_name, event.SourceEvent, key)
+				event.SourceEvent = key
+				err = s.dbLayer.WriteEvent(event)
+			}
+			if err != nil {
+				return migrated, err
 			}
 		}
-	} else {
-		s.logger.Error().Msg(err.Error())
 	}
-	return err
+	return migrated, nil
 }
 
-func (s Service) TagEvents() error {
-	s.tagEventsForSource("metrotheatre")
-	s.tagEventsForSource("moshtix")
+const tagBatchSize = 10
 
-	return nil
+// TagEvents tags the untagged events of the sources matching source, in
+// batches, up to limit events when limit is positive. It returns how many
+// events were tagged.
+func (s Service) TagEvents(source string, limit int) (int, error) {
+	names := map[string]bool{}
+	tagged := 0
+	var errs []error
+	for _, candidate := range s.sourcesToScrape(source) {
+		name := venuescrapers.EventSourceName(candidate)
+		if names[name] {
+			continue
+		}
+		names[name] = true
+
+		events, err := s.dbLayer.QueryUntaggedEvents(name)
+		if err != nil {
+			s.logger.Error().Msg(err.Error())
+			errs = append(errs, err)
+			continue
+		}
+		if limit > 0 && len(events) > limit-tagged {
+			events = events[:limit-tagged]
+		}
+		s.logger.Info().Msgf("Tagging %d untagged events of source %s", len(events), name)
+
+		for start := 0; start < len(events); start += tagBatchSize {
+			batch := events[start:min(start+tagBatchSize, len(events))]
+			if err := s.tagger.Tag(batch); err != nil {
+				s.logger.Error().Msgf("Error tagging batch %d of source %s: %s", start/tagBatchSize, name, err.Error())
+				errs = append(errs, err)
+				continue
+			}
+			tagged += len(batch)
+		}
+		if limit > 0 && tagged >= limit {
+			break
+		}
+	}
+	return tagged, errors.Join(errs...)
 }
 
-func (obj Service) Purge() error {
+// Purge deletes the events that started before cutoff
+func (obj Service) Purge(cutoff time.Time) error {
 	obj.logger.Info().Msg("Purging old events")
-	return obj.dbLayer.PurgeOldEvents(time.Now())
+	return obj.dbLayer.PurgeOldEvents(cutoff)
 }
 
 func (s Service) CreateTables() error {
@@ -106,5 +611,20 @@ func (s Service) CreateTables() error {
 		s.logger.Fatal().Msgf("createSourcesTable failed: %v", err)
 	}
 	s.logger.Info().Msgf("Sources Table is ready")
+
+	if err := s.dbLayer.CreateScrapeRunsTable(); err != nil {
+		s.logger.Fatal().Msgf("createScrapeRunsTable failed: %v", err)
+	}
+	s.logger.Info().Msgf("ScrapeRuns Table is ready")
+
+	if err := s.dbLayer.CreateQuarantineTable(); err != nil {
+		s.logger.Fatal().Msgf("createQuarantineTable failed: %v", err)
+	}
+	s.logger.Info().Msgf("Quarantine Table is ready")
+
+	if err := s.dbLayer.CreateSubmissionsTable(); err != nil {
+		s.logger.Fatal().Msgf("createSubmissionsTable failed: %v", err)
+	}
+	s.logger.Info().Msgf("Submissions Table is ready")
 	return nil
 }
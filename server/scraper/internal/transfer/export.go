@@ -0,0 +1,103 @@
+package transfer
+
+import (
+	"common"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Write writes events to w in format, earliest first
+func Write(w io.Writer, format string, events []common.Event) error {
+	sorted := append([]common.Event(nil), events...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Start.Before(sorted[j].Start) })
+
+	switch format {
+	case FormatJSONLines:
+		encoder := json.NewEncoder(w)
+		for _, event := range sorted {
+			if err := encoder.Encode(jsonRecord{Source: event.Source_name, IngestEvent: ingestEvent(event)}); err != nil {
+				return err
+			}
+		}
+		return nil
+	case FormatCSV:
+		writer := csv.NewWriter(w)
+		if err := writer.Write(Columns); err != nil {
+			return err
+		}
+		for _, event := range sorted {
+			if err := writer.Write(csvRow(event)); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	}
+	return fmt.Errorf("unknown format %q, expected %s or %s", format, FormatCSV, FormatJSONLines)
+}
+
+// ingestEvent is the exported form of a stored event
+func ingestEvent(event common.Event) common.IngestEvent {
+	result := common.IngestEvent{
+		SourceEvent:  event.SourceEvent,
+		Title:        event.Title,
+		Description:  event.Description,
+		Start:        event.Start,
+		End:          event.End,
+		VenueName:    event.VenueName,
+		URL:          event.URL,
+		TicketURL:    event.TicketURL,
+		PriceMin:     event.PriceMin,
+		PriceMax:     event.PriceMax,
+		Images:       event.Images,
+		Categories:   event.Categories,
+		Tags:         event.Tags,
+		EighteenPlus: event.ContentFlags.EighteenPlus,
+		SexPositive:  event.ContentFlags.SexPositive,
+	}
+	if event.Address != (common.Address{}) {
+		address := common.IngestAddress(event.Address)
+		result.Address = &address
+	}
+	if event.Geo != (common.Geo{}) {
+		result.Geo = &common.IngestGeo{Lat: event.Geo.Lat, Lng: event.Geo.Lng}
+	}
+	if event.Attributes != (common.Attributes{}) {
+		attributes := event.Attributes
+		result.Attributes = &attributes
+	}
+	return result
+}
+
+func csvRow(event common.Event) []string {
+	end := ""
+	if !event.End.IsZero() {
+		end = event.End.UTC().Format(time.RFC3339)
+	}
+	lat, lng := "", ""
+	if event.Geo != (common.Geo{}) {
+		lat = strconv.FormatFloat(event.Geo.Lat, 'f', -1, 64)
+		lng = strconv.FormatFloat(event.Geo.Lng, 'f', -1, 64)
+	}
+	return []string{
+		event.Source_name, event.SourceEvent, event.Title, event.Description,
+		event.Start.UTC().Format(time.RFC3339), end, event.VenueName,
+		event.Address.Line1, event.Address.Line2, event.Address.PostCode,
+		event.Address.Locality, event.Address.Region, event.Address.Country, lat, lng,
+		event.URL, event.TicketURL,
+		strconv.FormatFloat(event.PriceMin, 'f', -1, 64), strconv.FormatFloat(event.PriceMax, 'f', -1, 64),
+		strings.Join(event.Images, listSeparator), strings.Join(event.Categories, listSeparator),
+		strings.Join(event.Tags, listSeparator),
+		strconv.FormatBool(event.ContentFlags.EighteenPlus), strconv.FormatBool(event.ContentFlags.SexPositive),
+		strconv.FormatBool(event.Attributes.AllAges), strconv.FormatBool(event.Attributes.Licensed),
+		strconv.FormatBool(event.Attributes.Wheelchair), strconv.FormatBool(event.Attributes.Auslan),
+		strconv.FormatBool(event.Attributes.Outdoor), strconv.FormatBool(event.Attributes.Free),
+		event.Attributes.Seating,
+	}
+}
@@ -0,0 +1,322 @@
+package transfer
+
+import (
+	"bufio"
+	"bytes"
+	"common"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"scraper/internal/dates"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// longest JSON line accepted
+const maxLine = 4 << 20
+
+// layouts tried before the free-form date parser, in Sydney time unless they carry a zone
+var layouts = []string{
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// jsonRecord is a JSON line: an event, and optionally the source it is for
+type jsonRecord struct {
+	Source string `json:"source,omitempty"`
+	common.IngestEvent
+}
+
+type Reader struct {
+	format        string
+	mapping       map[string]string // field -> CSV header
+	defaultSource string            // for rows without a source
+	dateParser    dates.Parser
+}
+
+// NewReader reads files in format. Rows without a source column are imported for defaultSource.
+func NewReader(format string, mapping map[string]string, defaultSource string) (Reader, error) {
+	if format != FormatCSV && format != FormatJSONLines {
+		return Reader{}, fmt.Errorf("unknown format %q, expected %s or %s", format, FormatCSV, FormatJSONLines)
+	}
+	if len(mapping) > 0 && format != FormatCSV {
+		return Reader{}, errors.New("column mappings only apply to CSV")
+	}
+	return Reader{
+		format:        format,
+		mapping:       mapping,
+		defaultSource: defaultSource,
+		dateParser:    dates.NewSydneyParser(),
+	}, nil
+}
+
+// Read returns the valid records of r and the rows it rejected. The error is
+// for files that cannot be read at all.
+func (obj Reader) Read(r io.Reader) ([]Record, []RowError, error) {
+	if obj.format == FormatJSONLines {
+		return obj.readJSONLines(r)
+	}
+	return obj.readCSV(r)
+}
+
+func (obj Reader) readJSONLines(r io.Reader) ([]Record, []RowError, error) {
+	var records []Record
+	var rejected []RowError
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLine)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var item jsonRecord
+		decoder := json.NewDecoder(bytes.NewReader(scanner.Bytes()))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&item); err != nil {
+			rejected = append(rejected, RowError{Line: line, Error: err.Error()})
+			continue
+		}
+		record, err := obj.record(item.Source, item.IngestEvent)
+		if err != nil {
+			rejected = append(rejected, RowError{Line: line, Error: err.Error()})
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, rejected, scanner.Err()
+}
+
+func (obj Reader) readCSV(r io.Reader) ([]Record, []RowError, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil, errors.New("empty file")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	columns, err := obj.columns(header)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var records []Record
+	var rejected []RowError
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		line, _ := reader.FieldPos(0)
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				rejected = append(rejected, RowError{Line: parseErr.Line, Error: parseErr.Err.Error()})
+				continue
+			}
+			return records, rejected, err
+		}
+		value := func(field string) string {
+			if i, ok := columns[field]; ok && i < len(row) {
+				return strings.TrimSpace(row[i])
+			}
+			return ""
+		}
+		if blank(row) {
+			continue
+		}
+		event, err := obj.csvEvent(value)
+		if err == nil {
+			var record Record
+			if record, err = obj.record(value("source"), event); err == nil {
+				records = append(records, record)
+				continue
+			}
+		}
+		rejected = append(rejected, RowError{Line: line, Error: err.Error()})
+	}
+	return records, rejected, nil
+}
+
+// columns resolves each field to its position in header
+func (obj Reader) columns(header []string) (map[string]int, error) {
+	positions := map[string]int{}
+	for i, name := range header {
+		positions[normalizeHeader(name)] = i
+	}
+	result := map[string]int{}
+	for _, field := range Columns {
+		if column, ok := obj.mapping[field]; ok {
+			i, found := positions[normalizeHeader(column)]
+			if !found {
+				return nil, fmt.Errorf("column %q mapped to %s is not in the file", column, field)
+			}
+			result[field] = i
+		} else if i, found := positions[field]; found {
+			result[field] = i
+		}
+	}
+	for _, field := range []string{"title", "start"} {
+		if _, ok := result[field]; !ok {
+			return nil, fmt.Errorf("no %s column, map one with %s=<column>", field, field)
+		}
+	}
+	return result, nil
+}
+
+func blank(row []string) bool {
+	for _, cell := range row {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func (obj Reader) csvEvent(value func(field string) string) (common.IngestEvent, error) {
+	event := common.IngestEvent{
+		SourceEvent:  value("source_event"),
+		Title:        value("title"),
+		Description:  value("description"),
+		VenueName:    value("venue_name"),
+		URL:          value("url"),
+		TicketURL:    value("ticket_url"),
+		Images:       list(value("images")),
+		Categories:   list(value("categories")),
+		Tags:         list(value("tags")),
+		EighteenPlus: flag(value("eighteen_plus")),
+		SexPositive:  flag(value("sex_positive")),
+	}
+
+	when, err := obj.parseTime(value("start"))
+	if err != nil {
+		return event, fmt.Errorf("start: %w", err)
+	}
+	event.Start, event.End = when.Start, when.End
+	if text := value("end"); text != "" {
+		end, err := obj.parseTime(text)
+		if err != nil {
+			return event, fmt.Errorf("end: %w", err)
+		}
+		event.End = end.Start
+	}
+
+	attributes := common.Attributes{
+		AllAges:    flag(value("all_ages")),
+		Licensed:   flag(value("licensed")),
+		Wheelchair: flag(value("wheelchair")),
+		Auslan:     flag(value("auslan")),
+		Outdoor:    flag(value("outdoor")),
+		Free:       flag(value("free")),
+		Seating:    strings.ToLower(value("seating")),
+	}
+	if attributes != (common.Attributes{}) {
+		event.Attributes = &attributes
+	}
+
+	address := common.IngestAddress{
+		Line1:    value("line1"),
+		Line2:    value("line2"),
+		PostCode: value("postcode"),
+		Locality: value("locality"),
+		Region:   value("region"),
+		Country:  value("country"),
+	}
+	if address != (common.IngestAddress{}) {
+		event.Address = &address
+	}
+
+	if value("lat") != "" || value("lng") != "" {
+		lat, err := strconv.ParseFloat(value("lat"), 64)
+		if err != nil {
+			return event, fmt.Errorf("lat: %w", err)
+		}
+		lng, err := strconv.ParseFloat(value("lng"), 64)
+		if err != nil {
+			return event, fmt.Errorf("lng: %w", err)
+		}
+		event.Geo = &common.IngestGeo{Lat: lat, Lng: lng}
+	}
+
+	for field, target := range map[string]*float64{"price_min": &event.PriceMin, "price_max": &event.PriceMax} {
+		if *target, err = price(value(field)); err != nil {
+			return event, fmt.Errorf("%s: %w", field, err)
+		}
+	}
+	return event, nil
+}
+
+// record completes an event read from a file and checks it can be imported
+func (obj Reader) record(source string, event common.IngestEvent) (Record, error) {
+	if source == "" {
+		source = obj.defaultSource
+	}
+	if source == "" {
+		return Record{}, errors.New("no source, give one with -source or a source column")
+	}
+	if event.SourceEvent == "" && !event.Start.IsZero() {
+		event.SourceEvent = derivedID(event)
+	}
+	if err := event.Check(); err != nil {
+		return Record{}, err
+	}
+	if !event.End.IsZero() && !event.End.After(event.Start) {
+		return Record{}, errors.New("end is not after start")
+	}
+	if event.PriceMax > 0 && event.PriceMax < event.PriceMin {
+		return Record{}, errors.New("price_max is below price_min")
+	}
+	return Record{Source: source, Event: event}, nil
+}
+
+// parseTime reads RFC3339, a few spreadsheet layouts, or whatever the listing
+// date parser understands
+func (obj Reader) parseTime(text string) (dates.Result, error) {
+	if text == "" {
+		return dates.Result{}, errors.New("missing")
+	}
+	if t, err := time.Parse(time.RFC3339, text); err == nil {
+		return dates.Result{Start: t, TimeKnown: true}, nil
+	}
+	location, _ := time.LoadLocation(dates.DefaultLocation)
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, text, location); err == nil {
+			return dates.Result{Start: t, TimeKnown: layout != "2006-01-02"}, nil
+		}
+	}
+	return obj.dateParser.Parse(text, time.Now())
+}
+
+func list(text string) []string {
+	var result []string
+	for _, item := range strings.Split(text, listSeparator) {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+func flag(text string) bool {
+	switch strings.ToLower(text) {
+	case "1", "true", "yes", "y", "x":
+		return true
+	}
+	return false
+}
+
+// price reads "25", "$25.50" or "free", empty being 0
+func price(text string) (float64, error) {
+	text = strings.TrimSpace(strings.TrimPrefix(strings.ToLower(text), "$"))
+	if text == "" || text == "free" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(strings.ReplaceAll(text, ",", ""), 64)
+}
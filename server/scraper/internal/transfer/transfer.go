@@ -0,0 +1,97 @@
+// Package transfer reads events from CSV and JSON lines files, for bulk import
+// through the pipeline, and writes stored events out in the same formats. An
+// exported file imports as is.
+package transfer
+
+import (
+	"common"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	FormatCSV       = "csv"
+	FormatJSONLines = "jsonl"
+	listSeparator   = "|" // between the items of a list column
+	derivedIDPrefix = "import-"
+	derivedIDLength = 16
+)
+
+// Columns are the fields of an event file, in export order. CSV headers are
+// matched to them case-insensitively, with spaces read as underscores.
+var Columns = []string{
+	"source", "source_event", "title", "description", "start", "end", "venue_name",
+	"line1", "line2", "postcode", "locality", "region", "country", "lat", "lng",
+	"url", "ticket_url", "price_min", "price_max", "images", "categories", "tags",
+	"eighteen_plus", "sex_positive", "all_ages", "licensed", "wheelchair", "auslan",
+	"outdoor", "free", "seating",
+}
+
+// Record is an event read from a file, with the source it is imported for
+type Record struct {
+	Source string
+	Event  common.IngestEvent
+}
+
+// RowError is a row that could not be imported
+type RowError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// FormatOf picks the format of a file from its extension, CSV unless it looks like JSON lines
+func FormatOf(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jsonl", ".ndjson", ".json":
+		return FormatJSONLines
+	}
+	return FormatCSV
+}
+
+// ParseMapping reads a column mapping such as "title=Event Name,start=Date"
+// into field -> CSV header
+func ParseMapping(text string) (map[string]string, error) {
+	result := map[string]string{}
+	if strings.TrimSpace(text) == "" {
+		return result, nil
+	}
+	for _, pair := range strings.Split(text, ",") {
+		field, column, ok := strings.Cut(pair, "=")
+		field = strings.TrimSpace(field)
+		if !ok || field == "" || strings.TrimSpace(column) == "" {
+			return nil, fmt.Errorf("invalid mapping %q, expected field=column", pair)
+		}
+		if !isColumn(field) {
+			return nil, fmt.Errorf("unknown field %q in mapping", field)
+		}
+		result[field] = strings.TrimSpace(column)
+	}
+	return result, nil
+}
+
+func isColumn(name string) bool {
+	for _, column := range Columns {
+		if column == name {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeHeader(header string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(header)), " ", "_")
+}
+
+// derivedID gives a row without a source event ID a stable one, so that
+// importing the same sheet twice updates events instead of adding them again
+func derivedID(event common.IngestEvent) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		strings.ToLower(strings.TrimSpace(event.Title)),
+		event.Start.UTC().Format("2006-01-02T15:04"),
+		strings.ToLower(strings.TrimSpace(event.VenueName)),
+	}, "|")))
+	return derivedIDPrefix + hex.EncodeToString(sum[:])[:derivedIDLength]
+}
@@ -0,0 +1,98 @@
+package venuescrapers
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// genreNames maps the genre names of ticketing platforms onto our category set.
+// Genres not listed here are left for the tagger to categorise.
+var genreNames = map[string]string{
+	"music":             "music",
+	"acoustic":          "music",
+	"ambient":           "music",
+	"alternative":       "music",
+	"blues":             "music",
+	"classical":         "music",
+	"country":           "music",
+	"dance":             "music",
+	"drum and bass":     "music",
+	"electronic":        "music",
+	"experimental":      "music",
+	"folk":              "music",
+	"funk":              "music",
+	"garage":            "music",
+	"hardcore":          "music",
+	"hip hop":           "music",
+	"house":             "music",
+	"indie":             "music",
+	"jazz":              "music",
+	"metal":             "music",
+	"pop":               "music",
+	"psychedelic":       "music",
+	"punk":              "music",
+	"r&b":               "music",
+	"reggae":            "music",
+	"rock":              "music",
+	"soul":              "music",
+	"techno":            "music",
+	"world":             "music",
+	"arts":              "culture",
+	"performing arts":   "culture",
+	"performingarts":    "culture",
+	"visual arts":       "culture",
+	"cabaret":           "culture",
+	"comedy":            "culture",
+	"dance performance": "culture",
+	"festival":          "culture",
+	"film":              "culture",
+	"theatre":           "culture",
+	"burlesque":         "sex-positive",
+	"workshop":          "workshop",
+	"class":             "workshop",
+	"talk":              "talk",
+	"spoken word":       "talk",
+	"conference":        "talk",
+}
+
+// genreCategories maps genres onto our categories, in order and without duplicates
+func genreCategories(genres ...string) []string {
+	var result []string
+	for _, genre := range genres {
+		category, ok := genreNames[strings.ToLower(strings.TrimSpace(genre))]
+		if ok && !slices.Contains(result, category) {
+			result = append(result, category)
+		}
+	}
+	return result
+}
+
+// hashtags turns names into hashtags, keeping their order and dropping duplicates
+func hashtags(names []string) []string {
+	var result []string
+	seen := map[string]bool{}
+	for _, name := range names {
+		tag := hashtag(name)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		result = append(result, tag)
+	}
+	return result
+}
+
+// hashtag lowercases s and keeps only letters and digits: "Live Music" -> "#livemusic"
+func hashtag(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	if b.Len() == 0 {
+		return ""
+	}
+	return "#" + b.String()
+}
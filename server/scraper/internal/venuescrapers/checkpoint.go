@@ -0,0 +1,112 @@
+package venuescrapers
+
+import (
+	"common"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// Incremental runs trust the checkpoint and can miss events announced further
+// out than where they stopped, so a full walk is forced at least this often
+const fullRefreshInterval = 7 * 24 * time.Hour
+
+type ScrapeOptions struct {
+	Full bool // ignore the checkpoint and walk every page
+}
+
+// fingerprint hashes the JSON form of v, for spotting changed events and listings
+func fingerprint(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+func listingFingerprint(links []string) string {
+	sorted := append([]string(nil), links...)
+	sort.Strings(sorted)
+	return fingerprint(sorted)
+}
+
+// Incremental reports whether the current run may stop at known events
+func (obj Pipeline) Incremental() bool {
+	return !obj.full && !obj.checkpoint.LastSuccessfulRun.IsZero()
+}
+
+// Unchanged reports whether the last successful run saw sourceEventID with the
+// same fingerprint. It is always false on full runs.
+func (obj Pipeline) Unchanged(sourceEventID string, fingerprint string) bool {
+	if !obj.Incremental() {
+		return false
+	}
+	previous, ok := obj.checkpoint.Seen[sourceEventID]
+	return ok && previous == fingerprint
+}
+
+// Seen records an event that is stored, so the next run can skip it
+func (obj Pipeline) Seen(sourceEventID string, fingerprint string) {
+	if obj.report != nil {
+		obj.report.see(sourceEventID, fingerprint)
+	}
+}
+
+// ListingUnchanged records the fingerprint of the links found on a listing page
+// and reports whether they are the ones the last successful run fully processed
+func (obj Pipeline) ListingUnchanged(links []string) bool {
+	listing := listingFingerprint(links)
+	if obj.report != nil {
+		obj.report.setListing(listing)
+	}
+	if !obj.Incremental() || obj.checkpoint.ListingFingerprint != listing {
+		return false
+	}
+	for _, link := range links {
+		if _, ok := obj.checkpoint.Seen[link]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// StopEarly notes that the scraper ended the run because the rest is already known
+func (obj Pipeline) StopEarly(reason string) {
+	obj.logger.Info().Msgf("Stopping early: %s", reason)
+	if obj.report != nil {
+		obj.report.stopEarly()
+	}
+}
+
+// needsFullRun applies the periodic full refresh on top of the requested options
+func needsFullRun(checkpoint common.SourceCheckpoint, options ScrapeOptions, now time.Time) bool {
+	return options.Full || checkpoint.LastSuccessfulRun.IsZero() || now.Sub(checkpoint.LastFullRun) > fullRefreshInterval
+}
+
+// nextCheckpoint folds what a successful run saw into the previous checkpoint.
+// Full runs replace the seen set, which drops events that left the listing.
+func nextCheckpoint(previous common.SourceCheckpoint, report *ScrapeReport, started time.Time, full bool) common.SourceCheckpoint {
+	report.mu.Lock()
+	defer report.mu.Unlock()
+
+	result := common.SourceCheckpoint{
+		LastSuccessfulRun:  started,
+		LastFullRun:        previous.LastFullRun,
+		Seen:               map[string]string{},
+		ListingFingerprint: report.listing,
+	}
+	if full {
+		result.LastFullRun = started
+	} else {
+		for id, fingerprint := range previous.Seen {
+			result.Seen[id] = fingerprint
+		}
+	}
+	for id, fingerprint := range report.seen {
+		result.Seen[id] = fingerprint
+	}
+	return result
+}
@@ -0,0 +1,217 @@
+package venuescrapers
+
+import (
+	"bufio"
+	"bytes"
+	"common"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/rs/zerolog"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// External scraper protocol
+//
+// The pipeline starts source.Command with the common.Source record as JSON on
+// stdin, checkpoint included, and SCRAPER_INCREMENTAL=true in the environment
+// when the scraper may stop at events the checkpoint already knows.
+//
+// stdout carries one common.IngestEvent per line, the same JSON the ingestion
+// API accepts. Each is run through the same stages as the built-in scrapers,
+// under the source ID as source name.
+//
+// stderr carries ExternalRecord lines: "progress" (pages fetched so far),
+// "error" (an event that could not be scraped), "skip" (a URL deliberately left
+// out) and "stopped" (the rest is already known). Any other line is logged.
+//
+// A non-zero exit status fails the run, keeping the events already processed.
+
+// ExternalRecord is a progress or error report written by an external scraper
+type ExternalRecord struct {
+	Type    string `json:"type"`    // progress, error, skip or stopped
+	Message string `json:"message"` // what happened, or why
+	Subject string `json:"subject"` // URL or source event the record is about
+	Pages   int    `json:"pages"`   // progress: pages fetched since the last progress record
+}
+
+const (
+	// longest stdout or stderr line accepted from an external scraper
+	externalMaxLine = 4 << 20
+	// time a cancelled external scraper gets to exit before its pipes are closed
+	externalWaitDelay = 10 * time.Second
+)
+
+type ExternalScraper struct {
+	source common.Source
+	logger zerolog.Logger
+}
+
+func NewExternalScraper(source common.Source, logger zerolog.Logger) ExternalScraper {
+	return ExternalScraper{
+		source: source,
+		logger: logger,
+	}
+}
+
+// externalLine is one stdout line, numbered for error reports
+type externalLine struct {
+	number int
+	data   []byte
+}
+
+func (obj ExternalScraper) Scrape(ctx context.Context, pipeline Pipeline) error {
+	if len(obj.source.Command) == 0 {
+		return fmt.Errorf("source %s has no command", obj.source.SourceID)
+	}
+	input, err := json.Marshal(obj.source)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, obj.source.Command[0], obj.source.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Env = append(os.Environ(), "SCRAPER_INCREMENTAL="+strconv.FormatBool(pipeline.Incremental()))
+	cmd.WaitDelay = externalWaitDelay
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+
+	obj.logger.Debug().Msgf("Starting %s for source %s", strings.Join(obj.source.Command, " "), obj.source.SourceID)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	var lastError atomic.Value
+	var reporting sync.WaitGroup
+	reporting.Add(1)
+	go func() {
+		defer reporting.Done()
+		obj.readRecords(stderr, pipeline, &lastError)
+	}()
+
+	var eventsReceived, eventsProcessed atomic.Int64
+	lines := make(chan externalLine)
+	var readErr error
+	readDone := make(chan struct{})
+	go func() {
+		defer close(readDone)
+		defer close(lines)
+		scanner := bufio.NewScanner(stdout)
+		scanner.Buffer(make([]byte, 64*1024), externalMaxLine)
+		for number := 1; scanner.Scan(); number++ {
+			if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+				continue
+			}
+			line := externalLine{number: number, data: bytes.Clone(scanner.Bytes())}
+			select {
+			case <-ctx.Done():
+				return
+			case lines <- line:
+			}
+		}
+		readErr = scanner.Err()
+	}()
+
+	err = forEachReceived(ctx, pipeline.workers, lines, func(ctx context.Context, line externalLine) {
+		eventsReceived.Add(1)
+		pipeline.Found(1)
+		var item common.IngestEvent
+		decoder := json.NewDecoder(bytes.NewReader(line.data))
+		decoder.DisallowUnknownFields()
+		err := decoder.Decode(&item)
+		if err == nil {
+			err = item.Check()
+		}
+		if err != nil {
+			obj.logger.Error().Msgf("%s: stdout line %d: %s", obj.source.SourceID, line.number, err.Error())
+			pipeline.Fail(fmt.Sprintf("stdout line %d", line.number), err)
+			return
+		}
+
+		if pipeline.Unchanged(item.SourceEvent, fingerprint(item)) {
+			pipeline.Seen(item.SourceEvent, fingerprint(item))
+			return
+		}
+		_, err = pipeline.Process(ctx, ingestedEvent(item, obj.source))
+		if err != nil {
+			obj.logger.Error().Msg(err.Error())
+		} else {
+			eventsProcessed.Add(1)
+		}
+		if err == nil || errors.Is(err, ErrDuplicateEvent) {
+			pipeline.Seen(item.SourceEvent, fingerprint(item))
+		}
+	})
+	<-readDone
+	// drain whatever is left after a cancellation, so the scraper is not blocked on a full pipe
+	io.Copy(io.Discard, stdout)
+	reporting.Wait()
+	waitErr := cmd.Wait()
+
+	obj.logger.Info().Msgf("Source %s: received %d events, successfully processed %d events", obj.source.SourceID, eventsReceived.Load(), eventsProcessed.Load())
+	if err != nil {
+		return err
+	}
+	if readErr != nil {
+		return fmt.Errorf("reading %s output: %w", obj.source.Command[0], readErr)
+	}
+	if waitErr != nil {
+		if message, ok := lastError.Load().(string); ok {
+			return fmt.Errorf("%s: %w: %s", obj.source.Command[0], waitErr, message)
+		}
+		return fmt.Errorf("%s: %w", obj.source.Command[0], waitErr)
+	}
+	return nil
+}
+
+// readRecords applies the stderr records of an external scraper to the run,
+// remembering the last error message for the exit status
+func (obj ExternalScraper) readRecords(stderr io.Reader, pipeline Pipeline, lastError *atomic.Value) {
+	scanner := bufio.NewScanner(stderr)
+	scanner.Buffer(make([]byte, 64*1024), externalMaxLine)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var record ExternalRecord
+		if !strings.HasPrefix(line, "{") || json.Unmarshal([]byte(line), &record) != nil {
+			obj.logger.Debug().Msgf("%s: %s", obj.source.SourceID, line)
+			continue
+		}
+
+		switch record.Type {
+		case "progress":
+			for i := 0; i < record.Pages; i++ {
+				pipeline.PageFetched()
+			}
+			if record.Message != "" {
+				obj.logger.Info().Msgf("%s: %s", obj.source.SourceID, record.Message)
+			}
+		case "error":
+			obj.logger.Error().Msgf("%s: %s %s", obj.source.SourceID, record.Subject, record.Message)
+			pipeline.Fail(record.Subject, errors.New(record.Message))
+			lastError.Store(record.Message)
+		case "skip":
+			pipeline.Skip(record.Subject, record.Message)
+		case "stopped":
+			pipeline.StopEarly(record.Message)
+		default:
+			obj.logger.Warn().Msgf("%s: unknown record type %q: %s", obj.source.SourceID, record.Type, line)
+		}
+	}
+}
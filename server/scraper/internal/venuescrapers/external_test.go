@@ -0,0 +1,157 @@
+package venuescrapers
+
+import (
+	"common"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/rs/zerolog"
+	"os"
+	"scraper/internal/fetch"
+	"strings"
+	"testing"
+	"time"
+)
+
+// externalHelperEnv makes the test binary act as an external scraper, see TestExternalHelper
+const externalHelperEnv = "EXTERNAL_SCRAPER_HELPER"
+
+// TestExternalHelper is not a test: run by the tests below as the command of an
+// external source, it speaks the protocol as the mode in externalHelperEnv says
+func TestExternalHelper(t *testing.T) {
+	mode := os.Getenv(externalHelperEnv)
+	if mode == "" {
+		return
+	}
+	record := func(record ExternalRecord) {
+		line, _ := json.Marshal(record)
+		fmt.Fprintln(os.Stderr, string(line))
+	}
+	event := func(sourceEvent string, title string) {
+		line, _ := json.Marshal(common.IngestEvent{
+			SourceEvent: sourceEvent,
+			Title:       title,
+			Start:       time.Now().Add(72 * time.Hour).Truncate(time.Hour),
+			VenueName:   "Metro Theatre",
+			Address:     &common.IngestAddress{Line1: "624 George St", Locality: "Sydney", Region: "NSW", PostCode: "2000"},
+			Geo:         &common.IngestGeo{Lat: -33.8757, Lng: 151.2069},
+			URL:         "https://example.com/events/" + sourceEvent,
+		})
+		fmt.Println(string(line))
+	}
+
+	switch mode {
+	case "events":
+		record(ExternalRecord{Type: "progress", Pages: 2})
+		event("e1", "First Show")
+		fmt.Println()
+		fmt.Println(`{"source_event": "e2", "title": "Unknown Field", "start": "2030-01-01T20:00:00Z", "venue": "Metro"}`)
+		fmt.Println(`{"source_event": "e3"}`)
+		event("e4", "Second Show")
+		fmt.Fprintln(os.Stderr, "not a record")
+		record(ExternalRecord{Type: "skip", Subject: "https://example.com/private", Message: "private event"})
+		if os.Getenv("SCRAPER_INCREMENTAL") == "true" {
+			record(ExternalRecord{Type: "stopped", Message: "rest is known"})
+		}
+	case "fail":
+		event("e1", "First Show")
+		record(ExternalRecord{Type: "error", Subject: "https://example.com/broken", Message: "page layout changed"})
+		os.Exit(3)
+	case "long":
+		fmt.Println(`{"title": "` + strings.Repeat("x", externalMaxLine) + `"}`)
+	case "hang":
+		event("e1", "First Show")
+		time.Sleep(time.Minute)
+	}
+	os.Exit(0)
+}
+
+// scrapeExternal runs the test binary in mode as the command of an external source
+func scrapeExternal(t *testing.T, ctx context.Context, mode string, checkpoint common.SourceCheckpoint) (*MemoryStore, *ScrapeReport, error) {
+	t.Setenv(externalHelperEnv, mode)
+	source := common.Source{
+		SourceID:   "helper",
+		SourceType: common.External,
+		Active:     true,
+		Command:    []string{os.Args[0], "-test.run=^TestExternalHelper$"},
+		Checkpoint: checkpoint,
+	}
+	store := NewMemoryStore()
+	pipeline := NewPipeline(store, fetch.NewFetcher(fetch.DefaultConfig(), zerolog.Nop()), zerolog.Nop())
+	report, err := pipeline.Scrape(ctx, source, ScrapeOptions{})
+	return store, report, err
+}
+
+func TestExternalScraper(t *testing.T) {
+	store, report, err := scrapeExternal(t, context.Background(), "events", common.SourceCheckpoint{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var titles []string
+	for _, event := range store.Events() {
+		titles = append(titles, event.Title)
+	}
+	if len(titles) != 2 {
+		t.Errorf("stored %q, want First Show and Second Show", titles)
+	}
+	if report.EventsFound != 4 || report.EventsFailed != 2 {
+		t.Errorf("found %d, failed %d events, want 4 and 2", report.EventsFound, report.EventsFailed)
+	}
+	if report.PagesFetched != 2 {
+		t.Errorf("fetched %d pages, want 2", report.PagesFetched)
+	}
+	if len(report.Skipped) != 1 || report.Skipped[0].URL != "https://example.com/private" {
+		t.Errorf("skipped %v, want https://example.com/private", report.Skipped)
+	}
+	if report.StoppedEarly {
+		t.Error("full run stopped early")
+	}
+	if report.Checkpoint == nil || len(report.Checkpoint.Seen) != 2 {
+		t.Errorf("checkpoint %v, want the two stored events", report.Checkpoint)
+	}
+
+	// with a checkpoint the scraper is told it may stop, and says it did
+	_, report, err = scrapeExternal(t, context.Background(), "events", *report.Checkpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.StoppedEarly {
+		t.Error("incremental run did not stop early")
+	}
+}
+
+func TestExternalScraperExitStatus(t *testing.T) {
+	store, report, err := scrapeExternal(t, context.Background(), "fail", common.SourceCheckpoint{})
+	if err == nil || !strings.Contains(err.Error(), "exit status 3") || !strings.Contains(err.Error(), "page layout changed") {
+		t.Errorf("error %v, want the exit status and the last error record", err)
+	}
+	if len(store.Events()) != 1 {
+		t.Errorf("stored %d events, want the one received before the failure", len(store.Events()))
+	}
+	if report.EventsFailed != 1 || report.Checkpoint != nil {
+		t.Errorf("failed %d events with checkpoint %v, want 1 and none", report.EventsFailed, report.Checkpoint)
+	}
+}
+
+func TestExternalScraperLongLine(t *testing.T) {
+	_, _, err := scrapeExternal(t, context.Background(), "long", common.SourceCheckpoint{})
+	if err == nil || !strings.Contains(err.Error(), "too long") {
+		t.Errorf("error %v, want the line to be too long", err)
+	}
+}
+
+func TestExternalScraperCancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	started := time.Now()
+	store, _, err := scrapeExternal(t, ctx, "hang", common.SourceCheckpoint{})
+	if err == nil {
+		t.Error("cancelled run succeeded")
+	}
+	if elapsed := time.Since(started); elapsed > externalWaitDelay/2 {
+		t.Errorf("cancelled run took %s", elapsed)
+	}
+	if len(store.Events()) != 1 {
+		t.Errorf("stored %d events, want the one received before the cancellation", len(store.Events()))
+	}
+}
@@ -0,0 +1,324 @@
+package venuescrapers
+
+import (
+	"common"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"jaytaylor.com/html2text"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"scraper/internal/fetch"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	humanitixBaseURL    = "https://api.humanitix.com"
+	humanitixEventsPath = "/v1/events"
+	humanitixAPIKeyEnv  = "HUMANITIX_API_KEY"
+
+	humanitixDefaultPageSize = 100
+	humanitixMaxPageSize     = 100
+)
+
+type humanitixEvent struct {
+	ID            string `json:"_id"`
+	Name          string
+	Description   string
+	URL           string
+	StartDate     time.Time
+	EndDate       time.Time
+	Keywords      []string
+	Public        bool
+	Published     bool
+	EventLocation struct {
+		Type      string
+		VenueName string
+		Address   string
+		LatLng    []float64
+	}
+	Dates []struct {
+		ID        string `json:"_id"`
+		StartDate time.Time
+		EndDate   time.Time
+		Disabled  bool
+		Deleted   bool
+	}
+	TicketTypes []struct {
+		Name     string
+		Price    float64
+		Disabled bool
+		Deleted  bool
+	}
+	Pricing struct {
+		MinimumPrice float64
+		MaximumPrice float64
+	}
+	BannerImage struct {
+		URL string `json:"url"`
+	}
+	Classification struct {
+		Category    string
+		Subcategory string
+	}
+}
+
+type humanitixResponse struct {
+	Total    int
+	PageSize int
+	Page     int
+	Events   []humanitixEvent
+}
+
+type HumanitixScraper struct {
+	source  common.Source
+	logger  zerolog.Logger
+	fetcher *fetch.Fetcher
+	baseURL string
+	apiKey  string
+}
+
+func NewHumanitixScraper(source common.Source, logger zerolog.Logger, fetcher *fetch.Fetcher) HumanitixScraper {
+	return HumanitixScraper{
+		source:  source,
+		logger:  logger,
+		fetcher: fetcher,
+		baseURL: humanitixBaseURL,
+		apiKey:  os.Getenv(humanitixAPIKeyEnv),
+	}
+}
+
+// WithBaseURL points the scraper at another API host, e.g. a fixture replay server
+func (obj HumanitixScraper) WithBaseURL(baseURL string) Scraper {
+	obj.baseURL = baseURL
+	return obj
+}
+
+func (obj HumanitixScraper) BaseURL() string {
+	return obj.baseURL
+}
+
+func (obj HumanitixScraper) getPage(ctx context.Context, page int) (humanitixResponse, error) {
+	pageSize := obj.source.Scope.PageSize
+	if pageSize <= 0 {
+		pageSize = humanitixDefaultPageSize
+	}
+	params := url.Values{}
+	params.Set("inFutureOnly", "true")
+	params.Set("page", fmt.Sprint(page))
+	params.Set("pageSize", fmt.Sprint(min(pageSize, humanitixMaxPageSize)))
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, obj.baseURL+humanitixEventsPath+"?"+params.Encode(), nil)
+	if err != nil {
+		return humanitixResponse{}, err
+	}
+	request.Header.Set("x-api-key", obj.apiKey)
+	request.Header.Set("Accept", "application/json")
+
+	response, err := obj.fetcher.Client().Do(request)
+	if err != nil {
+		return humanitixResponse{}, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return humanitixResponse{}, fmt.Errorf("humanitix page %d: %s", page, response.Status)
+	}
+	var result humanitixResponse
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return humanitixResponse{}, fmt.Errorf("humanitix page %d: %w", page, err)
+	}
+	return result, nil
+}
+
+// australianLocality matches the "Newtown NSW 2042" part of a one-line address
+var australianLocality = regexp.MustCompile(`^(.*?)\s+(NSW|VIC|QLD|SA|WA|TAS|NT|ACT)\s+(\d{4})$`)
+
+// parseAustralianAddress splits a one-line address such as
+// "2 Missenden Rd, Camperdown NSW 2050, Australia". Anything it cannot place
+// stays in Line1.
+func parseAustralianAddress(address string) common.Address {
+	var parts []string
+	for _, part := range strings.Split(address, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	var result common.Address
+	if len(parts) > 0 && strings.EqualFold(parts[len(parts)-1], "Australia") {
+		result.Country = "Australia"
+		parts = parts[:len(parts)-1]
+	}
+	if len(parts) > 1 {
+		if match := australianLocality.FindStringSubmatch(parts[len(parts)-1]); match != nil {
+			result.Locality, result.Region, result.PostCode = match[1], match[2], match[3]
+			parts = parts[:len(parts)-1]
+		}
+	}
+	result.Line1 = strings.Join(parts, ", ")
+	return result
+}
+
+func (item humanitixEvent) prices() (float64, float64) {
+	if item.Pricing.MinimumPrice > 0 || item.Pricing.MaximumPrice > 0 {
+		return item.Pricing.MinimumPrice, item.Pricing.MaximumPrice
+	}
+	priceMin, priceMax := -1.0, 0.0
+	for _, ticket := range item.TicketTypes {
+		if ticket.Deleted || ticket.Disabled {
+			continue
+		}
+		if priceMin < 0 || ticket.Price < priceMin {
+			priceMin = ticket.Price
+		}
+		priceMax = max(priceMax, ticket.Price)
+	}
+	return max(priceMin, 0), priceMax
+}
+
+// humanitixTags turns the subcategory and the keywords of item into hashtags,
+// subcategory first
+func humanitixTags(item humanitixEvent) []string {
+	var names []string
+	if item.Classification.Subcategory != "" {
+		names = append(names, item.Classification.Subcategory)
+	}
+	return hashtags(append(names, item.Keywords...))
+}
+
+// convertHumanitixEvent turns an event into one common.Event per upcoming
+// session. An event listing one date keeps the Humanitix event ID as its source
+// event, sessions of one listing several are keyed by event and date ID.
+func convertHumanitixEvent(item humanitixEvent, now time.Time) []common.Event {
+	description, _ := html2text.FromString(item.Description, html2text.Options{TextOnly: true})
+	priceMin, priceMax := item.prices()
+
+	base := common.Event{
+		Source_name: string(common.Humanitix),
+		SourceEvent: item.ID,
+		Title:       item.Name,
+		Description: description,
+		VenueName:   item.EventLocation.VenueName,
+		Address:     parseAustralianAddress(item.EventLocation.Address),
+		URL:         item.URL,
+		TicketURL:   item.URL,
+		PriceMin:    priceMin,
+		PriceMax:    priceMax,
+		Categories:  genreCategories(item.Classification.Category, item.Classification.Subcategory),
+		Tags:        humanitixTags(item),
+		FetchedAt:   now,
+	}
+	if len(item.EventLocation.LatLng) == 2 {
+		base.Geo = common.Geo{Lat: item.EventLocation.LatLng[0], Lng: item.EventLocation.LatLng[1]}
+	}
+	if item.BannerImage.URL != "" {
+		base.Images = []string{item.BannerImage.URL}
+	}
+
+	type session struct {
+		id         string
+		start, end time.Time
+	}
+	var sessions []session
+	for _, date := range item.Dates {
+		if date.Deleted || date.Disabled || date.StartDate.Before(now) {
+			continue
+		}
+		sessions = append(sessions, session{date.ID, date.StartDate, date.EndDate})
+	}
+	if len(item.Dates) == 0 {
+		sessions = append(sessions, session{"", item.StartDate, item.EndDate})
+	}
+
+	var result []common.Event
+	for _, s := range sessions {
+		event := base
+		event.EventID = uuid.NewString()
+		event.Categories = slices.Clone(base.Categories)
+		event.Tags = slices.Clone(base.Tags)
+		event.Images = slices.Clone(base.Images)
+		event.Start = s.start.UTC()
+		if s.end.After(s.start) {
+			event.End = s.end.UTC()
+		}
+		// keyed on every date listed, past ones included, so a session keeps its
+		// key as the ones before it pass
+		if len(item.Dates) > 1 {
+			event.SourceEvent = item.ID + "/" + s.id
+		}
+		result = append(result, event)
+	}
+	return result
+}
+
+func (obj HumanitixScraper) Scrape(ctx context.Context, pipeline Pipeline) error {
+	var eventsFetched = 0
+	var eventsProcessed atomic.Int64
+
+	for page := 1; ; page++ {
+		obj.logger.Debug().Msgf("Getting page %d for source %s", page, obj.source.SourceID)
+		response, err := obj.getPage(ctx, page)
+		if err != nil {
+			obj.logger.Error().Msg(err.Error())
+			return err
+		}
+		eventsFetched += len(response.Events)
+		pipeline.PageFetched()
+
+		var items []humanitixEvent
+		wanted := 0
+		for _, item := range response.Events {
+			// online-only and unpublished events have nowhere to go on the map
+			if !item.Public || !item.Published || item.EventLocation.Type == "online" {
+				continue
+			}
+			wanted++
+			if pipeline.Unchanged(item.ID, fingerprint(item)) {
+				pipeline.Seen(item.ID, fingerprint(item))
+				continue
+			}
+			items = append(items, item)
+		}
+		pipeline.Found(wanted)
+
+		now := time.Now()
+		err = forEach(ctx, pipeline.workers, items, func(ctx context.Context, item humanitixEvent) {
+			failed := false
+			for _, event := range convertHumanitixEvent(item, now) {
+				_, err := pipeline.Process(ctx, event)
+				if err != nil && !errors.Is(err, ErrDuplicateEvent) {
+					failed = true
+				}
+				if err != nil {
+					obj.logger.Error().Msg(err.Error())
+				} else {
+					eventsProcessed.Add(1)
+				}
+			}
+			if !failed {
+				pipeline.Seen(item.ID, fingerprint(item))
+			}
+		})
+		if err != nil {
+			return err
+		}
+
+		if wanted > 0 && len(items) == 0 {
+			pipeline.StopEarly(fmt.Sprintf("page %d only has known, unchanged events", page))
+			break
+		}
+		if len(response.Events) == 0 || page*response.PageSize >= response.Total {
+			break
+		}
+	}
+
+	obj.logger.Info().Msgf("Source %s: fetched %d events, successfully processed %d events", obj.source.SourceID, eventsFetched, eventsProcessed.Load())
+	return nil
+}
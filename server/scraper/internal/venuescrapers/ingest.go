@@ -0,0 +1,45 @@
+package venuescrapers
+
+import (
+	"common"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"sync/atomic"
+)
+
+// ingestedEvent converts an event pushed for source, by an external scraper or the ingestion API
+func ingestedEvent(item common.IngestEvent, source common.Source) common.Event {
+	event := item.Event(EventSourceName(source))
+	event.EventID = uuid.NewString()
+	return event
+}
+
+// Ingest runs events pushed for source through the pipeline, as one run of the
+// source. Pushed events are always processed, there is no checkpoint to stop at.
+func (obj Pipeline) Ingest(ctx context.Context, source common.Source, items []common.IngestEvent) *ScrapeReport {
+	report := NewScrapeReport(source)
+	report.Full = true
+	run := obj
+	run.report = report
+
+	var eventsProcessed atomic.Int64
+	run.Found(len(items))
+	err := forEach(ctx, run.workers, items, func(ctx context.Context, item common.IngestEvent) {
+		if err := item.Check(); err != nil {
+			run.Fail(fmt.Sprintf("%s %q", source.SourceID, item.SourceEvent), err)
+			return
+		}
+		_, err := run.Process(ctx, ingestedEvent(item, source))
+		if err == nil {
+			eventsProcessed.Add(1)
+		} else if !errors.Is(err, ErrDuplicateEvent) {
+			obj.logger.Error().Msg(err.Error())
+		}
+	})
+	report.finish(err)
+
+	obj.logger.Info().Msgf("Source %s: received %d events, successfully processed %d events", source.SourceID, len(items), eventsProcessed.Load())
+	return report
+}
@@ -0,0 +1,136 @@
+package venuescrapers
+
+import (
+	"common"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"scraper/internal/dates"
+	"time"
+)
+
+// eventPageScraper returns the events of an event page, one per session. A page
+// some of whose sessions could not be read gives the others and an error.
+type eventPageScraper func(ctx context.Context, url string) ([]common.Event, error)
+
+// SessionSources are the sources whose events are sessions of a venue page
+var SessionSources = []common.SourceType{common.FactoryTheatre, common.MetroTheatre, common.OurSecretSpot}
+
+// sessionKeyLayout is the local start of a session in its source event ID
+const sessionKeyLayout = "2006-01-02T15:04"
+
+var sessionLocation, _ = time.LoadLocation(dates.DefaultLocation)
+
+// SessionKey is the source event ID of the session of the page at url starting
+// at start. Every session is keyed this way, however many the page lists, so a
+// session keeps its key as others are added or pass.
+func SessionKey(url string, start time.Time) string {
+	return url + "#" + start.In(sessionLocation).Format(sessionKeyLayout)
+}
+
+// parseSessions reads every li.session-date of an event page, in page order.
+// Sessions that do not parse are left out and reported in the error.
+func parseSessions(doc *goquery.Document, parser dates.Parser, url string) ([]dates.Result, error) {
+	var sessions []dates.Result
+	var errs []error
+	doc.Find("li.session-date").Each(func(i int, s *goquery.Selection) {
+		when, err := parser.Parse(s.Text(), time.Now())
+		if err != nil {
+			errs = append(errs, fmt.Errorf("session %d of event at %s: %w", i+1, url, err))
+			return
+		}
+		sessions = append(sessions, when)
+	})
+	if len(sessions) == 0 && len(errs) == 0 {
+		return nil, errors.New("no date found for event at " + url)
+	}
+	return sessions, errors.Join(errs...)
+}
+
+// sessionEvents makes one event per session out of event, which holds what the
+// sessions share and the page URL as its source event
+func sessionEvents(event common.Event, sessions []dates.Result) []common.Event {
+	var events []common.Event
+	keys := map[string]bool{}
+	for _, session := range sessions {
+		key := SessionKey(event.SourceEvent, session.Start)
+		if keys[key] {
+			continue
+		}
+		keys[key] = true
+
+		result := event
+		result.EventID = uuid.NewString()
+		result.SourceEvent = key
+		result.Start = session.Start.UTC()
+		result.End = session.End.UTC() // zero unless the session date gives an end time
+		events = append(events, result)
+	}
+	return events
+}
+
+// processLinks scrapes every event page, using the pipeline's worker pool, and
+// hands the sessions found to the pipeline, which dedupes them one by one.
+// Event pages carry no fingerprint, so on incremental runs a listing whose links
+// were all seen before is not walked at all, nor is a page seen before.
+func processLinks(ctx context.Context, pipeline Pipeline, links []string, logger zerolog.Logger, scrapeEvent eventPageScraper) error {
+	pipeline.Found(len(links))
+	if pipeline.ListingUnchanged(links) {
+		pipeline.StopEarly("listing unchanged since last run")
+		return nil
+	}
+
+	return forEach(ctx, pipeline.workers, links, func(ctx context.Context, link string) {
+		if pipeline.Unchanged(link, "") {
+			pipeline.Seen(link, "")
+			return
+		}
+
+		events, err := scrapeEvent(ctx, link)
+		if pipeline.skipIfDisallowed(link, err) {
+			return
+		}
+		// a page with sessions left goes on, unseen so the next run reads it again
+		failures := unjoin(err)
+		for _, failure := range failures {
+			logger.Error().Msgf("Error scraping event at %s: %s\n", link, failure.Error())
+			pipeline.Fail(link, failure)
+		}
+		if len(events) == 0 {
+			return
+		}
+		pipeline.PageFetched()
+		if extra := len(events) + len(failures) - 1; extra > 0 {
+			pipeline.Found(extra)
+		}
+		saved := err == nil
+		for _, event := range events {
+			_, err = pipeline.Process(ctx, event)
+			if err != nil {
+				logger.Error().Msgf("Error saving event %s - %s: %s\n", event.Source_name, event.SourceEvent, err.Error())
+			} else {
+				logger.Debug().Msgf("Saved event %s - %s\n", event.Source_name, event.SourceEvent)
+			}
+			if err != nil && !errors.Is(err, ErrDuplicateEvent) {
+				saved = false
+			}
+		}
+		if saved {
+			pipeline.Seen(link, "")
+		}
+	})
+}
+
+// unjoin splits an error made by errors.Join into the errors joined
+func unjoin(err error) []error {
+	if err == nil {
+		return nil
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return joined.Unwrap()
+	}
+	return []error{err}
+}
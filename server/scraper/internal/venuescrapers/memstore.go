@@ -0,0 +1,66 @@
+package venuescrapers
+
+import (
+	"common"
+	"sync"
+	"time"
+)
+
+// MemoryStore is an in-process EventStore, used when scraping without touching DynamoDB
+type MemoryStore struct {
+	mu     sync.Mutex
+	events []common.Event
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+func (obj *MemoryStore) QueryEventsBySourceAndSourceEventID(source, sourceEventID string) ([]common.Event, error) {
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+
+	var result []common.Event
+	for _, event := range obj.events {
+		if event.Source_name == source && event.SourceEvent == sourceEventID {
+			result = append(result, event)
+		}
+	}
+	return result, nil
+}
+
+func (obj *MemoryStore) QueryEventsByTicketRef(ref string, from time.Time, to time.Time) ([]common.Event, error) {
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+
+	var result []common.Event
+	for _, event := range obj.events {
+		if event.TicketRef == ref && !event.Start.Before(from) && !event.Start.After(to) {
+			result = append(result, event)
+		}
+	}
+	return result, nil
+}
+
+func (obj *MemoryStore) WriteEvent(event common.Event) error {
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+
+	// same semantics as a DynamoDB put keyed on event_id
+	for i, stored := range obj.events {
+		if stored.EventID == event.EventID {
+			obj.events[i] = event
+			return nil
+		}
+	}
+	obj.events = append(obj.events, event)
+	return nil
+}
+
+// Events returns a copy of everything written so far
+func (obj *MemoryStore) Events() []common.Event {
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+
+	return append([]common.Event(nil), obj.events...)
+}
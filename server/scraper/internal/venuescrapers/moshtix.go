@@ -3,11 +3,16 @@ package venuescrapers
 import (
 	"common"
 	"context"
+	"errors"
+	"fmt"
 	"github.com/google/uuid"
 	"github.com/hasura/go-graphql-client"
 	"github.com/rs/zerolog"
 	"jaytaylor.com/html2text"
+	"scraper/internal/fetch"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -91,13 +96,101 @@ type moshtixResponse struct {
 }
 
 type MoshtixScraper struct {
-	logger zerolog.Logger
+	source  common.Source
+	logger  zerolog.Logger
+	fetcher *fetch.Fetcher
+	baseURL string
 }
 
-func NewMoshtixScraper(logger zerolog.Logger) MoshtixScraper {
+const (
+	moshtixBaseURL     = "https://api.moshtix.com"
+	moshtixGraphQLPath = "/v1/graphql"
+)
+
+// Scope used for whatever the source record leaves empty: all of NSW around the Sydney CBD
+const (
+	moshtixDefaultRadiusKm = 10000
+	moshtixDefaultPageSize = 100
+	moshtixMaxPageSize     = 200
+)
+
+var (
+	moshtixDefaultRegions = []string{"NSW"}
+	moshtixDefaultCenter  = common.Geo{Lat: -33.8727, Lng: 151.2057}
+)
+
+func NewMoshtixScraper(source common.Source, logger zerolog.Logger, fetcher *fetch.Fetcher) MoshtixScraper {
 	return MoshtixScraper{
-		logger: logger,
+		source:  source,
+		logger:  logger,
+		fetcher: fetcher,
+		baseURL: moshtixBaseURL,
+	}
+}
+
+// moshtixQuery is a source scope resolved against the defaults
+type moshtixQuery struct {
+	regions  []RegionInput
+	location EventLocationInput
+	pageSize int
+	genres   map[string]bool // empty keeps every genre
+	horizon  time.Time       // zero means no limit
+}
+
+func newMoshtixQuery(scope common.SourceScope, now time.Time) moshtixQuery {
+	regions := scope.Regions
+	if len(regions) == 0 {
+		regions = moshtixDefaultRegions
+	}
+	center := scope.Center
+	if center.Lat == 0 && center.Lng == 0 {
+		center = moshtixDefaultCenter
+	}
+	radius := scope.RadiusKm
+	if radius <= 0 {
+		radius = moshtixDefaultRadiusKm
+	}
+	pageSize := scope.PageSize
+	if pageSize <= 0 {
+		pageSize = moshtixDefaultPageSize
+	}
+
+	query := moshtixQuery{
+		location: EventLocationInput{Latitude: center.Lat, Longitude: center.Lng, WithinRadius: radius},
+		pageSize: min(pageSize, moshtixMaxPageSize),
+		genres:   map[string]bool{},
+	}
+	for _, region := range regions {
+		query.regions = append(query.regions, RegionInput(strings.ToUpper(region)))
+	}
+	for _, genre := range scope.Genres {
+		query.genres[strings.ToLower(strings.TrimSpace(genre))] = true
 	}
+	if scope.HorizonDays > 0 {
+		query.horizon = now.AddDate(0, 0, scope.HorizonDays)
+	}
+	return query
+}
+
+func (q moshtixQuery) wantsGenre(item moshtixItem) bool {
+	if len(q.genres) == 0 {
+		return true
+	}
+	return item.Genre != nil && q.genres[strings.ToLower(strings.TrimSpace(item.Genre.Name))]
+}
+
+func (q moshtixQuery) beyondHorizon(item moshtixItem) bool {
+	return !q.horizon.IsZero() && item.StartDate.After(q.horizon)
+}
+
+// WithBaseURL points the scraper at another GraphQL host, e.g. a fixture replay server
+func (d MoshtixScraper) WithBaseURL(baseURL string) Scraper {
+	d.baseURL = baseURL
+	return d
+}
+
+func (d MoshtixScraper) BaseURL() string {
+	return d.baseURL
 }
 
 func convertToDbEvent(item moshtixItem) common.Event {
@@ -125,7 +218,9 @@ func convertToDbEvent(item moshtixItem) common.Event {
 		result.Images = append(result.Images, imageUrl.Url)
 	}
 
-	result.ContentFlags.EighteenPlus = (item.AgeRestriction == "OVER18")
+	result.ContentFlags.EighteenPlus, result.Attributes.AllAges = moshtixAges(item.AgeRestriction)
+	result.Categories = moshtixCategories(item)
+	result.Tags = moshtixTags(item)
 
 	if item.Venue.Address != nil {
 		result.Address = common.Address{
@@ -148,59 +243,89 @@ func convertToDbEvent(item moshtixItem) common.Event {
 	return result
 }
 
-func (d MoshtixScraper) Scrape(pipeline Pipeline) error {
+func (d MoshtixScraper) Scrape(ctx context.Context, pipeline Pipeline) error {
 
 	var pageIndex = 0
-	var pageSize = 100
-	var startFrom = time.Now().Format(time.RFC3339)
+	var now = time.Now()
+	var startFrom = now.Format(time.RFC3339)
+	var query = newMoshtixQuery(d.source.Scope, now)
 	var eventsFetched = 0
-	var eventsProcessed = 0
+	var eventsProcessed atomic.Int64
+	client := graphql.NewClient(d.baseURL+moshtixGraphQLPath, d.fetcher.Client()).WithDebug(d.source.Debug)
 
 	for {
 		var moshtixResponse = moshtixResponse{}
-		d.logger.Debug().Msgf("Getting page %d", pageIndex)
+		d.logger.Debug().Msgf("Getting page %d for source %s", pageIndex, d.source.SourceID)
 
 		// Variables
 		vars := map[string]any{
 			"pageIndex":          graphql.Int(pageIndex),
-			"pageSize":           IntBetween1and200(pageSize),
+			"pageSize":           IntBetween1and200(query.pageSize),
 			"sortBy":             EventSortOptionsInput("STARTDATE"),
 			"sortByDirection":    SortByDirectionInput("ASC"),
 			"eventStartDateFrom": Date(startFrom),
-			"location":           EventLocationInput{Latitude: -33.8727, Longitude: 151.2057, WithinRadius: 10000},
-			"region":             []RegionInput{RegionInput("NSW")},
+			"location":           query.location,
+			"region":             query.regions,
 		}
 
-		client := graphql.NewClient("https://api.moshtix.com/v1/graphql", nil).WithDebug(true)
-		err := client.Query(context.Background(), &moshtixResponse, vars)
+		err := client.Query(ctx, &moshtixResponse, vars)
 		if err != nil {
 			d.logger.Error().Msg(err.Error())
 			return err
 		}
 
 		eventsFetched += len(moshtixResponse.Viewer.GetEvents.Items)
+		pipeline.PageFetched()
 
-		for _, element := range moshtixResponse.Viewer.GetEvents.Items {
-			// element is the element from someSlice for where we are
+		// results come sorted by start date, so the first event past the horizon ends the walk
+		var items []moshtixItem
+		pastHorizon := false
+		wanted := 0
+		for _, item := range moshtixResponse.Viewer.GetEvents.Items {
+			if query.beyondHorizon(item) {
+				pastHorizon = true
+				break
+			}
+			if !query.wantsGenre(item) {
+				continue
+			}
+			wanted++
+			if pipeline.Unchanged(strconv.Itoa(item.Id), fingerprint(item)) {
+				pipeline.Seen(strconv.Itoa(item.Id), fingerprint(item))
+				continue
+			}
+			items = append(items, item)
+		}
+
+		pipeline.Found(wanted)
 
+		err = forEach(ctx, pipeline.workers, items, func(ctx context.Context, element moshtixItem) {
 			dbEvent := convertToDbEvent(element)
-			_, err := pipeline.Process(dbEvent)
+			_, err := pipeline.Process(ctx, dbEvent)
 			if err != nil {
 				d.logger.Error().Msg(err.Error())
 			} else {
-				eventsProcessed++
+				eventsProcessed.Add(1)
 			}
+			if err == nil || errors.Is(err, ErrDuplicateEvent) {
+				pipeline.Seen(dbEvent.SourceEvent, fingerprint(element))
+			}
+		})
+		if err != nil {
+			return err
 		}
 
-		if moshtixResponse.Viewer.GetEvents.PageInfo.HasNextPage {
+		if wanted > 0 && len(items) == 0 {
+			pipeline.StopEarly(fmt.Sprintf("page %d only has known, unchanged events", pageIndex))
+			break
+		}
+		if moshtixResponse.Viewer.GetEvents.PageInfo.HasNextPage && !pastHorizon {
 			pageIndex++
 		} else {
 			break
 		}
-
-		//	time.Sleep(3 * time.Second)
 	}
 
-	d.logger.Info().Msgf("Fetched %d events, successfully processed %d events", eventsFetched, eventsProcessed)
+	d.logger.Info().Msgf("Source %s: fetched %d events, successfully processed %d events", d.source.SourceID, eventsFetched, eventsProcessed.Load())
 	return nil
 }
@@ -0,0 +1,40 @@
+package venuescrapers
+
+import (
+	"strings"
+)
+
+func moshtixCategories(item moshtixItem) []string {
+	if item.Genre == nil {
+		return nil
+	}
+	return genreCategories(item.Genre.Name)
+}
+
+// moshtixTags turns the genre and the Moshtix tags into hashtags, genre first,
+// keeping Moshtix order and dropping duplicates
+func moshtixTags(item moshtixItem) []string {
+	var names []string
+	if item.Genre != nil {
+		names = append(names, item.Genre.Name)
+	}
+	if item.Tags != nil {
+		for _, tag := range item.Tags.Items {
+			names = append(names, tag.Name)
+		}
+	}
+	return hashtags(names)
+}
+
+// moshtixAges reads an age restriction as whether the event is 18+ and whether
+// it is all ages. Under-18 events shut adults out, so are not all ages; neither
+// are other minimum ages (e.g. OVER15).
+func moshtixAges(restriction AgeRestriction) (bool, bool) {
+	switch strings.ToUpper(strings.ReplaceAll(string(restriction), "_", "")) {
+	case "OVER18", "R18":
+		return true, false
+	case "ALLAGES":
+		return false, true
+	}
+	return false, false
+}
@@ -0,0 +1,51 @@
+package venuescrapers
+
+import (
+	"bytes"
+	"common"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// WebhookNotifier posts new and updated events as JSON to a URL
+type WebhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhookNotifier(url string, client *http.Client) WebhookNotifier {
+	return WebhookNotifier{url: url, client: client}
+}
+
+type webhookPayload struct {
+	Type  string       `json:"type"` // event.created or event.updated
+	Event common.Event `json:"event"`
+}
+
+func (obj WebhookNotifier) Notify(ctx context.Context, event common.Event, updated bool) error {
+	payload := webhookPayload{Type: "event.created", Event: event}
+	if updated {
+		payload.Type = "event.updated"
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, obj.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, err := obj.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook answered %s", response.Status)
+	}
+	return nil
+}
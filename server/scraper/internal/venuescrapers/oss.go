@@ -2,40 +2,49 @@ package venuescrapers
 
 import (
 	"common"
-	"crypto/tls"
+	"context"
 	"errors"
 	"github.com/PuerkitoBio/goquery"
-	"github.com/google/uuid"
 	"github.com/rs/zerolog"
-	"net/http"
+	"scraper/internal/dates"
+	"scraper/internal/fetch"
 	"time"
 )
 
+const (
+	ourSecretSpotBaseURL     = "https://oursecretspot.com.au"
+	ourSecretSpotListingPath = "/events-annandale/"
+)
+
 type OurSecretSpotScraper struct {
-	logger zerolog.Logger
+	logger     zerolog.Logger
+	fetcher    *fetch.Fetcher
+	baseURL    string
+	dateParser dates.Parser
 }
 
-func NewOurSecretSpotScraper(logger zerolog.Logger) OurSecretSpotScraper {
+func NewOurSecretSpotScraper(logger zerolog.Logger, fetcher *fetch.Fetcher) OurSecretSpotScraper {
 	return OurSecretSpotScraper{
-		logger: logger,
+		logger:     logger,
+		fetcher:    fetcher,
+		baseURL:    ourSecretSpotBaseURL,
+		dateParser: dates.NewSydneyParser(),
 	}
 }
 
-func (obj OurSecretSpotScraper) scrapeEvent(url string) (*common.Event, error) {
-	obj.logger.Debug().Msgf("Scraping event at %s", url)
-	client := http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{},
-		},
-	}
+// WithBaseURL points the scraper at another host, e.g. a fixture replay server
+func (obj OurSecretSpotScraper) WithBaseURL(baseURL string) Scraper {
+	obj.baseURL = baseURL
+	return obj
+}
 
-	resp, err := client.Get(url)
-	if err != nil {
-		return nil, err
-	}
+func (obj OurSecretSpotScraper) BaseURL() string {
+	return obj.baseURL
+}
 
-	defer resp.Body.Close()
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
+func (obj OurSecretSpotScraper) scrapeEvent(ctx context.Context, url string) ([]common.Event, error) {
+	obj.logger.Debug().Msgf("Scraping event at %s", url)
+	doc, err := obj.fetcher.GetDocument(ctx, url)
 	if err != nil {
 		return nil, err
 	}
@@ -54,30 +63,19 @@ func (obj OurSecretSpotScraper) scrapeEvent(url string) (*common.Event, error) {
 	descriptionTextLines := descriptionPanel.Find("p")
 	description := descriptionTextLines.Text()
 
-	sel = doc.Find("li.session-date").First()
-	if sel.Length() == 0 {
-		return nil, errors.New("no date found for event at " + url)
-	}
-	temp := sel.Text()
-	startDate, err := time.Parse("Monday, 2 January 2006 03:04 PM", temp)
-	if err != nil {
-		startDate = time.Time{}
+	sessions, sessionErr := parseSessions(doc, obj.dateParser, url)
+	if len(sessions) == 0 {
+		return nil, sessionErr
 	}
 
-	var result = common.Event{EventID: uuid.NewString(),
+	var result = common.Event{
 		Source_name: string(common.OurSecretSpot),
 		SourceEvent: url,
 		Title:       name,              //h1 title
 		Description: description,       //<div class='post-content'>
-		Start:       startDate.UTC(),   //<li class='session-date'>Friday, 31 October 2025 08:00 PM
-		End:         startDate.UTC(),   // <li class='session-date'>Friday, 31 October 2025 08:00 PM
 		VenueName:   "Our Secret Spot", // item.Venue.Name,
 		URL:         url,               // event URL
 		FetchedAt:   time.Now(),
-		Geo: common.Geo{
-			Lat: -33.87557496143779,
-			Lng: 151.206671962522,
-		},
 		Address: common.Address{
 			Line1:    "624 George St",
 			PostCode: "2000",
@@ -89,28 +87,21 @@ func (obj OurSecretSpotScraper) scrapeEvent(url string) (*common.Event, error) {
 		ContentFlags: common.ContentFlags{EighteenPlus: true, SexPositive: true},
 	}
 
-	return &result, nil
+	setTickets(&result, doc, descriptionPanel)
+
+	return sessionEvents(result, sessions), sessionErr
 }
 
 // Scrape fetches the OurSecretSpot Theatre upcoming events page and extracts event links
-func (obj OurSecretSpotScraper) Scrape(pipeline Pipeline) error {
+func (obj OurSecretSpotScraper) Scrape(ctx context.Context, pipeline Pipeline) error {
 	obj.logger.Debug().Msg("Starting OurSecretSpot Theatre scrape")
-	client := http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{},
-		},
-	}
-
-	resp, err := client.Get("https://oursecretspot.com.au/events-annandale/")
-	if err != nil {
-		return err
-	}
-
-	defer resp.Body.Close()
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	listingURL := obj.baseURL + ourSecretSpotListingPath
+	doc, err := obj.fetcher.GetDocument(ctx, listingURL)
 	if err != nil {
+		pipeline.skipIfDisallowed(listingURL, err)
 		return err
 	}
+	pipeline.PageFetched()
 
 	var links []string
 	doc.Find(".bb-link").Each(func(i int, s *goquery.Selection) {
@@ -130,29 +121,5 @@ func (obj OurSecretSpotScraper) Scrape(pipeline Pipeline) error {
 
 	obj.logger.Debug().Msgf("Found %obj event links\n", len(links))
 
-	for _, link := range links {
-		eventExists, err := pipeline.EventExists(string(common.OurSecretSpot), link)
-		if err != nil {
-			obj.logger.Error().Msgf("Error checking if event exists %s: %s\n", link, err.Error())
-			continue
-		}
-		if eventExists {
-			obj.logger.Debug().Msgf("Event already exists, skipping: %s\n", link)
-			continue
-		}
-
-		time.Sleep(1 * time.Second) // Be polite and avoid overwhelming the server
-		event, err := obj.scrapeEvent(link)
-		if err != nil {
-			obj.logger.Error().Msgf("Error scraping event at %s: %s\n", link, err.Error())
-			continue
-		}
-		_, err = pipeline.Process(*event)
-		if err != nil {
-			obj.logger.Error().Msgf("Error saving event %s - %s: %s\n", event.Source_name, event.SourceEvent, err.Error())
-		} else {
-			obj.logger.Debug().Msgf("Saved event %s - %s\n", event.Source_name, event.SourceEvent)
-		}
-	}
-	return nil
+	return processLinks(ctx, pipeline, links, obj.logger, obj.scrapeEvent)
 }
@@ -0,0 +1,260 @@
+package venuescrapers
+
+import (
+	"common"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"jaytaylor.com/html2text"
+	"net/http"
+	"net/url"
+	"os"
+	"scraper/internal/fetch"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// The Oztix event guide is an Algolia index. Its application ID and search-only
+// key are the ones the oztix.com.au pages use, read from the environment.
+const (
+	oztixAppIDEnv     = "OZTIX_ALGOLIA_APP_ID"
+	oztixAPIKeyEnv    = "OZTIX_ALGOLIA_API_KEY"
+	oztixIndexEnv     = "OZTIX_ALGOLIA_INDEX"
+	oztixDefaultIndex = "prod_oztix_eventguide"
+
+	oztixDefaultPageSize = 100
+	oztixMaxPageSize     = 1000
+)
+
+var oztixDefaultRegions = []string{"NSW"}
+
+type oztixHit struct {
+	ObjectID         string `json:"objectID"`
+	EventGuid        string
+	EventName        string
+	EventDescription string
+	EventUrl         string
+	EventImage1      string
+	DateStart        time.Time
+	DateEnd          time.Time
+	Categories       []string
+	Bands            []string
+	IsCancelled      bool
+	TicketPriceMin   float64
+	TicketPriceMax   float64
+	Venue            struct {
+		Name      string
+		Address   string
+		Locality  string
+		State     string
+		Postcode  string
+		Latitude  float64
+		Longitude float64
+	}
+}
+
+type oztixResponse struct {
+	Hits        []oztixHit `json:"hits"`
+	Page        int        `json:"page"`
+	NbPages     int        `json:"nbPages"`
+	HitsPerPage int        `json:"hitsPerPage"`
+}
+
+type OztixScraper struct {
+	source  common.Source
+	logger  zerolog.Logger
+	fetcher *fetch.Fetcher
+	baseURL string
+	index   string
+	appID   string
+	apiKey  string
+}
+
+func NewOztixScraper(source common.Source, logger zerolog.Logger, fetcher *fetch.Fetcher) OztixScraper {
+	result := OztixScraper{
+		source:  source,
+		logger:  logger,
+		fetcher: fetcher,
+		index:   os.Getenv(oztixIndexEnv),
+		appID:   os.Getenv(oztixAppIDEnv),
+		apiKey:  os.Getenv(oztixAPIKeyEnv),
+	}
+	if result.index == "" {
+		result.index = oztixDefaultIndex
+	}
+	if result.appID != "" {
+		result.baseURL = "https://" + strings.ToLower(result.appID) + "-dsn.algolia.net"
+	}
+	return result
+}
+
+// WithBaseURL points the scraper at another Algolia host, e.g. a fixture replay server
+func (obj OztixScraper) WithBaseURL(baseURL string) Scraper {
+	obj.baseURL = baseURL
+	return obj
+}
+
+func (obj OztixScraper) BaseURL() string {
+	return obj.baseURL
+}
+
+// pageURL asks for one page of the source's regions. It leaves the date out,
+// the index only holds upcoming events.
+func (obj OztixScraper) pageURL(page int) string {
+	regions := obj.source.Scope.Regions
+	if len(regions) == 0 {
+		regions = oztixDefaultRegions
+	}
+	filters := make([]string, len(regions))
+	for i, region := range regions {
+		filters[i] = fmt.Sprintf("Venue.State:%q", strings.ToUpper(region))
+	}
+	pageSize := obj.source.Scope.PageSize
+	if pageSize <= 0 {
+		pageSize = oztixDefaultPageSize
+	}
+
+	params := url.Values{}
+	params.Set("query", "")
+	params.Set("filters", strings.Join(filters, " OR "))
+	params.Set("hitsPerPage", fmt.Sprint(min(pageSize, oztixMaxPageSize)))
+	params.Set("page", fmt.Sprint(page))
+	return obj.baseURL + "/1/indexes/" + url.PathEscape(obj.index) + "?" + params.Encode()
+}
+
+func (obj OztixScraper) getPage(ctx context.Context, page int) (oztixResponse, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, obj.pageURL(page), nil)
+	if err != nil {
+		return oztixResponse{}, err
+	}
+	// credentials go in headers, so they stay out of recorded fixtures
+	request.Header.Set("X-Algolia-Application-Id", obj.appID)
+	request.Header.Set("X-Algolia-API-Key", obj.apiKey)
+
+	response, err := obj.fetcher.Client().Do(request)
+	if err != nil {
+		return oztixResponse{}, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return oztixResponse{}, fmt.Errorf("oztix page %d: %s", page, response.Status)
+	}
+	var result oztixResponse
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return oztixResponse{}, fmt.Errorf("oztix page %d: %w", page, err)
+	}
+	return result, nil
+}
+
+func (hit oztixHit) id() string {
+	if hit.EventGuid != "" {
+		return hit.EventGuid
+	}
+	return hit.ObjectID
+}
+
+// oztixTags turns the genres and the bands of hit into hashtags, genres first
+func oztixTags(hit oztixHit) []string {
+	var names []string
+	names = append(names, hit.Categories...)
+	return hashtags(append(names, hit.Bands...))
+}
+
+func convertOztixHit(hit oztixHit) common.Event {
+	description, _ := html2text.FromString(hit.EventDescription, html2text.Options{TextOnly: true})
+
+	result := common.Event{EventID: uuid.NewString(),
+		Source_name: string(common.Oztix),
+		SourceEvent: hit.id(),
+		Title:       hit.EventName,
+		Description: description,
+		Start:       hit.DateStart.UTC(),
+		VenueName:   hit.Venue.Name,
+		URL:         hit.EventUrl,
+		TicketURL:   hit.EventUrl,
+		PriceMin:    hit.TicketPriceMin,
+		PriceMax:    hit.TicketPriceMax,
+		Categories:  genreCategories(hit.Categories...),
+		Tags:        oztixTags(hit),
+		FetchedAt:   time.Now(),
+		Address: common.Address{
+			Line1:    hit.Venue.Address,
+			Locality: hit.Venue.Locality,
+			Region:   hit.Venue.State,
+			PostCode: hit.Venue.Postcode,
+			Country:  "Australia",
+		},
+		Geo: common.Geo{Lat: hit.Venue.Latitude, Lng: hit.Venue.Longitude},
+	}
+	if hit.DateEnd.After(hit.DateStart) {
+		result.End = hit.DateEnd.UTC()
+	}
+	if hit.EventImage1 != "" {
+		result.Images = []string{hit.EventImage1}
+	}
+	return result
+}
+
+func (obj OztixScraper) Scrape(ctx context.Context, pipeline Pipeline) error {
+	if obj.baseURL == "" {
+		return errors.New(oztixAppIDEnv + " is not set")
+	}
+
+	var eventsFetched = 0
+	var eventsProcessed atomic.Int64
+	for page := 0; ; page++ {
+		obj.logger.Debug().Msgf("Getting page %d for source %s", page, obj.source.SourceID)
+		response, err := obj.getPage(ctx, page)
+		if err != nil {
+			obj.logger.Error().Msg(err.Error())
+			return err
+		}
+		eventsFetched += len(response.Hits)
+		pipeline.PageFetched()
+
+		var hits []oztixHit
+		wanted := 0
+		for _, hit := range response.Hits {
+			if hit.IsCancelled {
+				continue
+			}
+			wanted++
+			if pipeline.Unchanged(hit.id(), fingerprint(hit)) {
+				pipeline.Seen(hit.id(), fingerprint(hit))
+				continue
+			}
+			hits = append(hits, hit)
+		}
+		pipeline.Found(wanted)
+
+		err = forEach(ctx, pipeline.workers, hits, func(ctx context.Context, hit oztixHit) {
+			_, err := pipeline.Process(ctx, convertOztixHit(hit))
+			if err != nil {
+				obj.logger.Error().Msg(err.Error())
+			} else {
+				eventsProcessed.Add(1)
+			}
+			if err == nil || errors.Is(err, ErrDuplicateEvent) {
+				pipeline.Seen(hit.id(), fingerprint(hit))
+			}
+		})
+		if err != nil {
+			return err
+		}
+
+		if wanted > 0 && len(hits) == 0 {
+			pipeline.StopEarly(fmt.Sprintf("page %d only has known, unchanged events", page))
+			break
+		}
+		if page+1 >= response.NbPages {
+			break
+		}
+	}
+
+	obj.logger.Info().Msgf("Source %s: fetched %d events, successfully processed %d events", obj.source.SourceID, eventsFetched, eventsProcessed.Load())
+	return nil
+}
@@ -4,34 +4,140 @@ import (
 	"common"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/openai/openai-go/v2"
 	"github.com/rs/zerolog"
+	"os"
+	"scraper/internal/fetch"
+	"scraper/internal/geocode"
+	"sync"
+	"time"
 )
 
 type Scraper interface {
-	Scrape(pipeline Pipeline) error
+	Scrape(ctx context.Context, pipeline Pipeline) error
 }
 
+// Relocatable scrapers can be pointed at another host, which the fixture harness relies on
+type Relocatable interface {
+	Scraper
+	BaseURL() string
+	WithBaseURL(baseURL string) Scraper
+}
+
+// NewScraper builds the scraper for a source record against the live site
+func NewScraper(source common.Source, fetcher *fetch.Fetcher, logger zerolog.Logger) (Scraper, error) {
+	switch source.SourceType {
+	case common.FactoryTheatre:
+		return NewFactoryTheatreScraper(logger, fetcher), nil
+	case common.MetroTheatre:
+		return NewMetroScraper(logger, fetcher), nil
+	case common.Moshtix:
+		return NewMoshtixScraper(source, logger, fetcher), nil
+	case common.OurSecretSpot:
+		return NewOurSecretSpotScraper(logger, fetcher), nil
+	case common.Oztix:
+		return NewOztixScraper(source, logger, fetcher), nil
+	case common.Humanitix:
+		return NewHumanitixScraper(source, logger, fetcher), nil
+	case common.External:
+		return NewExternalScraper(source, logger), nil
+	}
+	return nil, fmt.Errorf("no scraper for source type %q", source.SourceType)
+}
+
+// EventSourceName is the Source_name of the events scraped for source. Built-in
+// scrapers share theirs across sources of one type, external, push and user
+// sources each have their own.
+func EventSourceName(source common.Source) string {
+	if source.SourceType == common.External || source.Pushed() {
+		return source.SourceID
+	}
+	return string(source.SourceType)
+}
+
+// DefaultSource stands in for a Sources table entry, so built-in scrapers run without one
+func DefaultSource(sourceType common.SourceType) common.Source {
+	return common.Source{
+		SourceID:   string(sourceType),
+		Name:       string(sourceType),
+		SourceType: sourceType,
+		Active:     true,
+	}
+}
+
+// EventStore is the subset of common.Db the pipeline writes through
+type EventStore interface {
+	QueryEventsBySourceAndSourceEventID(source, sourceEventID string) ([]common.Event, error)
+	QueryEventsByTicketRef(ref string, from time.Time, to time.Time) ([]common.Event, error)
+	WriteEvent(event common.Event) error
+}
+
+// ErrDuplicateEvent is returned by Process for events that are already stored or being stored
+var ErrDuplicateEvent = errors.New("duplicate event")
+
 type Deduplicator struct {
-	dbLayer common.Db
+	dbLayer EventStore
 	logger  zerolog.Logger
 }
 
-func NewDeduplicator(dbLayer common.Db, logger zerolog.Logger) Deduplicator {
+func NewDeduplicator(dbLayer EventStore, logger zerolog.Logger) Deduplicator {
 	return Deduplicator{
 		dbLayer: dbLayer,
 		logger:  logger,
 	}
 }
 
+// Existing returns the stored copy of event, or nil when there is none
+func (d *Deduplicator) Existing(event common.Event) (*common.Event, error) {
+	events, err := d.dbLayer.QueryEventsBySourceAndSourceEventID(event.Source_name, event.SourceEvent)
+	if err != nil || len(events) == 0 {
+		return nil, err
+	}
+	return &events[0], nil
+}
+
+// linkTolerance is how far apart two listings of one show can start, e.g. a
+// venue page giving doors and a ticketing system the show time
+const linkTolerance = 3 * time.Hour
+
+// Link sets the ticketing listing of event and points it at a stored event of
+// another source selling tickets through the same listing, starting within
+// linkTolerance, so the show is matched once. The first event stored is the one
+// others link to.
+func (d *Deduplicator) Link(event *common.Event) error {
+	event.TicketRef = common.TicketRef(event.TicketURL)
+	if event.TicketRef == "" {
+		// ticketing sources' own pages
+		event.TicketRef = common.TicketRef(event.URL)
+	}
+	event.LinkedTo = ""
+	if event.TicketRef == "" {
+		return nil
+	}
+	candidates, err := d.dbLayer.QueryEventsByTicketRef(event.TicketRef, event.Start.Add(-linkTolerance), event.Start.Add(linkTolerance))
+	if err != nil {
+		return err
+	}
+	for _, candidate := range candidates {
+		if candidate.Source_name != event.Source_name && candidate.EventID != event.EventID && candidate.LinkedTo == "" {
+			event.LinkedTo = candidate.EventID
+			d.logger.Debug().Msgf("Linked %s - %s to %s - %s through %s", event.Source_name, event.SourceEvent,
+				candidate.Source_name, candidate.SourceEvent, event.TicketRef)
+			return nil
+		}
+	}
+	return nil
+}
+
 func (d *Deduplicator) Deduplicate(event common.Event) (common.Event, error) {
 	// Check if an event with the same Source_name and SourceEvent already exists in the database
 	// If it exists, return an error
 	// If it doesn't exist, return the event as is
-	events, err := d.dbLayer.QueryEventsBySourceAndSourceEventID(event.Source_name, event.SourceEvent)
-	if len(events) > 0 {
-		return event, fmt.Errorf("duplicate event found: %s - %s", event.Source_name, event.SourceEvent)
+	existing, err := d.Existing(event)
+	if existing != nil {
+		return event, fmt.Errorf("%w found: %s - %s", ErrDuplicateEvent, event.Source_name, event.SourceEvent)
 	}
 	// return the event as is
 	return event, err
@@ -50,20 +156,45 @@ func NewTagger(dbLayer common.Db, logger zerolog.Logger) Tagger {
 }
 
 type PerEvent struct {
-	Index      int      `json:"index"`    // position in the input slice
-	Top5       []string `json:"top5"`     // exactly 5
-	Extended   []string `json:"extended"` // up to 10
-	Caption    string   `json:"caption"`
-	Categories []string `json:"categories"`
+	Index      int              `json:"index"`    // position in the input slice
+	Top5       []string         `json:"top5"`     // exactly 5
+	Extended   []string         `json:"extended"` // up to 10
+	Caption    string           `json:"caption"`
+	Categories []string         `json:"categories"`
+	Attributes TaggedAttributes `json:"attributes"`
 }
+
+// TaggedAttributes are the attributes the model reads from a description. It
+// mirrors common.Attributes without omitempty, as structured outputs require
+// every field.
+type TaggedAttributes struct {
+	AllAges    bool   `json:"all_ages"`
+	Licensed   bool   `json:"licensed"`
+	Wheelchair bool   `json:"wheelchair"`
+	Auslan     bool   `json:"auslan"`
+	Outdoor    bool   `json:"outdoor"`
+	Free       bool   `json:"free"`
+	Seating    string `json:"seating" jsonschema:"enum=unknown,enum=seated,enum=standing,enum=mixed"`
+}
+
+func (obj TaggedAttributes) attributes() common.Attributes {
+	result := common.Attributes(obj)
+	if common.CheckSeating(result.Seating) != nil {
+		result.Seating = ""
+	}
+	return result
+}
+
 type BatchOut struct {
 	Results []PerEvent `json:"results"`
 }
 
 var batchOutSchema = common.GenerateSchema[BatchOut]()
 
-func (obj *Tagger) Tag(events []common.Event) error {
-	eventDescriptions := make([]string, len(events))
+// Annotate asks the model for tags, a caption and categories for each event and
+// returns the events with them applied, without writing anything
+func (obj *Tagger) Annotate(ctx context.Context, events []common.Event) ([]common.Event, error) {
+	eventDescriptions := make([]string, 0, len(events))
 
 	for _, event := range events {
 		eventDescriptions = append(eventDescriptions, event.Description)
@@ -75,13 +206,15 @@ For each event i:
 - "extended": up to 10 more hashtags
 - "caption": a punchy 1-liner using 2–3 top tags
 - "categories": up to 3 categories from the set {music, culture, sex-positive, workshop, talk, other}
+- "attributes": true only for what the description states: all_ages, licensed (alcohol served),
+  wheelchair (wheelchair accessible), auslan (Auslan interpreted), outdoor, free (no ticket price);
+  "seating" is seated, standing, mixed or unknown
 
 Return ONLY JSON that conforms to the provided schema.
 Input events (0-based indices):
 %v
 `, eventDescriptions)
 
-	ctx := context.Background()
 	client := openai.NewClient()
 
 	schemaParam := openai.ResponseFormatJSONSchemaJSONSchemaParam{
@@ -100,30 +233,51 @@ Input events (0-based indices):
 		// Only certain models can perform structured outputs
 		Model: openai.ChatModelGPT4oMini,
 	})
-
 	if err != nil {
-		panic(err.Error())
+		return nil, err
 	}
 
 	// The model responds with a JSON string, so parse it into a struct
 	var batchOut BatchOut
 	err = json.Unmarshal([]byte(chat.Choices[0].Message.Content), &batchOut)
 	if err != nil {
-		panic(err.Error())
+		return nil, err
 	}
 
-	for _, result := range batchOut.Results {
-		if result.Index >= len(events) {
-			obj.logger.Warn().Msgf("Skipping out-of-bounds result (event index %d)", result.Index)
+	result := append([]common.Event(nil), events...)
+	for _, out := range batchOut.Results {
+		if out.Index < 0 || out.Index >= len(events) {
+			obj.logger.Warn().Msgf("Skipping out-of-bounds result (event index %d)", out.Index)
 			continue
 		}
 
-		event := events[result.Index]
-		event.Tags = result.Top5
-		event.ExtraTags = result.Extended
-		event.Caption = result.Caption
-		event.Categories = result.Categories
+		// categories and tags mapped by the scraper (e.g. from Moshtix genres) come first
+		event := &result[out.Index]
+		event.Tags = mergeDistinct(event.Tags, out.Top5)
+		event.ExtraTags = out.Extended
+		event.Caption = out.Caption
+		event.Categories = mergeDistinct(event.Categories, out.Categories)
+		event.Inferred = out.Attributes.attributes()
+		event.Attributes = event.Attributes.Merge(event.Inferred)
+		event.Tagged = true
+		if err := event.ApplyOverrides(); err != nil {
+			obj.logger.Warn().Msgf("Event %s - %s: %s", event.Source_name, event.SourceEvent, err.Error())
+		}
+	}
+	return result, nil
+}
 
+// Tag annotates stored events and writes the tags back
+func (obj *Tagger) Tag(events []common.Event) error {
+	tagged, err := obj.Annotate(context.Background(), events)
+	if err != nil {
+		return err
+	}
+
+	for _, event := range tagged {
+		if !event.Tagged {
+			continue
+		}
 		_, err := obj.dbLayer.UpdateEventTags(event)
 		if err != nil {
 			obj.logger.Error().Msgf("Error writing tagged event %s - %s: %s", event.Source_name, event.SourceEvent, err.Error())
@@ -135,12 +289,25 @@ Input events (0-based indices):
 	return nil
 }
 
+// mergeDistinct appends the values of b missing from a, keeping order
+func mergeDistinct(a, b []string) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	result := make([]string, 0, len(a)+len(b))
+	for _, value := range append(append([]string{}, a...), b...) {
+		if !seen[value] {
+			seen[value] = true
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
 type Saver struct {
-	dbLayer common.Db
+	dbLayer EventStore
 	logger  zerolog.Logger
 }
 
-func NewSaver(dbLayer common.Db, logger zerolog.Logger) Saver {
+func NewSaver(dbLayer EventStore, logger zerolog.Logger) Saver {
 	return Saver{
 		dbLayer: dbLayer,
 		logger:  logger,
@@ -155,50 +322,337 @@ func (obj Saver) Save(event common.Event) (common.Event, error) {
 	return event, nil
 }
 
+// number of event pages fetched / events processed concurrently per source
+const defaultWorkers = 4
+
 type Pipeline struct {
 	deduplicator Deduplicator
 	saver        Saver
-	scrapers     map[common.SourceType]Scraper
+	sourceTypes  []common.SourceType // scraped by default
+	fetcher      *fetch.Fetcher
+	workers      int
+	inflight     *sync.Map     // source/source event keys currently being processed
+	report       *ScrapeReport // set on the copy handed to a scraper for one run
+	checkpoint   common.SourceCheckpoint
+	full         bool
+	stages       []StageSpec
+	stageMetrics *stageMetrics
+	images       ImageIngester // nil leaves image URLs as scraped
+	validator    Validator
+	venues       VenueDirectory   // nil skips venue enrichment
+	geocoder     geocode.Geocoder // nil skips geocoding
+	tagger       *Tagger
+	notifier     Notifier
+	quarantine   Quarantine
 	logger       zerolog.Logger
 }
 
-func NewPipeline(dbLayer common.Db, logger zerolog.Logger) Pipeline {
+func NewPipeline(dbLayer EventStore, fetcher *fetch.Fetcher, logger zerolog.Logger) Pipeline {
 	return Pipeline{
 		logger:       logger,
 		deduplicator: NewDeduplicator(dbLayer, logger),
 		saver:        NewSaver(dbLayer, logger),
-		scrapers: map[common.SourceType]Scraper{
-			common.FactoryTheatre: NewFactoryTheatreScraper(logger),
-			common.Moshtix:        NewMoshtixScraper(logger),
-			common.MetroTheatre:   NewMetroScraper(logger),
-		},
+		fetcher:      fetcher,
+		workers:      defaultWorkers,
+		inflight:     &sync.Map{},
+		stages:       DefaultStages,
+		stageMetrics: newStageMetrics(),
+		validator:    NewValidator(DefaultRules(Sydney)),
+		venues:       NewStaticVenues(DefaultVenues),
+		geocoder:     geocode.NewCache(geocode.DefaultGazetteer()),
+		sourceTypes:  defaultSourceTypes(logger),
 	}
 }
 
-func (obj Pipeline) EventExists(source, sourceEvent string) (bool, error) {
-	events, err := obj.deduplicator.dbLayer.QueryEventsBySourceAndSourceEventID(source, sourceEvent)
-	if err != nil {
-		return false, err
+// defaultSourceTypes are the sources scraped when none are configured. The
+// ticketing APIs need keys, so their sources are left out while the keys are unset.
+func defaultSourceTypes(logger zerolog.Logger) []common.SourceType {
+	result := []common.SourceType{
+		common.FactoryTheatre,
+		common.Moshtix,
+		common.MetroTheatre,
 	}
-	return len(events) > 0, nil
+	if os.Getenv(oztixAppIDEnv) != "" && os.Getenv(oztixAPIKeyEnv) != "" {
+		result = append(result, common.Oztix)
+	} else {
+		logger.Info().Msgf("Skipping %s: %s and %s are not set", common.Oztix, oztixAppIDEnv, oztixAPIKeyEnv)
+	}
+	if os.Getenv(humanitixAPIKeyEnv) != "" {
+		result = append(result, common.Humanitix)
+	} else {
+		logger.Info().Msgf("Skipping %s: %s is not set", common.Humanitix, humanitixAPIKeyEnv)
+	}
+	return result
 }
 
-func (obj Pipeline) Process(event common.Event) (common.Event, error) {
-	event, err := obj.deduplicator.Deduplicate(event)
-	if err != nil {
+// WithStore returns a pipeline with the same stages writing to dbLayer. It
+// shares the fetcher, and so its rate limits and metrics, with obj.
+func (obj Pipeline) WithStore(dbLayer EventStore) Pipeline {
+	obj.deduplicator = NewDeduplicator(dbLayer, obj.logger)
+	obj.saver = NewSaver(dbLayer, obj.logger)
+	obj.inflight = &sync.Map{}
+	obj.stageMetrics = newStageMetrics()
+	return obj
+}
+
+// WithStages returns a pipeline running stages in order instead of DefaultStages
+func (obj Pipeline) WithStages(stages []StageSpec) (Pipeline, error) {
+	for _, spec := range stages {
+		if _, err := obj.stage(spec.Name); err != nil {
+			return obj, err
+		}
+	}
+	obj.stages = stages
+	return obj, nil
+}
+
+// ImageIngester copies event images somewhere stable and returns their new URLs
+type ImageIngester interface {
+	Ingest(ctx context.Context, urls []string) []string
+}
+
+// WithImages returns a pipeline whose images stage copies images through ingester
+func (obj Pipeline) WithImages(ingester ImageIngester) Pipeline {
+	obj.images = ingester
+	return obj
+}
+
+// WithRules returns a pipeline whose validate stage checks events against rules
+func (obj Pipeline) WithRules(rules []Rule) Pipeline {
+	obj.validator = NewValidator(rules)
+	return obj
+}
+
+func (obj Pipeline) WithVenues(venues VenueDirectory) Pipeline {
+	obj.venues = venues
+	return obj
+}
+
+// WithGeocoder returns a pipeline whose geocode stage resolves missing locations with geocoder
+func (obj Pipeline) WithGeocoder(geocoder geocode.Geocoder) Pipeline {
+	obj.geocoder = geocoder
+	return obj
+}
+
+func (obj Pipeline) WithTagger(tagger Tagger) Pipeline {
+	obj.tagger = &tagger
+	return obj
+}
+
+func (obj Pipeline) WithNotifier(notifier Notifier) Pipeline {
+	obj.notifier = notifier
+	return obj
+}
+
+func (obj Pipeline) WithQuarantine(quarantine Quarantine) Pipeline {
+	obj.quarantine = quarantine
+	return obj
+}
+
+// StageMetrics returns the per-stage counters accumulated since the pipeline was created
+func (obj Pipeline) StageMetrics() []StageMetrics {
+	return obj.stageMetrics.snapshot()
+}
+
+func (obj Pipeline) LogStageMetrics() {
+	for _, m := range obj.StageMetrics() {
+		obj.logger.Info().Msgf("Stage %s: %d processed, %d dropped, %d failed, %d retried, %d quarantined, %s",
+			m.Name, m.Processed, m.Dropped, m.Failed, m.Retried, m.Quarantined, m.Duration)
+	}
+}
+
+// Process runs event through the configured stages. It is safe for concurrent use.
+// Two workers handing over the same source event at the same time would both pass
+// deduplication, so the second one is rejected here.
+func (obj Pipeline) Process(ctx context.Context, event common.Event) (common.Event, error) {
+	key := event.Source_name + "/" + event.SourceEvent
+	if _, busy := obj.inflight.LoadOrStore(key, struct{}{}); busy {
+		err := fmt.Errorf("%w in flight: %s - %s", ErrDuplicateEvent, event.Source_name, event.SourceEvent)
 		obj.logger.Info().Msgf("Deduplication: %s", err.Error())
 		return event, err
 	}
+	defer obj.inflight.Delete(key)
 
-	event, err = obj.saver.Save(event)
+	item := Item{Event: event}
+	for _, spec := range obj.stages {
+		stage, err := obj.stage(spec.Name)
+		if err == nil {
+			err = obj.runStage(ctx, spec, stage, &item)
+		}
+		if errors.Is(err, ErrDuplicateEvent) {
+			obj.logger.Info().Msgf("Deduplication: %s", err.Error())
+			return item.Event, err
+		}
+		if err != nil {
+			obj.logger.Info().Msgf("%s - %s: %s", event.Source_name, event.SourceEvent, err.Error())
+			obj.fail(key, err)
+			return item.Event, err
+		}
+	}
+
+	if obj.report != nil {
+		obj.report.count(func(report *ScrapeReport) {
+			if item.Existing != nil {
+				report.EventsUpdated++
+			} else {
+				report.EventsNew++
+			}
+		})
+	}
+	return item.Event, nil
+}
+
+// eventChanged compares what a scraper produces, ignoring IDs, fetch time and tagging
+func eventChanged(stored common.Event, scraped common.Event) bool {
+	return scrapedContent(stored) != scrapedContent(scraped)
+}
+
+func scrapedContent(event common.Event) string {
+	event = asScraped(event)
+	return fingerprint([]any{
+		event.Title, event.Description, event.Start.UTC(), event.End.UTC(), event.VenueName,
+		event.Address, event.Geo, event.URL, event.TicketURL, event.PriceMin, event.PriceMax,
+		event.Images, event.ContentFlags, event.Attributes,
+	})
+}
+
+// asScraped puts back the image URLs a stored event was scraped with
+func asScraped(event common.Event) common.Event {
+	if len(event.SourceImages) > 0 {
+		event.Images = event.SourceImages
+	}
+	return event
+}
+
+// carryOver keeps the identity of the stored event, its tagging once done and
+// its editorial overrides, so an update replaces the item rather than adding a
+// second one, and does not undo an editor's fixes. Attributes are the scraped
+// ones plus those tagging inferred, so a flag the source drops is cleared.
+func carryOver(stored common.Event, scraped common.Event) common.Event {
+	scraped.EventID = stored.EventID
+	if stored.Tagged {
+		scraped.Tags = stored.Tags
+		scraped.ExtraTags = stored.ExtraTags
+		scraped.Caption = stored.Caption
+		scraped.Categories = stored.Categories
+		scraped.Inferred = stored.Inferred
+		scraped.Attributes = scraped.Attributes.Merge(stored.Inferred)
+		scraped.Tagged = true
+	}
+	scraped.Overrides = stored.Overrides
+	// overrides are checked when set, one that no longer parses is left out
+	_ = scraped.ApplyOverrides()
+	return scraped
+}
+
+// Skip records a URL the scraper deliberately did not fetch in the current run's report
+func (obj Pipeline) Skip(url string, reason string) {
+	obj.logger.Info().Msgf("Skipping %s: %s", url, reason)
+	if obj.report != nil {
+		obj.report.Skip(url, reason)
+	}
+}
+
+// PageFetched counts a listing or event page fetched in the current run
+func (obj Pipeline) PageFetched() {
+	if obj.report != nil {
+		obj.report.count(func(report *ScrapeReport) { report.PagesFetched++ })
+	}
+}
+
+// Found counts events seen on a listing in the current run, known or not
+func (obj Pipeline) Found(count int) {
+	if obj.report != nil {
+		obj.report.count(func(report *ScrapeReport) { report.EventsFound += count })
+	}
+}
+
+// Fail counts an event that could not be scraped or saved, keeping a sample of the error
+func (obj Pipeline) Fail(subject string, err error) {
+	obj.fail(subject, err)
+}
+
+func (obj Pipeline) fail(subject string, err error) {
+	if obj.report != nil {
+		obj.report.fail(subject, err)
+	}
+}
+
+// skipIfDisallowed records url as skipped when err comes from robots.txt
+func (obj Pipeline) skipIfDisallowed(url string, err error) bool {
+	if !errors.Is(err, fetch.ErrDisallowed) {
+		return false
+	}
+	obj.Skip(url, fetch.ErrDisallowed.Error())
+	return true
+}
+
+// DefaultSources returns a stand-in source for every scraper run by default
+func (obj Pipeline) DefaultSources() []common.Source {
+	result := make([]common.Source, len(obj.sourceTypes))
+	for i, sourceType := range obj.sourceTypes {
+		result[i] = DefaultSource(sourceType)
+	}
+	return result
+}
+
+// Scrape runs the scraper for source. Unless a full run is requested or due, the
+// scraper may stop at events the source checkpoint already knows. On success the
+// report carries the checkpoint for the next run.
+func (obj Pipeline) Scrape(ctx context.Context, source common.Source, options ScrapeOptions) (*ScrapeReport, error) {
+	report := NewScrapeReport(source)
+	started := report.StartedAt
+	scraper, err := NewScraper(source, obj.fetcher, obj.logger)
 	if err != nil {
-		obj.logger.Info().Msgf("Tagging: %s", err.Error())
-		return event, err
+		report.finish(err)
+		return report, err
 	}
 
-	return event, nil
+	run := obj
+	run.report = report
+	run.checkpoint = source.Checkpoint
+	run.full = needsFullRun(source.Checkpoint, options, started)
+	report.Full = run.full
+	err = scraper.Scrape(ctx, run)
+	report.finish(err)
+	if err != nil {
+		return report, err
+	}
+	checkpoint := nextCheckpoint(source.Checkpoint, report, started, run.full)
+	report.Checkpoint = &checkpoint
+	return report, nil
+}
+
+// LogFetchMetrics logs the per-host HTTP counters accumulated since the pipeline was created
+func (obj Pipeline) LogFetchMetrics() {
+	obj.fetcher.LogMetrics()
 }
 
-func (obj Pipeline) Scrape(sourceType common.SourceType) error {
-	return obj.scrapers[sourceType].Scrape(obj)
+// ScrapeAll scrapes sources concurrently and returns their reports and the joined errors
+func (obj Pipeline) ScrapeAll(ctx context.Context, sources []common.Source, options ScrapeOptions) ([]*ScrapeReport, error) {
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		reports []*ScrapeReport
+		errs    []error
+	)
+	for _, source := range sources {
+		wg.Add(1)
+		go func(source common.Source) {
+			defer wg.Done()
+			obj.logger.Info().Msgf("Scraping source %s (%s)", source.SourceID, source.SourceType)
+			report, err := obj.Scrape(ctx, source, options)
+
+			mu.Lock()
+			defer mu.Unlock()
+			reports = append(reports, report)
+			if err != nil {
+				obj.logger.Error().Msgf("Scraping source %s failed: %s", source.SourceID, err.Error())
+				errs = append(errs, fmt.Errorf("%s: %w", source.SourceID, err))
+			}
+		}(source)
+	}
+	wg.Wait()
+
+	return reports, errors.Join(errs...)
 }
@@ -0,0 +1,103 @@
+package venuescrapers
+
+import (
+	"common"
+	"encoding/json"
+	"github.com/rs/zerolog"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeDynamoDB returns a Db that accepts every request, and the expression
+// attribute values of the UpdateItem requests it got
+func fakeDynamoDB(t *testing.T) (common.Db, *[]map[string]json.RawMessage) {
+	var updates []map[string]json.RawMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if strings.HasSuffix(r.Header.Get("X-Amz-Target"), ".UpdateItem") {
+			var request struct{ ExpressionAttributeValues map[string]json.RawMessage }
+			if err := json.Unmarshal(body, &request); err != nil {
+				t.Error(err)
+			}
+			updates = append(updates, request.ExpressionAttributeValues)
+		}
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		w.Write([]byte("{}"))
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+
+	db, err := common.NewDb(server.URL, "ap-southeast-2", zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, &updates
+}
+
+// An editor's fixes outlive a re-scrape of the event and the tagging that follows
+func TestOverridesSurviveRescrapeAndTagging(t *testing.T) {
+	start := time.Date(2030, 5, 1, 10, 0, 0, 0, time.UTC)
+	stored := common.Event{
+		EventID:     "e1",
+		Source_name: "moshtix",
+		SourceEvent: "170001",
+		Title:       "SCRAPED TITLE",
+		Start:       start,
+		Tags:        []string{"#rock"},
+		Categories:  []string{"music"},
+		Tagged:      true,
+	}
+	at := time.Date(2030, 4, 1, 0, 0, 0, 0, time.UTC)
+	for field, value := range map[string]string{
+		"title": `"Scraped Title"`,
+		"start": `"2030-05-01T20:00:00+10:00"`,
+		"tags":  `["#psychrock"]`,
+	} {
+		if err := stored.SetOverride(field, json.RawMessage(value), "editor", at); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	scraped := common.Event{
+		EventID:     "new",
+		Source_name: "moshtix",
+		SourceEvent: "170001",
+		Title:       "SCRAPED TITLE (SOLD OUT)",
+		Start:       start.Add(time.Hour),
+		PriceMin:    45,
+	}
+	event := carryOver(stored, scraped)
+	if event.EventID != "e1" || event.PriceMin != 45 {
+		t.Errorf("got ID %s, price %v, want the stored ID and the scraped price", event.EventID, event.PriceMin)
+	}
+	if event.Title != "Scraped Title" || !event.Start.Equal(start) {
+		t.Errorf("got title %q, start %s, want the overridden ones", event.Title, event.Start)
+	}
+	if len(event.Overrides) != 3 {
+		t.Errorf("overrides %v, want the three stored ones", event.OverriddenFields())
+	}
+
+	// tagging comes up with tags of its own, the overridden ones are written
+	db, updates := fakeDynamoDB(t)
+	event.Tags = []string{"#garage", "#live"}
+	event.Categories = []string{"culture"}
+	if _, err := db.UpdateEventTags(event); err != nil {
+		t.Fatal(err)
+	}
+	if len(*updates) != 1 {
+		t.Fatalf("%d UpdateItem requests, want 1", len(*updates))
+	}
+	values := (*updates)[0]
+	if got := string(values[":tags"]); got != `{"L":[{"S":"#psychrock"}]}` {
+		t.Errorf("tags written as %s, want the overridden #psychrock", got)
+	}
+	if got := string(values[":categories"]); got != `{"L":[{"S":"culture"}]}` {
+		t.Errorf("categories written as %s, want the tagger's", got)
+	}
+}
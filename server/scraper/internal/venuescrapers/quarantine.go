@@ -0,0 +1,55 @@
+package venuescrapers
+
+import (
+	"common"
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// QuarantineTable is the part of the database the quarantine is kept in
+type QuarantineTable interface {
+	WriteQuarantinedEvent(event common.QuarantinedEvent) error
+}
+
+// TableQuarantine keeps rejected events in the Quarantine table
+type TableQuarantine struct {
+	table QuarantineTable
+}
+
+func NewTableQuarantine(table QuarantineTable) TableQuarantine {
+	return TableQuarantine{table: table}
+}
+
+func (obj TableQuarantine) Quarantine(ctx context.Context, event common.Event, stage string, reason error) error {
+	entry, err := NewQuarantinedEvent(event, stage, reason)
+	if err != nil {
+		return err
+	}
+	return obj.table.WriteQuarantinedEvent(entry)
+}
+
+func NewQuarantinedEvent(event common.Event, stage string, reason error) (common.QuarantinedEvent, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return common.QuarantinedEvent{}, err
+	}
+	return common.QuarantinedEvent{
+		QuarantineID:  common.QuarantineID(event.Source_name, event.SourceEvent),
+		SourceName:    event.Source_name,
+		SourceEvent:   event.SourceEvent,
+		Stage:         stage,
+		Reasons:       quarantineReasons(reason),
+		Payload:       string(payload),
+		QuarantinedAt: time.Now(),
+	}, nil
+}
+
+func quarantineReasons(err error) []string {
+	var invalid ValidationError
+	if errors.As(err, &invalid) {
+		return invalid.Reasons()
+	}
+	return []string{err.Error()}
+}
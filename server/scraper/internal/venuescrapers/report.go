@@ -0,0 +1,168 @@
+package venuescrapers
+
+import (
+	"common"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// the first few failures are kept on the run, the rest only counted
+const maxErrorSamples = 10
+
+type SkippedURL struct {
+	URL    string `json:"url"`
+	Reason string `json:"reason"`
+}
+
+// ScrapeReport collects what happened during one scrape of one source
+type ScrapeReport struct {
+	mu           sync.Mutex
+	SourceID     string            `json:"source_id"`
+	SourceType   common.SourceType `json:"source_type"`
+	Full         bool              `json:"full"`
+	StoppedEarly bool              `json:"stopped_early"`
+	Skipped      []SkippedURL      `json:"skipped"`
+
+	StartedAt     time.Time `json:"started_at"`
+	FinishedAt    time.Time `json:"finished_at"`
+	PagesFetched  int       `json:"pages_fetched"`
+	EventsFound   int       `json:"events_found"`
+	EventsNew     int       `json:"events_new"`
+	EventsUpdated int       `json:"events_updated"`
+	EventsFailed  int       `json:"events_failed"`
+	ErrorSamples  []string  `json:"error_samples"`
+	Error         string    `json:"error"` // set when the run itself failed
+
+	// Checkpoint is set once the run succeeded and is what the next run should start from
+	Checkpoint *common.SourceCheckpoint `json:"-"`
+
+	seen    map[string]string
+	listing string
+}
+
+func NewScrapeReport(source common.Source) *ScrapeReport {
+	return &ScrapeReport{
+		SourceID:   source.SourceID,
+		SourceType: source.SourceType,
+		StartedAt:  time.Now(),
+		seen:       map[string]string{},
+	}
+}
+
+// Run summarises the report as stored in the ScrapeRuns table
+func (obj *ScrapeReport) Run() common.ScrapeRun {
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+
+	return common.ScrapeRun{
+		SourceID:      obj.SourceID,
+		StartedAt:     obj.StartedAt,
+		FinishedAt:    obj.FinishedAt,
+		SourceType:    obj.SourceType,
+		Full:          obj.Full,
+		StoppedEarly:  obj.StoppedEarly,
+		PagesFetched:  obj.PagesFetched,
+		EventsFound:   obj.EventsFound,
+		EventsNew:     obj.EventsNew,
+		EventsUpdated: obj.EventsUpdated,
+		EventsFailed:  obj.EventsFailed,
+		URLsSkipped:   len(obj.Skipped),
+		ErrorSamples:  append([]string(nil), obj.ErrorSamples...),
+		Error:         obj.Error,
+	}
+}
+
+func (obj *ScrapeReport) Skip(url string, reason string) {
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+
+	obj.Skipped = append(obj.Skipped, SkippedURL{URL: url, Reason: reason})
+}
+
+func (obj *ScrapeReport) see(sourceEventID string, fingerprint string) {
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+
+	obj.seen[sourceEventID] = fingerprint
+}
+
+func (obj *ScrapeReport) setListing(fingerprint string) {
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+
+	obj.listing = fingerprint
+}
+
+func (obj *ScrapeReport) stopEarly() {
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+
+	obj.StoppedEarly = true
+}
+
+func (obj *ScrapeReport) count(fn func(report *ScrapeReport)) {
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+
+	fn(obj)
+}
+
+func (obj *ScrapeReport) fail(subject string, err error) {
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+
+	obj.EventsFailed++
+	if len(obj.ErrorSamples) < maxErrorSamples {
+		obj.ErrorSamples = append(obj.ErrorSamples, fmt.Sprintf("%s: %s", subject, err.Error()))
+	}
+}
+
+func (obj *ScrapeReport) finish(err error) {
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+
+	obj.FinishedAt = time.Now()
+	if err != nil {
+		obj.Error = err.Error()
+	}
+}
+
+const (
+	AnomalyHistory   = 5   // trailing runs a new run is compared with
+	anomalyMinRuns   = 3   // comparable runs needed before judging
+	anomalyDropRatio = 0.5 // events found below this share of the trailing average
+)
+
+// FlagAnomaly marks run when it looks like the scraper broke: the run failed,
+// most events failed, or it found far fewer events than the trailing average.
+// Runs that stopped early on a checkpoint are not comparable and are left alone.
+func FlagAnomaly(run *common.ScrapeRun, history []common.ScrapeRun) {
+	switch {
+	case run.Error != "":
+		run.Anomaly, run.AnomalyReason = true, "run failed"
+		return
+	case run.EventsFound > 0 && run.EventsFailed*2 > run.EventsFound:
+		run.Anomaly, run.AnomalyReason = true, fmt.Sprintf("%d of %d events failed", run.EventsFailed, run.EventsFound)
+		return
+	case run.StoppedEarly:
+		return
+	}
+
+	total, count := 0, 0
+	for _, previous := range history {
+		if previous.Error != "" || previous.StoppedEarly {
+			continue
+		}
+		total += previous.EventsFound
+		count++
+	}
+	if count < anomalyMinRuns || total == 0 {
+		return
+	}
+	average := float64(total) / float64(count)
+	if float64(run.EventsFound) < average*anomalyDropRatio {
+		run.Anomaly = true
+		run.AnomalyReason = fmt.Sprintf("found %d events, trailing average %.1f", run.EventsFound, average)
+	}
+}
@@ -0,0 +1,220 @@
+package venuescrapers
+
+import (
+	"common"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	FormatJSONLines = "jsonl"
+	FormatTable     = "table"
+)
+
+// diff status of a scraped event against the stored copy
+const (
+	StatusNew         = "new"
+	StatusChanged     = "changed"
+	StatusUnchanged   = "unchanged"
+	StatusQuarantined = "quarantined"
+)
+
+// fields a scraper fills in, compared when diffing
+var scrapedFields = []string{
+	"Title", "Description", "Start", "End", "VenueName", "Address", "Geo",
+	"URL", "TicketURL", "PriceMin", "PriceMax", "Images", "ContentFlags", "Attributes",
+}
+
+type FieldChange struct {
+	Field   string `json:"field"`
+	Stored  any    `json:"stored"`
+	Scraped any    `json:"scraped"`
+}
+
+type SinkRecord struct {
+	Status  string        `json:"status,omitempty"`
+	Changes []FieldChange `json:"changes,omitempty"`
+	Reasons []string      `json:"reasons,omitempty"` // why the event was quarantined
+	Event   common.Event  `json:"event"`
+}
+
+// Sink is an EventStore that writes events out instead of storing them, for
+// dry runs. Lookups only see events written during the run, so every scraped
+// event comes through; with a reference store each one is diffed against the
+// stored copy. As a Quarantine it writes out rejected events too.
+type Sink struct {
+	mu        sync.Mutex
+	out       io.Writer
+	table     *tabwriter.Writer
+	format    string
+	reference EventStore
+	written   map[string]common.Event
+}
+
+func NewSink(out io.Writer, format string, reference EventStore) (*Sink, error) {
+	obj := &Sink{
+		out:       out,
+		format:    format,
+		reference: reference,
+		written:   map[string]common.Event{},
+	}
+	switch format {
+	case FormatJSONLines:
+	case FormatTable:
+		obj.table = tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(obj.table, "SOURCE\tSTART\tTITLE\tVENUE\tPRICE\tSTATUS")
+	default:
+		return nil, fmt.Errorf("unknown output format %q", format)
+	}
+	return obj, nil
+}
+
+func (obj *Sink) QueryEventsBySourceAndSourceEventID(source, sourceEventID string) ([]common.Event, error) {
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+
+	if event, ok := obj.written[source+"/"+sourceEventID]; ok {
+		return []common.Event{event}, nil
+	}
+	return nil, nil
+}
+
+// QueryEventsByTicketRef finds events written in this run, and stored ones when
+// there is a reference store
+func (obj *Sink) QueryEventsByTicketRef(ref string, from time.Time, to time.Time) ([]common.Event, error) {
+	var result []common.Event
+	if obj.reference != nil {
+		stored, err := obj.reference.QueryEventsByTicketRef(ref, from, to)
+		if err != nil {
+			return nil, err
+		}
+		result = stored
+	}
+
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+	for _, event := range obj.written {
+		if event.TicketRef == ref && !event.Start.Before(from) && !event.Start.After(to) {
+			result = append(result, event)
+		}
+	}
+	return result, nil
+}
+
+func (obj *Sink) WriteEvent(event common.Event) error {
+	record := SinkRecord{Event: event}
+	if obj.reference != nil {
+		stored, err := obj.reference.QueryEventsBySourceAndSourceEventID(event.Source_name, event.SourceEvent)
+		if err != nil {
+			return err
+		}
+		record.Status = StatusNew
+		if len(stored) > 0 {
+			record.Changes = diffEvents(asScraped(stored[0]), event)
+			record.Status = StatusUnchanged
+			if len(record.Changes) > 0 {
+				record.Status = StatusChanged
+			}
+		}
+	}
+
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+
+	obj.written[event.Source_name+"/"+event.SourceEvent] = event
+	return obj.write(record)
+}
+
+func (obj *Sink) Quarantine(ctx context.Context, event common.Event, stage string, reason error) error {
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+
+	return obj.write(SinkRecord{Status: StatusQuarantined, Reasons: quarantineReasons(reason), Event: event})
+}
+
+func (obj *Sink) write(record SinkRecord) error {
+	if obj.table != nil {
+		return obj.writeRow(record)
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = obj.out.Write(append(line, '\n'))
+	return err
+}
+
+func (obj *Sink) writeRow(record SinkRecord) error {
+	event := record.Event
+	price := "-"
+	if event.PriceMin > 0 || event.PriceMax > 0 {
+		price = fmt.Sprintf("$%.2f-$%.2f", event.PriceMin, event.PriceMax)
+	}
+	row := []string{
+		event.Source_name,
+		event.Start.Local().Format("Mon 02 Jan 2006 15:04"),
+		truncate(event.Title, 50),
+		truncate(event.VenueName, 30),
+		price,
+	}
+	details := record.Reasons
+	for _, change := range record.Changes {
+		details = append(details, change.Field)
+	}
+	status := record.Status
+	if len(details) > 0 {
+		status += " " + strings.Join(details, "; ")
+	}
+	row = append(row, status)
+	_, err := fmt.Fprintln(obj.table, strings.Join(row, "\t"))
+	return err
+}
+
+// Flush writes out buffered table rows; call it once the run is over
+func (obj *Sink) Flush() error {
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+
+	if obj.table != nil {
+		return obj.table.Flush()
+	}
+	return nil
+}
+
+func diffEvents(stored common.Event, scraped common.Event) []FieldChange {
+	var changes []FieldChange
+	storedValue, scrapedValue := reflect.ValueOf(stored), reflect.ValueOf(scraped)
+	for _, field := range scrapedFields {
+		a, b := storedValue.FieldByName(field).Interface(), scrapedValue.FieldByName(field).Interface()
+		if sameValue(a, b) {
+			continue
+		}
+		changes = append(changes, FieldChange{Field: field, Stored: a, Scraped: b})
+	}
+	return changes
+}
+
+func sameValue(a, b any) bool {
+	if at, ok := a.(time.Time); ok {
+		return at.Equal(b.(time.Time))
+	}
+	if as, ok := a.([]string); ok && len(as) == 0 {
+		return len(b.([]string)) == 0
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func truncate(s string, max int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if runes := []rune(s); len(runes) > max {
+		return string(runes[:max-1]) + "…"
+	}
+	return s
+}
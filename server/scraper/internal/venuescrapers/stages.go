@@ -0,0 +1,380 @@
+package venuescrapers
+
+import (
+	"common"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/rs/zerolog"
+	"scraper/internal/geocode"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Item is an event on its way through the stages, with what earlier stages learnt about it
+type Item struct {
+	Event    common.Event
+	Existing *common.Event // stored copy, found by the dedupe stage
+}
+
+// Stage is one processing step of the pipeline. Stages are built per event from
+// the pipeline's dependencies, see Pipeline.stage.
+type Stage interface {
+	Name() string
+	Process(ctx context.Context, item *Item) error
+}
+
+const (
+	StageNormalize   = "normalize"
+	StageValidate    = "validate"
+	StageEnrichVenue = "enrich-venue"
+	StageGeocode     = "geocode"
+	StageDedupe      = "dedupe"
+	StageImages      = "images"
+	StageTag         = "tag"
+	StageSave        = "save"
+	StageNotify      = "notify"
+)
+
+type ErrorPolicy string
+
+const (
+	PolicySkip       ErrorPolicy = "skip"       // drop the event and count it as failed
+	PolicyRetry      ErrorPolicy = "retry"      // try the stage again with backoff, then skip
+	PolicyQuarantine ErrorPolicy = "quarantine" // set the event aside for review, then skip
+)
+
+const (
+	defaultRetries = 3
+	retryBackoff   = 200 * time.Millisecond
+)
+
+type StageSpec struct {
+	Name    string
+	Policy  ErrorPolicy
+	Retries int // attempts after the first, for PolicyRetry
+}
+
+// DefaultStages is the chain used unless configured otherwise. Tagging stays a
+// separate command by default: it calls the LLM and is cheaper in batches.
+var DefaultStages = []StageSpec{
+	{Name: StageNormalize, Policy: PolicySkip},
+	{Name: StageEnrichVenue, Policy: PolicySkip},
+	{Name: StageGeocode, Policy: PolicyRetry, Retries: defaultRetries},
+	{Name: StageValidate, Policy: PolicyQuarantine},
+	{Name: StageDedupe, Policy: PolicyRetry, Retries: defaultRetries},
+	{Name: StageImages, Policy: PolicySkip},
+	{Name: StageSave, Policy: PolicyRetry, Retries: defaultRetries},
+}
+
+// ParseStages reads a chain such as "normalize,validate:quarantine,dedupe,save:retry:5".
+// Each entry is a stage name, optionally followed by its error policy and retry count.
+func ParseStages(spec string) ([]StageSpec, error) {
+	var result []StageSpec
+	for _, entry := range strings.Split(spec, ",") {
+		parts := strings.Split(strings.TrimSpace(entry), ":")
+		if parts[0] == "" {
+			continue
+		}
+		stage := StageSpec{Name: parts[0], Policy: PolicySkip}
+		if len(parts) > 1 {
+			stage.Policy = ErrorPolicy(parts[1])
+		}
+		switch stage.Policy {
+		case PolicySkip, PolicyQuarantine:
+		case PolicyRetry:
+			stage.Retries = defaultRetries
+		default:
+			return nil, fmt.Errorf("stage %s: unknown error policy %q", stage.Name, stage.Policy)
+		}
+		if len(parts) > 2 {
+			retries, err := strconv.Atoi(parts[2])
+			if err != nil || retries < 0 {
+				return nil, fmt.Errorf("stage %s: invalid retry count %q", stage.Name, parts[2])
+			}
+			stage.Retries = retries
+		}
+		result = append(result, stage)
+	}
+	if len(result) == 0 {
+		return nil, errors.New("no stages configured")
+	}
+	return result, nil
+}
+
+// Quarantine keeps events a stage rejected under PolicyQuarantine
+type Quarantine interface {
+	Quarantine(ctx context.Context, event common.Event, stage string, reason error) error
+}
+
+// Notifier is told about every new or updated event that made it through the chain
+type Notifier interface {
+	Notify(ctx context.Context, event common.Event, updated bool) error
+}
+
+type StageMetrics struct {
+	Name        string        `json:"name"`
+	Processed   int           `json:"processed"`
+	Dropped     int           `json:"dropped"` // duplicates, not failures
+	Failed      int           `json:"failed"`
+	Retried     int           `json:"retried"`
+	Quarantined int           `json:"quarantined"`
+	Duration    time.Duration `json:"duration"` // cumulative
+}
+
+type stageMetrics struct {
+	mu     sync.Mutex
+	stages map[string]*StageMetrics
+}
+
+func newStageMetrics() *stageMetrics {
+	return &stageMetrics{stages: map[string]*StageMetrics{}}
+}
+
+func (obj *stageMetrics) update(name string, fn func(m *StageMetrics)) {
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+
+	m, ok := obj.stages[name]
+	if !ok {
+		m = &StageMetrics{Name: name}
+		obj.stages[name] = m
+	}
+	fn(m)
+}
+
+func (obj *stageMetrics) snapshot() []StageMetrics {
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+
+	result := make([]StageMetrics, 0, len(obj.stages))
+	for _, m := range obj.stages {
+		result = append(result, *m)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// runStage applies the spec's error policy around one stage
+func (obj Pipeline) runStage(ctx context.Context, spec StageSpec, stage Stage, item *Item) error {
+	attempts := 1
+	if spec.Policy == PolicyRetry {
+		attempts += spec.Retries
+	}
+
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			obj.stageMetrics.update(spec.Name, func(m *StageMetrics) { m.Retried++ })
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(retryBackoff << (attempt - 1)):
+			}
+		}
+
+		// a failed attempt must not leave half-applied changes behind
+		attemptItem := *item
+		started := time.Now()
+		err = stage.Process(ctx, &attemptItem)
+		elapsed := time.Since(started)
+
+		if err == nil {
+			*item = attemptItem
+			obj.stageMetrics.update(spec.Name, func(m *StageMetrics) { m.Processed++; m.Duration += elapsed })
+			return nil
+		}
+		obj.stageMetrics.update(spec.Name, func(m *StageMetrics) { m.Duration += elapsed })
+		if errors.Is(err, ErrDuplicateEvent) {
+			obj.stageMetrics.update(spec.Name, func(m *StageMetrics) { m.Dropped++ })
+			return err
+		}
+	}
+
+	obj.stageMetrics.update(spec.Name, func(m *StageMetrics) { m.Failed++ })
+	err = fmt.Errorf("stage %s: %w", spec.Name, err)
+	if spec.Policy == PolicyQuarantine {
+		if obj.quarantine == nil {
+			obj.logger.Warn().Msgf("No quarantine configured, skipping %s - %s: %s", item.Event.Source_name, item.Event.SourceEvent, err.Error())
+		} else if qerr := obj.quarantine.Quarantine(ctx, item.Event, spec.Name, err); qerr != nil {
+			obj.logger.Error().Msgf("Could not quarantine %s - %s: %s", item.Event.Source_name, item.Event.SourceEvent, qerr.Error())
+		} else {
+			obj.stageMetrics.update(spec.Name, func(m *StageMetrics) { m.Quarantined++ })
+		}
+	}
+	return err
+}
+
+// stage builds the named stage from the pipeline's dependencies
+func (obj Pipeline) stage(name string) (Stage, error) {
+	switch name {
+	case StageNormalize:
+		return normalizeStage{}, nil
+	case StageValidate:
+		return validateStage{validator: obj.validator}, nil
+	case StageEnrichVenue:
+		return venueStage{venues: obj.venues}, nil
+	case StageGeocode:
+		return geocodeStage{geocoder: obj.geocoder, logger: obj.logger}, nil
+	case StageDedupe:
+		return dedupeStage{deduplicator: obj.deduplicator}, nil
+	case StageImages:
+		return imageStage{ingester: obj.images}, nil
+	case StageTag:
+		if obj.tagger == nil {
+			return nil, errors.New("tag stage needs a tagger")
+		}
+		return tagStage{tagger: obj.tagger}, nil
+	case StageSave:
+		return saveStage{saver: obj.saver}, nil
+	case StageNotify:
+		if obj.notifier == nil {
+			return nil, errors.New("notify stage needs a notifier")
+		}
+		return notifyStage{notifier: obj.notifier}, nil
+	}
+	return nil, fmt.Errorf("unknown stage %q", name)
+}
+
+type normalizeStage struct{}
+
+func (normalizeStage) Name() string { return StageNormalize }
+
+// Process tidies the whitespace scraped HTML leaves in text fields
+func (normalizeStage) Process(ctx context.Context, item *Item) error {
+	event := &item.Event
+	event.Title = strings.Join(strings.Fields(event.Title), " ")
+	event.VenueName = strings.Join(strings.Fields(event.VenueName), " ")
+	event.Description = strings.TrimSpace(event.Description)
+	event.URL = strings.TrimSpace(event.URL)
+	event.Images = distinct(event.Images)
+	event.Tags = distinct(event.Tags)
+	event.Categories = distinct(event.Categories)
+	return nil
+}
+
+// distinct drops repeated values, leaving nil as nil
+func distinct(values []string) []string {
+	if len(values) == 0 {
+		return values
+	}
+	return mergeDistinct(nil, values)
+}
+
+type geocodeStage struct {
+	geocoder geocode.Geocoder
+	logger   zerolog.Logger
+}
+
+func (geocodeStage) Name() string { return StageGeocode }
+
+// Process locates events the source and venue directory left without coordinates.
+// An event that cannot be located goes on without them.
+func (obj geocodeStage) Process(ctx context.Context, item *Item) error {
+	event := &item.Event
+	if event.Geo != (common.Geo{}) {
+		if event.GeoPrecision == "" {
+			event.GeoPrecision = string(geocode.PrecisionSource)
+		}
+		return nil
+	}
+	if obj.geocoder == nil {
+		return nil
+	}
+
+	result, err := obj.geocoder.Geocode(ctx, geocode.Query{Venue: event.VenueName, Address: event.Address})
+	if errors.Is(err, geocode.ErrNotFound) {
+		obj.logger.Debug().Msgf("No location for %s - %s at %s", event.Source_name, event.SourceEvent, event.VenueName)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	event.Geo = result.Geo
+	event.GeoPrecision = string(result.Precision)
+	return nil
+}
+
+type dedupeStage struct {
+	deduplicator Deduplicator
+}
+
+func (dedupeStage) Name() string { return StageDedupe }
+
+// Process drops events stored before and unchanged since. Changed ones keep the
+// identity of the stored copy, so saving replaces it. New and changed events are
+// linked to other sources' events selling the same tickets.
+func (obj dedupeStage) Process(ctx context.Context, item *Item) error {
+	existing, err := obj.deduplicator.Existing(item.Event)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		// compared with overrides applied, or every overridden event would look changed
+		event := carryOver(*existing, item.Event)
+		if !eventChanged(*existing, event) {
+			return fmt.Errorf("%w found: %s - %s", ErrDuplicateEvent, item.Event.Source_name, item.Event.SourceEvent)
+		}
+		item.Existing = existing
+		item.Event = event
+	}
+	return obj.deduplicator.Link(&item.Event)
+}
+
+type imageStage struct {
+	ingester ImageIngester
+}
+
+func (imageStage) Name() string { return StageImages }
+
+func (obj imageStage) Process(ctx context.Context, item *Item) error {
+	if obj.ingester == nil || len(item.Event.Images) == 0 {
+		return nil
+	}
+	item.Event.SourceImages = item.Event.Images
+	item.Event.Images = obj.ingester.Ingest(ctx, item.Event.Images)
+	return nil
+}
+
+type tagStage struct {
+	tagger *Tagger
+}
+
+func (tagStage) Name() string { return StageTag }
+
+func (obj tagStage) Process(ctx context.Context, item *Item) error {
+	if item.Event.Tagged {
+		return nil
+	}
+	tagged, err := obj.tagger.Annotate(ctx, []common.Event{item.Event})
+	if err != nil {
+		return err
+	}
+	item.Event = tagged[0]
+	return nil
+}
+
+type saveStage struct {
+	saver Saver
+}
+
+func (saveStage) Name() string { return StageSave }
+
+func (obj saveStage) Process(ctx context.Context, item *Item) error {
+	event, err := obj.saver.Save(item.Event)
+	item.Event = event
+	return err
+}
+
+type notifyStage struct {
+	notifier Notifier
+}
+
+func (notifyStage) Name() string { return StageNotify }
+
+func (obj notifyStage) Process(ctx context.Context, item *Item) error {
+	return obj.notifier.Notify(ctx, item.Event, item.Existing != nil)
+}
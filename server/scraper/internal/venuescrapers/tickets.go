@@ -0,0 +1,138 @@
+package venuescrapers
+
+import (
+	"common"
+	"github.com/PuerkitoBio/goquery"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Venue pages give prices as text: "$35 + booking fee", "From $25", "$40 / $35 conc",
+// "FREE". Booking fees are not part of the price.
+
+var (
+	priceAmount = regexp.MustCompile(`\$\s*(\d{1,4}(?:,\d{3})*(?:\.\d{1,2})?)`)
+	priceFrom   = regexp.MustCompile(`\bfrom\s*\$`)
+	bookingFee  = regexp.MustCompile(`\(?\s*(?:\+|\bplus\b|\binc\w*\.?)\s*\$\s*[\d.,]+\s*(?:bf\b|b/f\b|(?:booking|transaction|service|handling|card)[\s-]*fees?)\s*\)?` +
+		`|(?:booking|transaction|service|handling|card)[\s-]*fees?\s*:?\s*(?:of\s*)?\$\s*[\d.,]+`)
+
+	// "free" on its own or as the price of entry, not "free drink on entry" or "gluten free"
+	priceFree = regexp.MustCompile(`(?m)^\W*free\W*$|\bfree\s+(?:entry|admission|event|show|gig)\b` +
+		`|\b(?:entry|admission|tickets?)\s*(?:is\s+|are\s+|:\s*)?free\b`)
+)
+
+// pagePrice is what an event page says about ticket prices. Max is zero when
+// only a starting price is given.
+type pagePrice struct {
+	Min   float64
+	Max   float64
+	Free  bool
+	Found bool
+}
+
+// parsePrice reads the ticket prices in text
+func parsePrice(text string) pagePrice {
+	text = bookingFee.ReplaceAllString(strings.ToLower(text), " ")
+
+	var amounts []float64
+	for _, match := range priceAmount.FindAllStringSubmatch(text, -1) {
+		amount, err := strconv.ParseFloat(strings.ReplaceAll(match[1], ",", ""), 64)
+		if err == nil {
+			amounts = append(amounts, amount)
+		}
+	}
+	free := priceFree.MatchString(text)
+	if len(amounts) == 0 {
+		return pagePrice{Free: free, Found: free}
+	}
+
+	result := pagePrice{Min: amounts[0], Max: amounts[0], Found: true}
+	for _, amount := range amounts[1:] {
+		result.Min = min(result.Min, amount)
+		result.Max = max(result.Max, amount)
+	}
+	switch {
+	case free:
+		// free for some, e.g. "Free entry before 9pm, $10 after"
+		result.Min = 0
+	case len(amounts) == 1 && priceFrom.MatchString(text):
+		result.Max = 0
+	}
+	return result
+}
+
+// pagePriceText is the text of the price elements in content, the event's part
+// of the page, or failing those of its paragraphs that talk about tickets or
+// entry. Only when content has neither is the whole page searched, as other
+// shows' prices sit in sidebars and footers.
+func pagePriceText(doc *goquery.Document, content *goquery.Selection) string {
+	if text := priceElementsText(content); text != "" {
+		return text
+	}
+	var lines []string
+	content.Find("p, li").Each(func(i int, s *goquery.Selection) {
+		text := strings.ToLower(s.Text())
+		if strings.Contains(text, "ticket") || strings.Contains(text, "entry") || strings.Contains(text, "admission") {
+			lines = append(lines, s.Text())
+		}
+	})
+	if len(lines) > 0 {
+		return strings.Join(lines, "\n")
+	}
+	return priceElementsText(doc.Selection)
+}
+
+// priceElementsText joins the text of the innermost price and ticket elements in scope
+func priceElementsText(scope *goquery.Selection) string {
+	var lines []string
+	scope.Find("[class*='price'], [class*='ticket']").Not("a, html, body").Each(func(i int, s *goquery.Selection) {
+		if s.Find("[class*='price'], [class*='ticket']").Length() == 0 {
+			lines = append(lines, s.Text())
+		}
+	})
+	return strings.Join(lines, "\n")
+}
+
+// pageTicketURL is the first link in content, else in the article or main
+// element around it (the page when there is none), to an event on a ticketing
+// system common.TicketRef knows. Links to anything else, e.g. a ticketing FAQ,
+// are not ticket links, and sidebars selling other shows are left alone.
+func pageTicketURL(doc *goquery.Document, content *goquery.Selection, pageURL string) string {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return ""
+	}
+	around := content.Closest("article, main")
+	if around.Length() == 0 {
+		around = doc.Selection
+	}
+	for _, scope := range []*goquery.Selection{content, around} {
+		var result string
+		scope.Find("a[href]").EachWithBreak(func(i int, s *goquery.Selection) bool {
+			href, _ := s.Attr("href")
+			link, err := base.Parse(strings.TrimSpace(href))
+			if err == nil && common.TicketRef(link.String()) != "" {
+				result = link.String()
+			}
+			return result == ""
+		})
+		if result != "" {
+			return result
+		}
+	}
+	return ""
+}
+
+// setTickets fills the prices and ticket link of event from its page, content
+// being the event's part of it
+func setTickets(event *common.Event, doc *goquery.Document, content *goquery.Selection) {
+	price := parsePrice(pagePriceText(doc, content))
+	if price.Found {
+		event.PriceMin = price.Min
+		event.PriceMax = price.Max
+		event.Attributes.Free = price.Free && price.Max == 0
+	}
+	event.TicketURL = pageTicketURL(doc, content, event.URL)
+}
@@ -0,0 +1,43 @@
+package venuescrapers
+
+import "testing"
+
+func TestParsePrice(t *testing.T) {
+	tests := []struct {
+		text string
+		want pagePrice
+	}{
+		{"$35", pagePrice{Min: 35, Max: 35, Found: true}},
+		{"Tickets $35 + booking fee", pagePrice{Min: 35, Max: 35, Found: true}},
+		{"$35 + $4.50 booking fee", pagePrice{Min: 35, Max: 35, Found: true}},
+		{"$35 (+$3.95 BF)", pagePrice{Min: 35, Max: 35, Found: true}},
+		{"$49.90 inc. $4.90 booking fee", pagePrice{Min: 49.9, Max: 49.9, Found: true}},
+		{"Booking fee: $5. Tickets $30", pagePrice{Min: 30, Max: 30, Found: true}},
+		{"From $25", pagePrice{Min: 25, Found: true}},
+		{"from $25 to $60", pagePrice{Min: 25, Max: 60, Found: true}},
+		{"$40 / $35 conc", pagePrice{Min: 35, Max: 40, Found: true}},
+		{"GA $1,250.00, VIP $2,000", pagePrice{Min: 1250, Max: 2000, Found: true}},
+		{"FREE", pagePrice{Free: true, Found: true}},
+		{"Free!", pagePrice{Free: true, Found: true}},
+		{"Free entry", pagePrice{Free: true, Found: true}},
+		{"Entry is free, all welcome", pagePrice{Free: true, Found: true}},
+		{"Tickets: FREE", pagePrice{Free: true, Found: true}},
+		{"This is a free event", pagePrice{Free: true, Found: true}},
+		{"Free entry before 9pm, $10 after", pagePrice{Min: 0, Max: 10, Found: true}},
+
+		// "free" that is not the price
+		{"$25, includes a free drink on entry", pagePrice{Min: 25, Max: 25, Found: true}},
+		{"Gluten free options at the bar. $30", pagePrice{Min: 30, Max: 30, Found: true}},
+		{"Free parking behind the venue", pagePrice{}},
+		{"Feel free to bring a friend. Tickets $20", pagePrice{Min: 20, Max: 20, Found: true}},
+		{"Smoke free venue", pagePrice{}},
+
+		{"Tickets at the door", pagePrice{}},
+		{"", pagePrice{}},
+	}
+	for _, test := range tests {
+		if got := parsePrice(test.text); got != test.want {
+			t.Errorf("parsePrice(%q) = %+v, want %+v", test.text, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package venuescrapers
+
+import (
+	"common"
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Rule is one check an event must pass before it is stored
+type Rule struct {
+	Name  string
+	Check func(event common.Event, now time.Time) error
+}
+
+// ValidationError lists every rule an event broke, not just the first
+type ValidationError struct {
+	Failures []RuleFailure
+}
+
+type RuleFailure struct {
+	Rule   string
+	Reason string
+}
+
+func (obj ValidationError) Error() string {
+	return "invalid event: " + strings.Join(obj.Reasons(), "; ")
+}
+
+// Reasons describes each failure, for the quarantine
+func (obj ValidationError) Reasons() []string {
+	reasons := make([]string, len(obj.Failures))
+	for i, failure := range obj.Failures {
+		reasons[i] = failure.Rule + ": " + failure.Reason
+	}
+	return reasons
+}
+
+// City bounds where events are expected to take place
+type City struct {
+	Name     string
+	Center   common.Geo
+	RadiusKm float64
+}
+
+// Sydney reaches out to Newcastle, Wollongong and the Blue Mountains
+var Sydney = City{Name: "Sydney", Center: common.Geo{Lat: -33.8688, Lng: 151.2093}, RadiusKm: 150}
+
+const maxPlausiblePrice = 2000
+
+// DefaultRules reject what scrapers get wrong most: missing fields, past or
+// backwards dates, prices parsed from the wrong text, and venues in the wrong place.
+func DefaultRules(city City) []Rule {
+	return []Rule{
+		Required("source", func(event common.Event) bool { return event.Source_name != "" && event.SourceEvent != "" }),
+		Required("title", func(event common.Event) bool { return strings.TrimSpace(event.Title) != "" }),
+		Required("start", func(event common.Event) bool { return !event.Start.IsZero() }),
+		Required("venue", func(event common.Event) bool { return strings.TrimSpace(event.VenueName) != "" }),
+		EndsAfterStart(),
+		NotOver(),
+		PriceBetween(0, maxPlausiblePrice),
+		WithinCity(city),
+	}
+}
+
+func Required(field string, present func(event common.Event) bool) Rule {
+	return Rule{
+		Name: "required",
+		Check: func(event common.Event, now time.Time) error {
+			if !present(event) {
+				return fmt.Errorf("missing %s", field)
+			}
+			return nil
+		},
+	}
+}
+
+// EndsAfterStart rejects an end time before or equal to the start. Scrapers
+// that do not know when an event ends leave End unset.
+func EndsAfterStart() Rule {
+	return Rule{
+		Name: "ends-after-start",
+		Check: func(event common.Event, now time.Time) error {
+			if !event.End.IsZero() && !event.End.After(event.Start) {
+				return fmt.Errorf("ends %s, not after its start %s", event.End.Format(time.RFC3339), event.Start.Format(time.RFC3339))
+			}
+			return nil
+		},
+	}
+}
+
+// NotOver rejects events that have already finished, or started when their end is unknown
+func NotOver() Rule {
+	return Rule{
+		Name: "in-future",
+		Check: func(event common.Event, now time.Time) error {
+			last := event.End
+			if last.IsZero() {
+				last = event.Start
+			}
+			if !last.IsZero() && last.Before(now) {
+				return fmt.Errorf("already over on %s", last.Format(time.RFC3339))
+			}
+			return nil
+		},
+	}
+}
+
+func PriceBetween(min float64, max float64) Rule {
+	return Rule{
+		Name: "price",
+		Check: func(event common.Event, now time.Time) error {
+			switch {
+			case event.PriceMin < min || event.PriceMax < min:
+				return fmt.Errorf("negative price %.2f-%.2f", event.PriceMin, event.PriceMax)
+			case event.PriceMax > 0 && event.PriceMin > event.PriceMax:
+				return fmt.Errorf("minimum price %.2f above maximum %.2f", event.PriceMin, event.PriceMax)
+			case event.PriceMin > max || event.PriceMax > max:
+				return fmt.Errorf("price %.2f-%.2f above %.2f", event.PriceMin, event.PriceMax, max)
+			}
+			return nil
+		},
+	}
+}
+
+// WithinCity rejects coordinates too far from the city. Events without coordinates pass.
+func WithinCity(city City) Rule {
+	return Rule{
+		Name: "location",
+		Check: func(event common.Event, now time.Time) error {
+			if event.Geo == (common.Geo{}) {
+				return nil
+			}
+			if distance := distanceKm(city.Center, event.Geo); distance > city.RadiusKm {
+				return fmt.Errorf("%.0f km from %s", distance, city.Name)
+			}
+			return nil
+		},
+	}
+}
+
+// distanceKm is the great circle distance between a and b
+func distanceKm(a common.Geo, b common.Geo) float64 {
+	const earthRadiusKm = 6371
+	lat1, lat2 := a.Lat*math.Pi/180, b.Lat*math.Pi/180
+	dLat, dLng := lat2-lat1, (b.Lng-a.Lng)*math.Pi/180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(h))
+}
+
+type Validator struct {
+	rules []Rule
+	now   func() time.Time
+}
+
+func NewValidator(rules []Rule) Validator {
+	return Validator{rules: rules, now: time.Now}
+}
+
+// Validate returns a ValidationError listing every rule event breaks, or nil
+func (obj Validator) Validate(event common.Event) error {
+	now := obj.now()
+	var result ValidationError
+	for _, rule := range obj.rules {
+		if err := rule.Check(event, now); err != nil {
+			result.Failures = append(result.Failures, RuleFailure{Rule: rule.Name, Reason: err.Error()})
+		}
+	}
+	if len(result.Failures) > 0 {
+		return result
+	}
+	return nil
+}
+
+type validateStage struct {
+	validator Validator
+}
+
+func (validateStage) Name() string { return StageValidate }
+
+func (obj validateStage) Process(ctx context.Context, item *Item) error {
+	return obj.validator.Validate(item.Event)
+}
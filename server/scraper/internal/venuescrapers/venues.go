@@ -0,0 +1,78 @@
+package venuescrapers
+
+import (
+	"common"
+	"context"
+	"scraper/internal/geocode"
+	"strings"
+)
+
+// VenueDirectory knows where venues are, for events whose source leaves it out
+type VenueDirectory interface {
+	LookupVenue(name string) (common.Address, common.Geo, bool)
+}
+
+type KnownVenue struct {
+	Name    string
+	Address common.Address
+	Geo     common.Geo
+}
+
+// StaticVenues is a VenueDirectory over a fixed list, matched on case-insensitive name
+type StaticVenues map[string]KnownVenue
+
+func NewStaticVenues(venues []KnownVenue) StaticVenues {
+	result := StaticVenues{}
+	for _, venue := range venues {
+		result[venueKey(venue.Name)] = venue
+	}
+	return result
+}
+
+func (obj StaticVenues) LookupVenue(name string) (common.Address, common.Geo, bool) {
+	venue, ok := obj[venueKey(name)]
+	return venue.Address, venue.Geo, ok
+}
+
+func venueKey(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// DefaultVenues are the venues the HTML scrapers know about
+var DefaultVenues = []KnownVenue{
+	{
+		Name:    "Metro Theatre",
+		Address: common.Address{Line1: "624 George St", PostCode: "2000", Locality: "Sydney", Region: "NSW", Country: "Australia"},
+		Geo:     common.Geo{Lat: -33.87557496143779, Lng: 151.206671962522},
+	},
+	{
+		Name:    "Factory Theatre",
+		Address: common.Address{Line1: "105 Victoria Road", PostCode: "2204", Locality: "Marrickville", Region: "NSW", Country: "Australia"},
+		Geo:     common.Geo{Lat: -33.90574, Lng: 151.16553},
+	},
+}
+
+type venueStage struct {
+	venues VenueDirectory
+}
+
+func (venueStage) Name() string { return StageEnrichVenue }
+
+// Process fills in the address and location of known venues when the source gave none
+func (obj venueStage) Process(ctx context.Context, item *Item) error {
+	if obj.venues == nil || item.Event.VenueName == "" {
+		return nil
+	}
+	address, geo, ok := obj.venues.LookupVenue(item.Event.VenueName)
+	if !ok {
+		return nil
+	}
+	if item.Event.Address == (common.Address{}) {
+		item.Event.Address = address
+	}
+	if item.Event.Geo == (common.Geo{}) {
+		item.Event.Geo = geo
+		item.Event.GeoPrecision = string(geocode.PrecisionVenue)
+	}
+	return nil
+}
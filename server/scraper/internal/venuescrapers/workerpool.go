@@ -0,0 +1,69 @@
+package venuescrapers
+
+import (
+	"context"
+	"sync"
+)
+
+// forEach runs fn over items with at most workers goroutines in flight.
+// It stops handing out work once ctx is cancelled and returns ctx.Err() in that case.
+func forEach[T any](ctx context.Context, workers int, items []T, fn func(ctx context.Context, item T)) error {
+	if workers < 1 {
+		workers = 1
+	}
+
+	jobs := make(chan T)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range jobs {
+				fn(ctx, item)
+			}
+		}()
+	}
+
+feed:
+	for _, item := range items {
+		select {
+		case <-ctx.Done():
+			break feed
+		case jobs <- item:
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	return ctx.Err()
+}
+
+// forEachReceived is forEach over items arriving on a channel, until it is
+// closed or ctx is cancelled. Senders should give up on ctx.Done() as well.
+func forEachReceived[T any](ctx context.Context, workers int, items <-chan T, fn func(ctx context.Context, item T)) error {
+	if workers < 1 {
+		workers = 1
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case item, ok := <-items:
+					if !ok {
+						return
+					}
+					fn(ctx, item)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	return ctx.Err()
+}
@@ -1,40 +1,97 @@
 package http
 
 import (
+	"common"
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"spotify-auth-broker/internal/admin"
 	"spotify-auth-broker/internal/auth"
+	"spotify-auth-broker/internal/ingest"
 	"spotify-auth-broker/internal/service"
 	"spotify-auth-broker/internal/spotify"
 	"spotify-auth-broker/internal/store"
+	"spotify-auth-broker/internal/submit"
 	"spotify-auth-broker/internal/util"
 )
 
 type Router struct {
-	store   *store.DDB
-	service *service.Service
-	client  *spotify.Client
-	session *auth.Session
-	logger  zerolog.Logger
+	store       *store.DDB
+	service     *service.Service
+	client      *spotify.Client
+	session     *auth.Session
+	ingest      *ingest.Ingester   // nil when SCRAPER_QUEUE_URL is not set
+	admin       *admin.Editor      // nil when ADMIN_API_KEYS is not set
+	submissions *submit.Moderation // nil when ingestion is disabled
+	logger      zerolog.Logger
 }
 
 func NewRouter() *Router {
 	s := store.MustNew()
 	svc := service.NewService(os.Getenv("DYNAMODB_ENDPOINT"), os.Getenv("AWS_REGION"))
+	logger := log.Output(zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: time.RFC3339, NoColor: true})
 
-	return &Router{
+	router := &Router{
 		store:   s,
 		service: svc,
 		client:  spotify.NewClient(os.Getenv("SPOTIFY_CLIENT_ID"), os.Getenv("SPOTIFY_REDIRECT_URI")),
 		session: auth.NewSession(os.Getenv("APP_JWT_SECRET")),
-		logger:  log.Output(zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: time.RFC3339, NoColor: true}),
+		logger:  logger,
 	}
+	dbLayer, err := common.NewDb(os.Getenv("DYNAMODB_ENDPOINT"), os.Getenv("AWS_REGION"), logger)
+	if err != nil {
+		logger.Error().Msgf("Event ingestion, submissions and admin endpoints are disabled: %v", err)
+		return router
+	}
+	router.ingest = newIngester(dbLayer, logger)
+	router.admin = newEditor(dbLayer, logger)
+	if router.ingest != nil {
+		router.submissions = submit.NewModeration(dbLayer, router.ingest, logger)
+	}
+	return router
+}
+
+// newIngester sets up event ingestion, which queues pushed events for the scraper
+func newIngester(dbLayer common.Db, logger zerolog.Logger) *ingest.Ingester {
+	queueURL := os.Getenv("SCRAPER_QUEUE_URL")
+	if queueURL == "" {
+		logger.Warn().Msg("SCRAPER_QUEUE_URL is not set, event ingestion and submissions are disabled")
+		return nil
+	}
+	queue, err := ingest.NewSQSQueue(context.Background(), queueURL, os.Getenv("AWS_REGION"))
+	if err != nil {
+		logger.Error().Msgf("Event ingestion is disabled: %v", err)
+		return nil
+	}
+	ingester, err := ingest.NewIngester(dbLayer, queue, logger)
+	if err != nil {
+		logger.Error().Msgf("Event ingestion is disabled: %v", err)
+		return nil
+	}
+	return ingester
+}
+
+// newEditor sets up the admin endpoints, for editors with a key in ADMIN_API_KEYS
+func newEditor(dbLayer common.Db, logger zerolog.Logger) *admin.Editor {
+	keys := os.Getenv("ADMIN_API_KEYS")
+	if keys == "" {
+		logger.Warn().Msg("ADMIN_API_KEYS is not set, admin endpoints are disabled")
+		return nil
+	}
+	editor, err := admin.NewEditor(dbLayer, keys, logger)
+	if err != nil {
+		logger.Error().Msgf("Admin endpoints are disabled: %v", err)
+		return nil
+	}
+	return editor
 }
 
 func (r *Router) Serve(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
@@ -56,6 +113,18 @@ func (r *Router) Serve(ctx context.Context, req events.APIGatewayV2HTTPRequest)
 		return r.match(ctx, req)
 	case method == "GET" && path == "/api/spotify/liked":
 		return r.getLiked(ctx, req)
+	case method == "POST" && path == "/api/ingest/events":
+		return r.ingestEvents(ctx, req)
+	case method == "GET" && path == "/api/ingest/schema":
+		return r.ingestSchema(ctx, req)
+	case method == "POST" && path == "/api/submissions":
+		return r.submitEvent(ctx, req)
+	case method == "GET" && path == "/api/submissions":
+		return r.mySubmissions(ctx, req)
+	case strings.HasPrefix(path, adminEventsPath):
+		return r.adminEvents(ctx, req)
+	case path == "/api/admin/submissions" || strings.HasPrefix(path, adminSubmissionsPath):
+		return r.adminSubmissions(ctx, req)
 	default:
 		return util.JSON(404, util.M{"error": "not found"}), nil
 	}
@@ -206,6 +275,10 @@ func (r *Router) match(ctx context.Context, req events.APIGatewayV2HTTPRequest)
 		return util.JSON(500, nil), err
 	}
 
+	if err := matchingRequest.CheckFilters(); err != nil {
+		return util.JSON(400, util.M{"error": err.Error()}), nil
+	}
+
 	matchingRequest.EndDate = service.Date{Time: matchingRequest.StartDate.Add(3 * 24 * time.Hour)}
 
 	if matchingRequest.Category == "music" {
@@ -244,3 +317,247 @@ func (r *Router) match(ctx context.Context, req events.APIGatewayV2HTTPRequest)
 	//data, _ := json.Marshal(recommendedEvents)
 	return util.JSON(200, recommendedEvents), nil
 }
+
+// POST /api/ingest/events
+// One event or an array of them, see GET /api/ingest/schema. Valid events are
+// accepted even when others in the request are rejected.
+func (r *Router) ingestEvents(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	if r.ingest == nil {
+		return util.JSON(503, util.M{"error": "ingestion unavailable"}), nil
+	}
+	source, err := r.ingest.Authenticate(req.Headers)
+	if errors.Is(err, ingest.ErrUnauthorized) {
+		return util.JSON(401, util.M{"error": "unauthorized"}), nil
+	}
+	if err != nil {
+		r.logger.Error().Msg(err.Error())
+		return util.JSON(500, util.M{"error": "source lookup failed"}), nil
+	}
+
+	body := []byte(req.Body)
+	if req.IsBase64Encoded {
+		if body, err = base64.StdEncoding.DecodeString(req.Body); err != nil {
+			return util.JSON(400, util.M{"error": "invalid body", "detail": err.Error()}), nil
+		}
+	}
+	accepted, rejected, err := r.ingest.Parse(body)
+	if errors.Is(err, ingest.ErrTooMany) {
+		return util.JSON(413, util.M{"error": err.Error()}), nil
+	}
+	if err != nil {
+		return util.JSON(400, util.M{"error": "invalid body", "detail": err.Error()}), nil
+	}
+	if len(accepted) == 0 {
+		return util.JSON(400, util.M{"error": "no valid events", "rejected": rejected}), nil
+	}
+
+	if err := r.ingest.Submit(ctx, source, accepted); err != nil {
+		r.logger.Error().Msgf("Queueing events of source %s failed: %v", source.SourceID, err)
+		return util.JSON(502, util.M{"error": "queue failed"}), nil
+	}
+	r.logger.Info().Msgf("Source %s: %d events accepted, %d rejected", source.SourceID, len(accepted), len(rejected))
+	return util.JSON(202, util.M{"accepted": len(accepted), "rejected": rejected}), nil
+}
+
+// GET /api/ingest/schema
+func (r *Router) ingestSchema(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	if r.ingest == nil {
+		return util.JSON(503, util.M{"error": "ingestion unavailable"}), nil
+	}
+	return util.JSON(200, r.ingest.Schema()), nil
+}
+
+const adminEventsPath = "/api/admin/events/"
+
+// GET /api/admin/events/{id}
+// PUT /api/admin/events/{id}/overrides, body {"<field>": <value>, ...}
+// DELETE /api/admin/events/{id}/overrides/{field}
+// Overrides keep editorial values of event fields over what sources say. The
+// response is the event with its overrides.
+func (r *Router) adminEvents(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	author, denied, ok := r.requireEditor(req)
+	if !ok {
+		return denied, nil
+	}
+
+	var err error
+	parts := strings.Split(strings.TrimPrefix(req.RawPath, adminEventsPath), "/")
+	method := req.RequestContext.HTTP.Method
+	var event common.Event
+	switch {
+	case len(parts) == 1 && parts[0] != "" && method == "GET":
+		event, err = r.admin.Event(parts[0])
+	case len(parts) == 2 && parts[1] == "overrides" && method == "PUT":
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(req.Body), &fields); err != nil {
+			return util.JSON(400, util.M{"error": "invalid body", "detail": err.Error()}), nil
+		}
+		event, err = r.admin.SetOverrides(parts[0], author, fields)
+	case len(parts) == 3 && parts[1] == "overrides" && method == "DELETE":
+		event, err = r.admin.ClearOverride(parts[0], author, parts[2])
+	default:
+		return util.JSON(404, util.M{"error": "not found"}), nil
+	}
+
+	switch {
+	case errors.Is(err, admin.ErrNotFound):
+		return util.JSON(404, util.M{"error": err.Error()}), nil
+	case errors.Is(err, admin.ErrInvalid):
+		return util.JSON(400, util.M{"error": err.Error()}), nil
+	case err != nil:
+		r.logger.Error().Msg(err.Error())
+		return util.JSON(500, util.M{"error": "update failed"}), nil
+	}
+	return util.JSON(200, event), nil
+}
+
+// requireEditor authenticates an admin request, returning the response to send when it fails
+func (r *Router) requireEditor(req events.APIGatewayV2HTTPRequest) (string, events.APIGatewayV2HTTPResponse, bool) {
+	if r.admin == nil {
+		return "", util.JSON(503, util.M{"error": "admin unavailable"}), false
+	}
+	author, err := r.admin.Authenticate(req.Headers)
+	if err != nil {
+		return "", util.JSON(401, util.M{"error": "unauthorized"}), false
+	}
+	return author, events.APIGatewayV2HTTPResponse{}, true
+}
+
+// requireSubmitter returns the Spotify account linked to the session, as the
+// submitter ID. Sessions are handed out to anyone, so submissions are made,
+// counted and blocked by account rather than by session.
+func (r *Router) requireSubmitter(ctx context.Context, req events.APIGatewayV2HTTPRequest) (string, events.APIGatewayV2HTTPResponse, bool) {
+	userID, ok := r.session.Require(req.Cookies)
+	if !ok {
+		return "", util.JSON(401, util.M{"error": "unauthorized"}), false
+	}
+	link, err := r.store.GetLink(ctx, userID)
+	if err != nil {
+		return "", util.JSON(403, util.M{"error": "link a Spotify account to submit events"}), false
+	}
+	access, err := r.client.EnsureAccessToken(ctx, link.RefreshToken)
+	if err != nil {
+		return "", util.JSON(502, util.M{"error": "refresh failed", "detail": err.Error()}), false
+	}
+	profile, err := r.client.GetMe(ctx, access)
+	if err != nil {
+		return "", util.JSON(502, util.M{"error": "spotify error", "detail": err.Error()}), false
+	}
+	return "spotify-" + profile.ID, events.APIGatewayV2HTTPResponse{}, true
+}
+
+// POST /api/submissions
+// An event in the ingestion schema, without source_event, for editors to
+// review. Only users with a linked Spotify account can submit.
+func (r *Router) submitEvent(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	if r.submissions == nil {
+		return util.JSON(503, util.M{"error": "submissions unavailable"}), nil
+	}
+	userID, denied, ok := r.requireSubmitter(ctx, req)
+	if !ok {
+		return denied, nil
+	}
+	submission, err := r.submissions.Submit(userID, []byte(req.Body))
+	if err != nil {
+		return submissionError(err, r.logger), nil
+	}
+	return util.JSON(201, submission), nil
+}
+
+// GET /api/submissions
+func (r *Router) mySubmissions(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	if r.submissions == nil {
+		return util.JSON(503, util.M{"error": "submissions unavailable"}), nil
+	}
+	userID, denied, ok := r.requireSubmitter(ctx, req)
+	if !ok {
+		return denied, nil
+	}
+	submissions, err := r.submissions.Mine(userID)
+	if err != nil {
+		return submissionError(err, r.logger), nil
+	}
+	return util.JSON(200, submissions), nil
+}
+
+const adminSubmissionsPath = "/api/admin/submissions/"
+
+// GET /api/admin/submissions?status=pending|approved|rejected (default pending, "all" for every one)
+// PUT /api/admin/submissions/{id}, body the event fields to change
+// POST /api/admin/submissions/{id}/approve
+// POST /api/admin/submissions/{id}/reject, body {"reason": "..."}
+func (r *Router) adminSubmissions(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	editor, denied, ok := r.requireEditor(req)
+	if !ok {
+		return denied, nil
+	}
+	if r.submissions == nil {
+		return util.JSON(503, util.M{"error": "submissions unavailable"}), nil
+	}
+
+	method := req.RequestContext.HTTP.Method
+	if req.RawPath == "/api/admin/submissions" {
+		if method != "GET" {
+			return util.JSON(404, util.M{"error": "not found"}), nil
+		}
+		status := common.SubmissionStatus(req.QueryStringParameters["status"])
+		switch status {
+		case "":
+			status = common.SubmissionPending
+		case "all":
+			status = ""
+		case common.SubmissionPending, common.SubmissionApproved, common.SubmissionRejected:
+		default:
+			return util.JSON(400, util.M{"error": "invalid status"}), nil
+		}
+		submissions, err := r.submissions.List(status)
+		if err != nil {
+			return submissionError(err, r.logger), nil
+		}
+		return util.JSON(200, submissions), nil
+	}
+
+	parts := strings.Split(strings.TrimPrefix(req.RawPath, adminSubmissionsPath), "/")
+	var submission common.Submission
+	var err error
+	switch {
+	case len(parts) == 1 && parts[0] != "" && method == "PUT":
+		submission, err = r.submissions.Edit(parts[0], editor, []byte(req.Body))
+	case len(parts) == 2 && parts[1] == "approve" && method == "POST":
+		submission, err = r.submissions.Approve(ctx, parts[0], editor)
+	case len(parts) == 2 && parts[1] == "reject" && method == "POST":
+		var body struct {
+			Reason string `json:"reason"`
+		}
+		if req.Body != "" {
+			if err := json.Unmarshal([]byte(req.Body), &body); err != nil {
+				return util.JSON(400, util.M{"error": "invalid body", "detail": err.Error()}), nil
+			}
+		}
+		submission, err = r.submissions.Reject(parts[0], editor, body.Reason)
+	default:
+		return util.JSON(404, util.M{"error": "not found"}), nil
+	}
+	if err != nil {
+		return submissionError(err, r.logger), nil
+	}
+	return util.JSON(200, submission), nil
+}
+
+func submissionError(err error, logger zerolog.Logger) events.APIGatewayV2HTTPResponse {
+	var invalid submit.InvalidError
+	switch {
+	case errors.As(err, &invalid):
+		return util.JSON(400, util.M{"error": "invalid event", "errors": invalid.Errors})
+	case errors.Is(err, submit.ErrNotFound):
+		return util.JSON(404, util.M{"error": err.Error()})
+	case errors.Is(err, submit.ErrNotPending):
+		return util.JSON(409, util.M{"error": err.Error()})
+	case errors.Is(err, submit.ErrTooMany):
+		return util.JSON(429, util.M{"error": err.Error()})
+	case errors.Is(err, submit.ErrBlocked):
+		return util.JSON(403, util.M{"error": err.Error()})
+	}
+	logger.Error().Msg(err.Error())
+	return util.JSON(500, util.M{"error": "submission failed"})
+}
@@ -0,0 +1,261 @@
+package ingest
+
+import (
+	"bytes"
+	"common"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/rs/zerolog"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"strings"
+)
+
+// Promoters and venues push events for a push source, authenticated by the API
+// key issued for it ("scraper sources key"). Events are checked against the
+// published schema here, then queued for the scraper, which runs them through
+// the same pipeline as scraped events.
+
+var (
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrTooMany      = fmt.Errorf("more than %d events in one request", MaxEvents)
+	ErrNoEvents     = errors.New("no events in request")
+)
+
+// MaxEvents is the most events accepted in one request
+const MaxEvents = 500
+
+// maxMessageBytes keeps queued messages well under the SQS limit of 256 KiB.
+// Events that do not fit in a message of their own are rejected.
+const maxMessageBytes = 200 * 1024
+
+const schemaURL = "https://gigsnearme/schemas/ingest-event.json"
+
+// Queue takes the ingest commands for the scraper
+type Queue interface {
+	Send(ctx context.Context, message common.IngestMessage) error
+}
+
+type SQSQueue struct {
+	client *sqs.Client
+	url    string
+}
+
+func NewSQSQueue(ctx context.Context, url string, region string) (SQSQueue, error) {
+	cfg, err := config.LoadDefaultConfig(ctx, func(o *config.LoadOptions) error {
+		if region != "" {
+			o.Region = region
+		}
+		return nil
+	})
+	if err != nil {
+		return SQSQueue{}, fmt.Errorf("failed loading AWS config: %w", err)
+	}
+	return SQSQueue{client: sqs.NewFromConfig(cfg), url: url}, nil
+}
+
+func (obj SQSQueue) Send(ctx context.Context, message common.IngestMessage) error {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	_, err = obj.client.SendMessage(ctx, &sqs.SendMessageInput{QueueUrl: aws.String(obj.url), MessageBody: aws.String(string(body))})
+	return err
+}
+
+// Sources looks up the source an API key was issued for
+type Sources interface {
+	QuerySourceBySourceID(sourceID string) (*common.Source, error)
+}
+
+// Rejection is an event of a request that failed validation
+type Rejection struct {
+	Index  int      `json:"index"`
+	Errors []string `json:"errors"`
+}
+
+type Ingester struct {
+	sources Sources
+	queue   Queue
+	schema  *jsonschema.Schema
+	raw     []byte
+	logger  zerolog.Logger
+}
+
+func NewIngester(sources Sources, queue Queue, logger zerolog.Logger) (*Ingester, error) {
+	raw, err := json.Marshal(common.GenerateSchema[common.IngestEvent]())
+	if err != nil {
+		return nil, err
+	}
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	compiler := jsonschema.NewCompiler()
+	compiler.AssertFormat()
+	if err := compiler.AddResource(schemaURL, doc); err != nil {
+		return nil, err
+	}
+	schema, err := compiler.Compile(schemaURL)
+	if err != nil {
+		return nil, err
+	}
+	return &Ingester{
+		sources: sources,
+		queue:   queue,
+		schema:  schema,
+		raw:     raw,
+		logger:  logger,
+	}, nil
+}
+
+// Schema returns the published JSON schema of one event
+func (obj *Ingester) Schema() json.RawMessage {
+	return obj.raw
+}
+
+// Authenticate returns the active push source the key in headers was issued
+// for. The key goes in "Authorization: Bearer <key>" or "X-API-Key: <key>".
+func (obj *Ingester) Authenticate(headers map[string]string) (common.Source, error) {
+	var bearer, apiKey string
+	for name, value := range headers {
+		switch strings.ToLower(name) {
+		case "authorization":
+			if token, ok := strings.CutPrefix(value, "Bearer "); ok {
+				bearer = strings.TrimSpace(token)
+			}
+		case "x-api-key":
+			apiKey = strings.TrimSpace(value)
+		}
+	}
+	key := bearer
+	if key == "" {
+		key = apiKey
+	}
+	sourceID := common.APIKeySourceID(key)
+	if sourceID == "" {
+		return common.Source{}, ErrUnauthorized
+	}
+
+	source, err := obj.sources.QuerySourceBySourceID(sourceID)
+	if err != nil {
+		return common.Source{}, err
+	}
+	if source == nil || source.SourceType != common.Push || !source.Active || !common.APIKeyMatches(*source, key) {
+		obj.logger.Warn().Msgf("Rejected API key for source %s", sourceID)
+		return common.Source{}, ErrUnauthorized
+	}
+	return *source, nil
+}
+
+// Parse reads one event, or an array of events, and validates each against the
+// schema. Invalid events are returned as rejections, by position in the request.
+func (obj *Ingester) Parse(body []byte) ([]common.IngestEvent, []Rejection, error) {
+	body = bytes.TrimSpace(body)
+	var items []json.RawMessage
+	switch {
+	case len(body) == 0:
+		return nil, nil, ErrNoEvents
+	case body[0] == '[':
+		if err := json.Unmarshal(body, &items); err != nil {
+			return nil, nil, err
+		}
+	default:
+		items = []json.RawMessage{body}
+	}
+	if len(items) == 0 {
+		return nil, nil, ErrNoEvents
+	}
+	if len(items) > MaxEvents {
+		return nil, nil, ErrTooMany
+	}
+
+	var accepted []common.IngestEvent
+	var rejected []Rejection
+	for i, item := range items {
+		event, err := obj.validate(item)
+		if err != nil {
+			rejected = append(rejected, Rejection{Index: i, Errors: validationErrors(err)})
+			continue
+		}
+		if data, err := json.Marshal(event); err != nil || len(data) > maxMessageBytes {
+			rejected = append(rejected, Rejection{Index: i, Errors: []string{fmt.Sprintf("/: event is larger than %d bytes", maxMessageBytes)}})
+			continue
+		}
+		accepted = append(accepted, event)
+	}
+	return accepted, rejected, nil
+}
+
+func (obj *Ingester) validate(item json.RawMessage) (common.IngestEvent, error) {
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(item))
+	if err != nil {
+		return common.IngestEvent{}, err
+	}
+	if err := obj.schema.Validate(doc); err != nil {
+		return common.IngestEvent{}, err
+	}
+	var event common.IngestEvent
+	if err := json.Unmarshal(item, &event); err != nil {
+		return common.IngestEvent{}, err
+	}
+	return event, event.Check()
+}
+
+// validationErrors flattens a schema validation error into one line per failed keyword
+func validationErrors(err error) []string {
+	var invalid *jsonschema.ValidationError
+	if !errors.As(err, &invalid) {
+		return []string{err.Error()}
+	}
+	var result []string
+	var walk func(unit jsonschema.OutputUnit)
+	walk = func(unit jsonschema.OutputUnit) {
+		if unit.Error != nil && len(unit.Errors) == 0 {
+			location := unit.InstanceLocation
+			if location == "" {
+				location = "/"
+			}
+			result = append(result, location+": "+unit.Error.String())
+		}
+		for _, child := range unit.Errors {
+			walk(child)
+		}
+	}
+	walk(*invalid.BasicOutput())
+	if len(result) == 0 {
+		result = []string{err.Error()}
+	}
+	return result
+}
+
+// Submit queues events for the scraper, split so each message stays under the SQS size limit
+func (obj *Ingester) Submit(ctx context.Context, source common.Source, events []common.IngestEvent) error {
+	message := common.IngestMessage{Name: common.IngestCommand, Venue: source.SourceID}
+	size := 0
+	for _, event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		if len(message.Events) > 0 && size+len(data) > maxMessageBytes {
+			if err := obj.queue.Send(ctx, message); err != nil {
+				return err
+			}
+			message.Events, size = nil, 0
+		}
+		message.Events = append(message.Events, event)
+		size += len(data)
+	}
+	if len(message.Events) > 0 {
+		if err := obj.queue.Send(ctx, message); err != nil {
+			return err
+		}
+	}
+	obj.logger.Info().Msgf("Queued %d events for source %s", len(events), source.SourceID)
+	return nil
+}
@@ -0,0 +1,193 @@
+package ingest
+
+import (
+	"common"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/rs/zerolog"
+	"strings"
+	"testing"
+)
+
+type sourcesStub map[string]common.Source
+
+func (obj sourcesStub) QuerySourceBySourceID(sourceID string) (*common.Source, error) {
+	if source, ok := obj[sourceID]; ok {
+		return &source, nil
+	}
+	return nil, nil
+}
+
+type queueStub struct {
+	messages []common.IngestMessage
+}
+
+func (obj *queueStub) Send(ctx context.Context, message common.IngestMessage) error {
+	obj.messages = append(obj.messages, message)
+	return nil
+}
+
+func newTestIngester(t *testing.T, sources sourcesStub) (*Ingester, *queueStub) {
+	queue := &queueStub{}
+	ingester, err := NewIngester(sources, queue, zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ingester, queue
+}
+
+// issue adds a source of sourceType to sources and returns its API key
+func issue(t *testing.T, sources sourcesStub, sourceID string, sourceType common.SourceType, active bool) string {
+	key, hash, err := common.NewAPIKey(sourceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sources[sourceID] = common.Source{SourceID: sourceID, SourceType: sourceType, Active: active, APIKeyHash: hash}
+	return key
+}
+
+func TestAuthenticate(t *testing.T) {
+	sources := sourcesStub{}
+	key := issue(t, sources, "promoter", common.Push, true)
+	inactiveKey := issue(t, sources, "retired", common.Push, false)
+	userKey := issue(t, sources, "user-1", common.UserSource, true)
+	otherKey, _, _ := common.NewAPIKey("promoter")
+	ingester, _ := newTestIngester(t, sources)
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    string
+	}{
+		{"bearer", map[string]string{"Authorization": "Bearer " + key}, "promoter"},
+		{"api key header", map[string]string{"X-API-Key": key}, "promoter"},
+		{"header names in any case", map[string]string{"x-api-key": " " + key + " "}, "promoter"},
+		{"bearer first", map[string]string{"authorization": "Bearer " + key, "x-api-key": otherKey}, "promoter"},
+		{"not a bearer token", map[string]string{"Authorization": "Basic " + key}, ""},
+		{"no key", map[string]string{}, ""},
+		{"malformed key", map[string]string{"X-API-Key": "promoter"}, ""},
+		{"wrong key", map[string]string{"X-API-Key": otherKey}, ""},
+		{"unknown source", map[string]string{"X-API-Key": "nobody.c2VjcmV0"}, ""},
+		{"inactive source", map[string]string{"X-API-Key": inactiveKey}, ""},
+		{"not a push source", map[string]string{"X-API-Key": userKey}, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			source, err := ingester.Authenticate(test.headers)
+			if test.want == "" {
+				if !errors.Is(err, ErrUnauthorized) {
+					t.Errorf("got source %q, error %v, want unauthorized", source.SourceID, err)
+				}
+				return
+			}
+			if err != nil || source.SourceID != test.want {
+				t.Errorf("got source %q, error %v, want %s", source.SourceID, err, test.want)
+			}
+		})
+	}
+}
+
+func eventJSON(sourceEvent string, description string) string {
+	data, _ := json.Marshal(map[string]any{
+		"source_event": sourceEvent,
+		"title":        "Show " + sourceEvent,
+		"start":        "2030-05-01T20:00:00+10:00",
+		"description":  description,
+	})
+	return string(data)
+}
+
+func TestParse(t *testing.T) {
+	ingester, _ := newTestIngester(t, sourcesStub{})
+
+	accepted, rejected, err := ingester.Parse([]byte(eventJSON("a", "")))
+	if err != nil || len(accepted) != 1 || len(rejected) != 0 {
+		t.Errorf("single event: accepted %d, rejected %v, error %v", len(accepted), rejected, err)
+	}
+
+	body := "[" + strings.Join([]string{
+		eventJSON("a", ""),
+		`{"source_event": "b", "start": "2030-05-01T20:00:00Z"}`,
+		eventJSON("c", ""),
+		`{"source_event": "d", "title": "Show d", "start": "next friday"}`,
+		eventJSON("e", strings.Repeat("x", maxMessageBytes)),
+	}, ",") + "]"
+	accepted, rejected, err = ingester.Parse([]byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, event := range accepted {
+		ids = append(ids, event.SourceEvent)
+	}
+	if strings.Join(ids, ",") != "a,c" {
+		t.Errorf("accepted %v, want a and c", ids)
+	}
+	var indexes []int
+	for _, rejection := range rejected {
+		if len(rejection.Errors) == 0 {
+			t.Errorf("rejection %d has no errors", rejection.Index)
+		}
+		indexes = append(indexes, rejection.Index)
+	}
+	if fmt.Sprint(indexes) != "[1 3 4]" {
+		t.Errorf("rejected %v, want indexes 1, 3 and 4", indexes)
+	}
+
+	for name, body := range map[string]string{"empty": " ", "empty array": "[]"} {
+		if _, _, err := ingester.Parse([]byte(body)); !errors.Is(err, ErrNoEvents) {
+			t.Errorf("%s: error %v, want no events", name, err)
+		}
+	}
+	if _, _, err := ingester.Parse([]byte("[{")); err == nil {
+		t.Error("malformed body parsed")
+	}
+}
+
+func TestParseMaxEvents(t *testing.T) {
+	ingester, _ := newTestIngester(t, sourcesStub{})
+	items := make([]string, MaxEvents+1)
+	for i := range items {
+		items[i] = eventJSON(fmt.Sprint(i), "")
+	}
+
+	accepted, _, err := ingester.Parse([]byte("[" + strings.Join(items[:MaxEvents], ",") + "]"))
+	if err != nil || len(accepted) != MaxEvents {
+		t.Errorf("%d events: accepted %d, error %v", MaxEvents, len(accepted), err)
+	}
+	if _, _, err := ingester.Parse([]byte("[" + strings.Join(items, ",") + "]")); !errors.Is(err, ErrTooMany) {
+		t.Errorf("%d events: error %v, want too many", MaxEvents+1, err)
+	}
+}
+
+func TestSubmitSplitsMessages(t *testing.T) {
+	ingester, queue := newTestIngester(t, sourcesStub{})
+	// three events fit in a message, not four
+	description := strings.Repeat("x", maxMessageBytes/4)
+	var events []common.IngestEvent
+	for i := range 7 {
+		events = append(events, common.IngestEvent{SourceEvent: fmt.Sprint(i), Title: "Show", Description: description})
+	}
+
+	if err := ingester.Submit(context.Background(), common.Source{SourceID: "promoter"}, events); err != nil {
+		t.Fatal(err)
+	}
+	var sizes []int
+	total := 0
+	for _, message := range queue.messages {
+		if message.Name != common.IngestCommand || message.Venue != "promoter" {
+			t.Errorf("message %s for %s, want %s for promoter", message.Name, message.Venue, common.IngestCommand)
+		}
+		data, _ := json.Marshal(message)
+		if len(data) > maxMessageBytes+1024 {
+			t.Errorf("message of %d bytes", len(data))
+		}
+		sizes = append(sizes, len(message.Events))
+		total += len(message.Events)
+	}
+	if fmt.Sprint(sizes) != "[3 3 1]" || total != len(events) {
+		t.Errorf("messages of %v events, want [3 3 1]", sizes)
+	}
+}
@@ -45,6 +45,28 @@ type MatchingRequest struct {
 	Description string   `json:"description"`
 	Venues      []string `json:"venues"`
 	Artists     []string `json:"artists"`
+	Attributes  []string `json:"attributes"` // required features, see common.AttributeNames
+	Seating     string   `json:"seating"`    // seated, standing or mixed; any when empty
+}
+
+// CheckFilters reports attribute and seating filters that do not exist
+func (obj MatchingRequest) CheckFilters() error {
+	for _, name := range obj.Attributes {
+		if _, err := (common.Attributes{}).Has(name); err != nil {
+			return err
+		}
+	}
+	return common.CheckSeating(obj.Seating)
+}
+
+// matches reports whether event has every attribute and the seating asked for
+func (obj MatchingRequest) matches(event common.Event) bool {
+	for _, name := range obj.Attributes {
+		if has, _ := event.Attributes.Has(name); !has {
+			return false
+		}
+	}
+	return obj.Seating == "" || event.Attributes.Seating == obj.Seating
 }
 
 type RecommendedEvent struct {
@@ -63,6 +85,7 @@ type RecommendedEvent struct {
 	Images       []string            // list of strings
 	Categories   []string            // list of strings
 	ContentFlags common.ContentFlags // map of booleans
+	Attributes   common.Attributes
 }
 
 type Service struct {
@@ -89,6 +112,7 @@ func (s Service) ConvertEvent(event common.Event) RecommendedEvent {
 		Images:       event.Images,
 		Categories:   event.Categories,
 		ContentFlags: event.ContentFlags,
+		Attributes:   event.Attributes,
 	}
 }
 
@@ -105,12 +129,14 @@ func NewService(dynamoURL string, region string) *Service {
 }
 
 func (s Service) MatchEvents(request MatchingRequest) ([]RecommendedEvent, error) {
-	s.logger.Debug().Msgf("Matching events from %s to %s, category: %s, searchString: %s, venues: %v\n",
+	s.logger.Debug().Msgf("Matching events from %s to %s, category: %s, searchString: %s, venues: %v, attributes: %v, seating: %s\n",
 		request.StartDate.Format("2006-01-02"),
 		request.EndDate.Format("2006-01-02"),
 		request.Category,
 		request.Description,
-		request.Venues)
+		request.Venues,
+		request.Attributes,
+		request.Seating)
 
 	events, err := s.dbLayer.QueryEventsByCategoryAndDate(request.StartDate.Time, request.EndDate.Time, request.Category)
 
@@ -119,6 +145,14 @@ func (s Service) MatchEvents(request MatchingRequest) ([]RecommendedEvent, error
 		return nil, err
 	}
 
+	filtered := events[:0]
+	for _, event := range linkEvents(events) {
+		if request.matches(event) {
+			filtered = append(filtered, event)
+		}
+	}
+	events = filtered
+
 	s.logger.Debug().Msgf("Found %d events to match against", len(events))
 	eventsRecommendedByMatcher, err := s.matcher.Match(events, request.Description, request.Venues, request.Artists)
 	if err != nil {
@@ -133,3 +167,36 @@ func (s Service) MatchEvents(request MatchingRequest) ([]RecommendedEvent, error
 
 	return result, nil
 }
+
+// linkEvents drops the events linked to another source's listing of the same
+// show when that listing is among events, and gives the listing the prices and
+// ticket link it lacks from them. A linked event whose listing is missing, e.g.
+// as it is in another category, is kept in its place.
+func linkEvents(events []common.Event) []common.Event {
+	listings := map[string]int{}
+	for i, event := range events {
+		listings[event.EventID] = i
+	}
+	dropped := make([]bool, len(events))
+	for j, event := range events {
+		i, ok := listings[event.LinkedTo]
+		if event.LinkedTo == "" || !ok {
+			continue
+		}
+		dropped[j] = true
+		listing := &events[i]
+		if listing.PriceMin == 0 && listing.PriceMax == 0 && !listing.Attributes.Free {
+			listing.PriceMin, listing.PriceMax = event.PriceMin, event.PriceMax
+		}
+		if listing.TicketURL == "" {
+			listing.TicketURL = event.TicketURL
+		}
+	}
+	result := make([]common.Event, 0, len(events))
+	for j, event := range events {
+		if !dropped[j] {
+			result = append(result, event)
+		}
+	}
+	return result
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"common"
+	"testing"
+)
+
+func TestLinkEvents(t *testing.T) {
+	venue := common.Event{EventID: "venue", Title: "Show at the venue"}
+	free := common.Event{EventID: "free", Title: "Free show", Attributes: common.Attributes{Free: true}}
+	priced := common.Event{EventID: "priced", Title: "Priced show", PriceMin: 20, PriceMax: 20, TicketURL: "https://venue.example/tickets"}
+	events := []common.Event{
+		{EventID: "m1", LinkedTo: "venue", PriceMin: 35, PriceMax: 45, TicketURL: "https://www.moshtix.com.au/v2/event/show/1"},
+		venue,
+		free,
+		{EventID: "m2", LinkedTo: "free", PriceMin: 10, PriceMax: 10, TicketURL: "https://www.moshtix.com.au/v2/event/free/2"},
+		priced,
+		{EventID: "m3", LinkedTo: "priced", PriceMin: 25, PriceMax: 30, TicketURL: "https://www.moshtix.com.au/v2/event/priced/3"},
+		// its listing is in another category or outside the dates
+		{EventID: "m4", LinkedTo: "elsewhere", Title: "Show listed elsewhere", PriceMin: 15, PriceMax: 15},
+	}
+
+	result := linkEvents(events)
+	byID := map[string]common.Event{}
+	var ids []string
+	for _, event := range result {
+		byID[event.EventID] = event
+		ids = append(ids, event.EventID)
+	}
+	if len(result) != 4 {
+		t.Fatalf("kept %v, want venue, free, priced and m4", ids)
+	}
+	for _, id := range []string{"m1", "m2", "m3"} {
+		if _, ok := byID[id]; ok {
+			t.Errorf("kept %s, whose listing is among the events", id)
+		}
+	}
+
+	if got := byID["venue"]; got.PriceMin != 35 || got.PriceMax != 45 || got.TicketURL != "https://www.moshtix.com.au/v2/event/show/1" {
+		t.Errorf("venue has prices %v-%v and ticket link %q, want those of m1", got.PriceMin, got.PriceMax, got.TicketURL)
+	}
+	if got := byID["free"]; got.PriceMin != 0 || got.PriceMax != 0 || got.TicketURL != "https://www.moshtix.com.au/v2/event/free/2" {
+		t.Errorf("free show has prices %v-%v and ticket link %q, want no prices and the link of m2", got.PriceMin, got.PriceMax, got.TicketURL)
+	}
+	if got := byID["priced"]; got.PriceMin != 20 || got.TicketURL != "https://venue.example/tickets" {
+		t.Errorf("priced show has price %v and ticket link %q, want its own", got.PriceMin, got.TicketURL)
+	}
+	if got, ok := byID["m4"]; !ok || got.PriceMin != 15 {
+		t.Errorf("m4, whose listing is missing, was dropped or changed: %+v", got)
+	}
+}
@@ -270,6 +270,36 @@ func (c *Client) GetTopArtists(ctx context.Context, access string, tr TimeRange,
 	return &out, nil
 }
 
+// UserProfile is the part of the Spotify profile we use
+type UserProfile struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"display_name"`
+}
+
+// GetMe fetches the profile of the user the access token was issued for
+func (c *Client) GetMe(ctx context.Context, access string) (*UserProfile, error) {
+	req, _ := http.NewRequestWithContext(ctx, "GET", apiBase+"/me", nil)
+	req.Header.Set("Authorization", "Bearer "+access)
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		b, _ := io.ReadAll(res.Body)
+		return nil, errors.New("spotify api error: " + strconv.Itoa(res.StatusCode) + " " + string(b))
+	}
+	var out UserProfile
+	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	if out.ID == "" {
+		return nil, errors.New("spotify api error: profile without an id")
+	}
+	return &out, nil
+}
+
 // helpers
 func stringsReader(s string) *stringsReaderType { return &stringsReaderType{str: s, i: 0} }
 
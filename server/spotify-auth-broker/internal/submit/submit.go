@@ -0,0 +1,247 @@
+package submit
+
+import (
+	"common"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"spotify-auth-broker/internal/ingest"
+	"strings"
+	"time"
+)
+
+// Users with a linked Spotify account suggest events we do not scrape. They
+// submit as the account, not the session, as anyone can get a session.
+// Submissions wait as pending until an editor approves, edits or rejects them.
+// Approved ones are queued for the scraper like pushed events, as events of the
+// submitter's user source.
+
+var (
+	ErrNotFound   = errors.New("submission not found")
+	ErrNotPending = errors.New("submission is no longer pending")
+	ErrTooMany    = fmt.Errorf("more than %d submissions waiting for review", MaxPending)
+	ErrBlocked    = errors.New("submissions of this user are blocked")
+)
+
+// MaxPending is the most submissions a user can have waiting for review
+const MaxPending = 20
+
+// InvalidError lists what is wrong with a submitted event
+type InvalidError struct {
+	Errors []string
+}
+
+func (obj InvalidError) Error() string {
+	return "invalid event: " + strings.Join(obj.Errors, "; ")
+}
+
+// Store keeps submissions and the user sources approved ones are recorded under
+type Store interface {
+	WriteSubmission(submission common.Submission) error
+	UpdateSubmission(submission common.Submission, from common.SubmissionStatus) error
+	QuerySubmission(submissionID string) (*common.Submission, error)
+	QuerySubmissions(status common.SubmissionStatus, userID string) ([]common.Submission, error)
+	QuerySourceBySourceID(sourceID string) (*common.Source, error)
+	WriteSource(source common.Source) error
+}
+
+type Moderation struct {
+	store    Store
+	ingester *ingest.Ingester
+	logger   zerolog.Logger
+}
+
+func NewModeration(store Store, ingester *ingest.Ingester, logger zerolog.Logger) *Moderation {
+	return &Moderation{
+		store:    store,
+		ingester: ingester,
+		logger:   logger,
+	}
+}
+
+// Submit stores an event suggested by userID, as JSON in the ingestion schema
+// without a source_event, for review
+func (obj *Moderation) Submit(userID string, body []byte) (common.Submission, error) {
+	source, err := obj.store.QuerySourceBySourceID(common.UserSourceID(userID))
+	if err != nil {
+		return common.Submission{}, err
+	}
+	if source != nil && !source.Active {
+		return common.Submission{}, ErrBlocked
+	}
+	pending, err := obj.store.QuerySubmissions(common.SubmissionPending, userID)
+	if err != nil {
+		return common.Submission{}, err
+	}
+	if len(pending) >= MaxPending {
+		return common.Submission{}, ErrTooMany
+	}
+
+	submission := common.Submission{
+		SubmissionID: uuid.NewString(),
+		UserID:       userID,
+		Status:       common.SubmissionPending,
+		SubmittedAt:  time.Now().UTC(),
+	}
+	if submission.Event, err = obj.event(submission.SubmissionID, nil, body); err != nil {
+		return common.Submission{}, err
+	}
+	if err := obj.store.WriteSubmission(submission); err != nil {
+		return common.Submission{}, err
+	}
+	obj.logger.Info().Msgf("User %s submitted %q as %s", userID, submission.Event.Title, submission.SubmissionID)
+	return submission, nil
+}
+
+// event validates the fields in body laid over base. The source event ID is
+// always the submission's.
+func (obj *Moderation) event(submissionID string, base *common.IngestEvent, body []byte) (common.IngestEvent, error) {
+	fields := map[string]json.RawMessage{}
+	if base != nil {
+		data, err := json.Marshal(base)
+		if err != nil {
+			return common.IngestEvent{}, err
+		}
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return common.IngestEvent{}, err
+		}
+	}
+	var changes map[string]json.RawMessage
+	if err := json.Unmarshal(body, &changes); err != nil {
+		return common.IngestEvent{}, InvalidError{Errors: []string{err.Error()}}
+	}
+	for name, value := range changes {
+		fields[name] = value
+	}
+	fields["source_event"], _ = json.Marshal(submissionID)
+
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return common.IngestEvent{}, err
+	}
+	accepted, rejected, err := obj.ingester.Parse(data)
+	if err != nil {
+		return common.IngestEvent{}, InvalidError{Errors: []string{err.Error()}}
+	}
+	if len(rejected) > 0 {
+		return common.IngestEvent{}, InvalidError{Errors: rejected[0].Errors}
+	}
+	return accepted[0], nil
+}
+
+// Mine lists the submissions of userID
+func (obj *Moderation) Mine(userID string) ([]common.Submission, error) {
+	return obj.store.QuerySubmissions("", userID)
+}
+
+// List lists submissions with status, all when empty
+func (obj *Moderation) List(status common.SubmissionStatus) ([]common.Submission, error) {
+	return obj.store.QuerySubmissions(status, "")
+}
+
+// Edit changes fields of a pending submission, body holding the fields to change
+func (obj *Moderation) Edit(submissionID string, editor string, body []byte) (common.Submission, error) {
+	submission, err := obj.pending(submissionID)
+	if err != nil {
+		return common.Submission{}, err
+	}
+	if submission.Event, err = obj.event(submissionID, &submission.Event, body); err != nil {
+		return common.Submission{}, err
+	}
+	submission.EditedBy = editor
+	if err := obj.update(submission, common.SubmissionPending); err != nil {
+		return common.Submission{}, err
+	}
+	obj.logger.Info().Msgf("%s edited submission %s", editor, submissionID)
+	return submission, nil
+}
+
+// Approve queues a pending submission for the pipeline, as an event of the
+// submitter's user source, which is created on their first approval
+func (obj *Moderation) Approve(ctx context.Context, submissionID string, editor string) (common.Submission, error) {
+	submission, err := obj.pending(submissionID)
+	if err != nil {
+		return common.Submission{}, err
+	}
+	source, err := obj.userSource(submission.UserID)
+	if err != nil {
+		return common.Submission{}, err
+	}
+
+	reviewed := submission
+	reviewed.Status = common.SubmissionApproved
+	reviewed.ReviewedBy = editor
+	reviewed.ReviewedAt = time.Now().UTC()
+	if err := obj.update(reviewed, common.SubmissionPending); err != nil {
+		return common.Submission{}, err
+	}
+	if err := obj.ingester.Submit(ctx, source, []common.IngestEvent{reviewed.Event}); err != nil {
+		// back to pending, so it can be approved again
+		if revertErr := obj.update(submission, common.SubmissionApproved); revertErr != nil {
+			obj.logger.Error().Msgf("Submission %s is approved but was not queued: %v", submissionID, revertErr)
+		}
+		return common.Submission{}, err
+	}
+	obj.logger.Info().Msgf("%s approved submission %s", editor, submissionID)
+	return reviewed, nil
+}
+
+// Reject turns down a pending submission, telling the submitter why
+func (obj *Moderation) Reject(submissionID string, editor string, reason string) (common.Submission, error) {
+	submission, err := obj.pending(submissionID)
+	if err != nil {
+		return common.Submission{}, err
+	}
+	submission.Status = common.SubmissionRejected
+	submission.ReviewedBy = editor
+	submission.ReviewedAt = time.Now().UTC()
+	submission.Reason = reason
+	if err := obj.update(submission, common.SubmissionPending); err != nil {
+		return common.Submission{}, err
+	}
+	obj.logger.Info().Msgf("%s rejected submission %s", editor, submissionID)
+	return submission, nil
+}
+
+func (obj *Moderation) pending(submissionID string) (common.Submission, error) {
+	submission, err := obj.store.QuerySubmission(submissionID)
+	if err != nil {
+		return common.Submission{}, err
+	}
+	if submission == nil {
+		return common.Submission{}, ErrNotFound
+	}
+	if submission.Status != common.SubmissionPending {
+		return common.Submission{}, ErrNotPending
+	}
+	return *submission, nil
+}
+
+func (obj *Moderation) update(submission common.Submission, from common.SubmissionStatus) error {
+	err := obj.store.UpdateSubmission(submission, from)
+	var conflict *types.ConditionalCheckFailedException
+	if errors.As(err, &conflict) {
+		return ErrNotPending
+	}
+	return err
+}
+
+func (obj *Moderation) userSource(userID string) (common.Source, error) {
+	sourceID := common.UserSourceID(userID)
+	source, err := obj.store.QuerySourceBySourceID(sourceID)
+	if err != nil {
+		return common.Source{}, err
+	}
+	if source != nil {
+		if !source.Active {
+			return common.Source{}, ErrBlocked
+		}
+		return *source, nil
+	}
+	created := common.Source{SourceID: sourceID, Name: "Submitted by " + userID, SourceType: common.UserSource, Active: true}
+	return created, obj.store.WriteSource(created)
+}